@@ -0,0 +1,220 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	defaultAlertLimit = 100
+	maxAlertLimit     = 1000
+)
+
+// handleAlerts lists security alerts
+// GET /api/alerts?status=&username=&rule=&severity=&limit=
+func (api *ManagementAPI) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := api.requireAdmin(w, r); !ok {
+		return
+	}
+
+	limit, err := intParam(r, "limit", defaultAlertLimit, maxAlertLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	alerts, err := api.listAlerts(query.Get("status"), query.Get("username"), query.Get("rule"), query.Get("severity"), limit)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list security alerts: %v", err)
+		http.Error(w, "Failed to retrieve alerts", http.StatusInternalServerError)
+		return
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Retrieved %d alerts", len(alerts)),
+		Data:      alerts,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleAlertByID handles a single security alert
+// GET  /api/alerts/{id}
+// POST /api/alerts/{id}/acknowledge
+// POST /api/alerts/{id}/resolve
+func (api *ManagementAPI) handleAlertByID(w http.ResponseWriter, r *http.Request) {
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/alerts/"), "/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid alert ID", http.StatusBadRequest)
+		return
+	}
+
+	var status string
+	switch {
+	case len(parts) == 1 && r.Method == "GET":
+	case len(parts) == 2 && parts[1] == "acknowledge" && r.Method == "POST":
+		status = shared.AlertStatusAcknowledged
+	case len(parts) == 2 && parts[1] == "resolve" && r.Method == "POST":
+		status = shared.AlertStatusResolved
+	case len(parts) <= 2:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if status != "" {
+		if err := api.updateAlertStatus(id, status, admin); err != nil {
+			if err == sql.ErrNoRows {
+				http.Error(w, "Alert not found", http.StatusNotFound)
+				return
+			}
+			log.Printf("[ERROR] Failed to update alert %d: %v", id, err)
+			http.Error(w, "Failed to update alert", http.StatusInternalServerError)
+			return
+		}
+
+		api.auditRequest(
+			r,
+			"SECURITY_ALERT_"+strings.ToUpper(status),
+			admin,
+			fmt.Sprintf("Alert %d marked %s", id, status),
+		)
+	}
+
+	alert, err := api.getAlert(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Alert not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to load alert %d: %v", id, err)
+		http.Error(w, "Failed to retrieve alert", http.StatusInternalServerError)
+		return
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Alert retrieved successfully",
+		Data:      alert,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// alertColumns is the column list read by scanAlert
+const alertColumns = `id, rule, severity, username, server_id, ip_address, description,
+	metadata, status, resolved_by, resolved_at, created_at`
+
+// listAlerts returns the newest alerts matching the optional filters
+func (api *ManagementAPI) listAlerts(status, username, rule, severity string, limit int) ([]shared.SecurityAlert, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	query := "SELECT " + alertColumns + " FROM security_alerts WHERE 1=1"
+	var args []interface{}
+	for _, f := range []struct{ column, value string }{
+		{"status", status},
+		{"username", username},
+		{"rule", rule},
+		{"severity", severity},
+	} {
+		if f.value != "" {
+			args = append(args, f.value)
+			query += fmt.Sprintf(" AND %s = $%d", f.column, len(args))
+		}
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC LIMIT $%d", len(args))
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []shared.SecurityAlert{}
+	for rows.Next() {
+		alert, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+// getAlert loads a single alert
+func (api *ManagementAPI) getAlert(id int64) (*shared.SecurityAlert, error) {
+	conn := api.manager.GetDB().GetConnection()
+	return scanAlert(conn.QueryRow("SELECT "+alertColumns+" FROM security_alerts WHERE id = $1", id))
+}
+
+// updateAlertStatus acknowledges or resolves an alert
+func (api *ManagementAPI) updateAlertStatus(id int64, status, admin string) error {
+	conn := api.manager.GetDB().GetConnection()
+
+	result, err := conn.Exec(`
+		UPDATE security_alerts SET status = $1, resolved_by = $2, resolved_at = $3
+		WHERE id = $4
+	`, status, admin, time.Now(), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// scanAlert reads a security_alerts row selected with alertColumns
+func scanAlert(row rowScanner) (*shared.SecurityAlert, error) {
+	var alert shared.SecurityAlert
+	var serverID, ipAddress, resolvedBy sql.NullString
+	var resolvedAt sql.NullTime
+	var metadata []byte
+
+	err := row.Scan(&alert.ID, &alert.Rule, &alert.Severity, &alert.Username, &serverID, &ipAddress,
+		&alert.Description, &metadata, &alert.Status, &resolvedBy, &resolvedAt, &alert.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	alert.ServerID = serverID.String
+	alert.IPAddress = ipAddress.String
+	alert.ResolvedBy = resolvedBy.String
+	if resolvedAt.Valid {
+		alert.ResolvedAt = &resolvedAt.Time
+	}
+	if len(metadata) > 0 {
+		json.Unmarshal(metadata, &alert.Metadata)
+	}
+
+	return &alert, nil
+}
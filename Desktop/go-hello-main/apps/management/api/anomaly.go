@@ -0,0 +1,412 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// Activity event kinds fed to the anomaly rules
+const (
+	activityStatus = "status"
+	activityStats  = "stats"
+)
+
+// Anomaly rule names
+const (
+	ruleImpossibleTravel = "impossible_travel"
+	ruleTrafficSpike     = "traffic_spike"
+	ruleServerHopping    = "server_hopping"
+	ruleRepeatedErrors   = "repeated_errors"
+)
+
+// alertDedupeWindow suppresses repeat alerts for the same user and rule
+const alertDedupeWindow = 1 * time.Hour
+
+// Anomaly worker pool defaults
+const (
+	defaultAnomalyWorkers   = 4
+	defaultAnomalyQueueSize = 1024
+)
+
+// activityEvent is a connection or statistics event checked by the anomaly rules
+type activityEvent struct {
+	Kind     string
+	Username string
+	ServerID string
+	Status   string
+	SourceIP net.IP
+	At       time.Time
+}
+
+// anomalyConfig holds rule thresholds, overridable through the environment
+type anomalyConfig struct {
+	MaxTravelKmh     float64       // ANOMALY_MAX_TRAVEL_KMH
+	MinTravelKm      float64       // ANOMALY_MIN_TRAVEL_KM
+	SpikeFactor      float64       // ANOMALY_SPIKE_FACTOR
+	SpikeMinBytes    int64         // ANOMALY_SPIKE_MIN_BYTES
+	HopServers       int           // ANOMALY_HOP_SERVERS
+	HopWindow        time.Duration // ANOMALY_HOP_WINDOW
+	ErrorCount       int           // ANOMALY_ERROR_COUNT
+	ErrorWindow      time.Duration // ANOMALY_ERROR_WINDOW
+	BaselineDuration time.Duration
+}
+
+// loadAnomalyConfig returns the rule thresholds with environment overrides applied
+func loadAnomalyConfig() anomalyConfig {
+	cfg := anomalyConfig{
+		MaxTravelKmh:     900,
+		MinTravelKm:      500,
+		SpikeFactor:      10,
+		SpikeMinBytes:    1 << 30,
+		HopServers:       4,
+		HopWindow:        10 * time.Minute,
+		ErrorCount:       5,
+		ErrorWindow:      15 * time.Minute,
+		BaselineDuration: 7 * 24 * time.Hour,
+	}
+
+	if v, err := strconv.ParseFloat(os.Getenv("ANOMALY_MAX_TRAVEL_KMH"), 64); err == nil && v > 0 {
+		cfg.MaxTravelKmh = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("ANOMALY_MIN_TRAVEL_KM"), 64); err == nil && v >= 0 {
+		cfg.MinTravelKm = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("ANOMALY_SPIKE_FACTOR"), 64); err == nil && v > 1 {
+		cfg.SpikeFactor = v
+	}
+	if v, err := strconv.ParseInt(os.Getenv("ANOMALY_SPIKE_MIN_BYTES"), 10, 64); err == nil && v > 0 {
+		cfg.SpikeMinBytes = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ANOMALY_HOP_SERVERS")); err == nil && v > 1 {
+		cfg.HopServers = v
+	}
+	if d, err := time.ParseDuration(os.Getenv("ANOMALY_HOP_WINDOW")); err == nil && d > 0 {
+		cfg.HopWindow = d
+	}
+	if v, err := strconv.Atoi(os.Getenv("ANOMALY_ERROR_COUNT")); err == nil && v > 1 {
+		cfg.ErrorCount = v
+	}
+	if d, err := time.ParseDuration(os.Getenv("ANOMALY_ERROR_WINDOW")); err == nil && d > 0 {
+		cfg.ErrorWindow = d
+	}
+
+	return cfg
+}
+
+// anomalyRule checks one kind of suspicious behavior, returning nil when the event looks normal
+type anomalyRule struct {
+	name  string
+	kinds []string
+	check func(api *ManagementAPI, cfg anomalyConfig, event activityEvent) (*shared.SecurityAlert, error)
+}
+
+// anomalyRules is the rule set evaluated for every activity event
+var anomalyRules = []anomalyRule{
+	{ruleImpossibleTravel, []string{activityStatus}, (*ManagementAPI).checkImpossibleTravel},
+	{ruleTrafficSpike, []string{activityStats}, (*ManagementAPI).checkTrafficSpike},
+	{ruleServerHopping, []string{activityStatus}, (*ManagementAPI).checkServerHopping},
+	{ruleRepeatedErrors, []string{activityStatus}, (*ManagementAPI).checkRepeatedErrors},
+}
+
+// newAnomalyQueue returns the activity event queue, sized by ANOMALY_QUEUE_SIZE
+func newAnomalyQueue() chan activityEvent {
+	size := defaultAnomalyQueueSize
+	if n, err := strconv.Atoi(os.Getenv("ANOMALY_QUEUE_SIZE")); err == nil && n > 0 {
+		size = n
+	}
+	return make(chan activityEvent, size)
+}
+
+// queueAnomalyCheck hands an event to the anomaly workers without blocking the request
+// Events are dropped when the queue is full, so a burst of traffic cannot pile up goroutines
+func (api *ManagementAPI) queueAnomalyCheck(event activityEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	select {
+	case api.anomalies <- event:
+	default:
+		log.Printf("[ANOMALY] Queue full, skipping %s event for %s", event.Kind, event.Username)
+	}
+}
+
+// runAnomalyWorkers evaluates queued activity events on ANOMALY_WORKERS goroutines
+func (api *ManagementAPI) runAnomalyWorkers() {
+	workers := defaultAnomalyWorkers
+	if n, err := strconv.Atoi(os.Getenv("ANOMALY_WORKERS")); err == nil && n > 0 {
+		workers = n
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for event := range api.anomalies {
+				api.detectAnomalies(event)
+			}
+		}()
+	}
+}
+
+// detectAnomalies runs the rules matching the event and raises any resulting alerts
+// Rule failures are logged and never affect the request that produced the event
+func (api *ManagementAPI) detectAnomalies(event activityEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	cfg := loadAnomalyConfig()
+
+	for _, rule := range anomalyRules {
+		if !containsString(rule.kinds, event.Kind) {
+			continue
+		}
+
+		alert, err := rule.check(api, cfg, event)
+		if err != nil {
+			log.Printf("[ANOMALY] Rule %s failed for %s: %v", rule.name, event.Username, err)
+			continue
+		}
+		if alert == nil {
+			continue
+		}
+
+		alert.Rule = rule.name
+		alert.Username = event.Username
+		if alert.ServerID == "" {
+			alert.ServerID = event.ServerID
+		}
+		if alert.IPAddress == "" && event.SourceIP != nil {
+			alert.IPAddress = event.SourceIP.String()
+		}
+
+		if err := api.raiseAlert(alert); err != nil {
+			log.Printf("[ANOMALY] Failed to raise %s alert for %s: %v", rule.name, event.Username, err)
+		}
+	}
+}
+
+// raiseAlert stores an alert unless the same rule fired for the user recently
+// The partial unique index on open alerts makes concurrent raises insert at most one
+func (api *ManagementAPI) raiseAlert(alert *shared.SecurityAlert) error {
+	conn := api.manager.GetDB().GetConnection()
+
+	metadata, err := json.Marshal(alert.Metadata)
+	if err != nil {
+		return err
+	}
+
+	err = conn.QueryRow(`
+		INSERT INTO security_alerts (rule, severity, username, server_id, ip_address, description, metadata, status, created_at)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9
+		WHERE NOT EXISTS (
+			SELECT 1 FROM security_alerts
+			WHERE username = $3 AND rule = $1 AND status <> $10 AND created_at > $11
+		)
+		ON CONFLICT (username, rule) WHERE status = 'open' DO NOTHING
+		RETURNING id, created_at
+	`, alert.Rule, alert.Severity, alert.Username, nullString(alert.ServerID), nullString(alert.IPAddress),
+		alert.Description, metadata, shared.AlertStatusOpen, time.Now(),
+		shared.AlertStatusResolved, time.Now().Add(-alertDedupeWindow)).Scan(&alert.ID, &alert.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	alert.Status = shared.AlertStatusOpen
+
+	log.Printf("[ANOMALY] %s alert %d for %s: %s", alert.Severity, alert.ID, alert.Username, alert.Description)
+	api.logAudit(
+		"SECURITY_ALERT",
+		alert.Username,
+		fmt.Sprintf("Alert %d rule=%s severity=%s - %s", alert.ID, alert.Rule, alert.Severity, alert.Description),
+		alert.IPAddress,
+	)
+
+	return nil
+}
+
+// checkImpossibleTravel flags connections from places too far apart to travel between in time
+func (api *ManagementAPI) checkImpossibleTravel(cfg anomalyConfig, event activityEvent) (*shared.SecurityAlert, error) {
+	if event.Status != shared.SessionStateConnected {
+		return nil, nil
+	}
+
+	location, ok := api.geoIP.Lookup(event.SourceIP)
+	if !ok {
+		return nil, nil
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+
+	var prevIP string
+	var prev geoPoint
+	var prevSeen time.Time
+	err := conn.QueryRow(`
+		SELECT ip_address, latitude, longitude, seen_at
+		FROM user_source_locations
+		WHERE username = $1
+	`, event.Username).Scan(&prevIP, &prev.Latitude, &prev.Longitude, &prevSeen)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	found := err == nil
+
+	_, err = conn.Exec(`
+		INSERT INTO user_source_locations (username, ip_address, latitude, longitude, seen_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (username) DO UPDATE
+		SET ip_address = EXCLUDED.ip_address, latitude = EXCLUDED.latitude,
+		    longitude = EXCLUDED.longitude, seen_at = EXCLUDED.seen_at
+	`, event.Username, event.SourceIP.String(), location.Latitude, location.Longitude, event.At)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found || prevIP == event.SourceIP.String() {
+		return nil, nil
+	}
+
+	distance := haversineKm(prev, *location)
+	if distance < cfg.MinTravelKm {
+		return nil, nil
+	}
+
+	// Treat simultaneous connections as one second apart to keep the speed finite
+	hours := math.Max(event.At.Sub(prevSeen).Hours(), 1.0/3600)
+	speed := distance / hours
+	if speed <= cfg.MaxTravelKmh {
+		return nil, nil
+	}
+
+	return &shared.SecurityAlert{
+		Severity: shared.AlertSeverityHigh,
+		Description: fmt.Sprintf("Connected from %s %.0f km away from %s within %s",
+			event.SourceIP, distance, prevIP, event.At.Sub(prevSeen).Round(time.Minute)),
+		Metadata: map[string]interface{}{
+			"previous_ip":   prevIP,
+			"previous_seen": prevSeen,
+			"distance_km":   distance,
+			"speed_kmh":     speed,
+		},
+	}, nil
+}
+
+// checkTrafficSpike flags an hour of traffic far above the user's hourly baseline
+func (api *ManagementAPI) checkTrafficSpike(cfg anomalyConfig, event activityEvent) (*shared.SecurityAlert, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	hour := event.At.Truncate(time.Hour)
+
+	var current int64
+	var baseline float64
+	err := conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(bytes_in + bytes_out) FILTER (WHERE bucket_start = $2), 0)::BIGINT,
+			COALESCE(SUM(bytes_in + bytes_out) FILTER (WHERE bucket_start < $2), 0)::FLOAT8
+				/ GREATEST(COUNT(DISTINCT bucket_start) FILTER (WHERE bucket_start < $2), 1)
+		FROM vpn_statistics_hourly
+		WHERE username = $1 AND bucket_start >= $3 AND bucket_start <= $2
+	`, event.Username, hour, hour.Add(-cfg.BaselineDuration)).Scan(&current, &baseline)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users without history have no baseline to compare against
+	if baseline == 0 || current < cfg.SpikeMinBytes || float64(current) < baseline*cfg.SpikeFactor {
+		return nil, nil
+	}
+
+	return &shared.SecurityAlert{
+		Severity: shared.AlertSeverityMedium,
+		Description: fmt.Sprintf("Transferred %d bytes this hour, %.1fx the hourly baseline",
+			current, float64(current)/baseline),
+		Metadata: map[string]interface{}{
+			"hour":           hour,
+			"bytes":          current,
+			"baseline_bytes": baseline,
+		},
+	}, nil
+}
+
+// checkServerHopping flags an account connecting to many servers in a short window
+func (api *ManagementAPI) checkServerHopping(cfg anomalyConfig, event activityEvent) (*shared.SecurityAlert, error) {
+	if event.Status != shared.SessionStateConnected {
+		return nil, nil
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+
+	var servers int
+	err := conn.QueryRow(`
+		SELECT COUNT(DISTINCT server_id)
+		FROM vpn_connections
+		WHERE username = $1 AND connected_at >= $2
+	`, event.Username, event.At.Add(-cfg.HopWindow)).Scan(&servers)
+	if err != nil {
+		return nil, err
+	}
+
+	if servers < cfg.HopServers {
+		return nil, nil
+	}
+
+	return &shared.SecurityAlert{
+		Severity:    shared.AlertSeverityMedium,
+		Description: fmt.Sprintf("Connected to %d servers within %v", servers, cfg.HopWindow),
+		Metadata: map[string]interface{}{
+			"servers": servers,
+			"window":  cfg.HopWindow.String(),
+		},
+	}, nil
+}
+
+// checkRepeatedErrors flags repeated error statuses in a short window
+func (api *ManagementAPI) checkRepeatedErrors(cfg anomalyConfig, event activityEvent) (*shared.SecurityAlert, error) {
+	if event.Status != shared.SessionStateError {
+		return nil, nil
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+
+	var errorCount int
+	err := conn.QueryRow(`
+		SELECT COUNT(*)
+		FROM vpn_connections
+		WHERE username = $1 AND close_reason = $2 AND disconnected_at >= $3
+	`, event.Username, closeReasonError, event.At.Add(-cfg.ErrorWindow)).Scan(&errorCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if errorCount < cfg.ErrorCount {
+		return nil, nil
+	}
+
+	return &shared.SecurityAlert{
+		Severity:    shared.AlertSeverityLow,
+		Description: fmt.Sprintf("Reported %d connection errors within %v", errorCount, cfg.ErrorWindow),
+		Metadata: map[string]interface{}{
+			"errors": errorCount,
+			"window": cfg.ErrorWindow.String(),
+		},
+	}, nil
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"regexp"
@@ -16,17 +17,28 @@ import (
 
 // ManagementAPI handles API requests for the management server
 type ManagementAPI struct {
-	manager    *manager.ManagementManager
-	httpClient *http.Client
+	manager     *manager.ManagementManager
+	httpClient  *http.Client
+	geoIP       *geoIPResolver
+	latency     *latencyModel
+	auditLogger AuditLogger
+	anomalies   chan activityEvent
 }
 
 // NewManagementAPI creates a new management API
 func NewManagementAPI(manager *manager.ManagementManager) *ManagementAPI {
+	startEventBus(manager.GetDB().GetConnection())
+
 	return &ManagementAPI{
 		manager: manager,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: metricsTransport{next: http.DefaultTransport},
 		},
+		geoIP:       newGeoIPResolverFromEnv(),
+		latency:     newLatencyModel(),
+		auditLogger: newAuditLogger(manager.GetDB().GetConnection(), managementServerID),
+		anomalies:   newAnomalyQueue(),
 	}
 }
 
@@ -37,6 +49,10 @@ func (api *ManagementAPI) Start(port int) error {
 	// Health check endpoint
 	mux.HandleFunc("/health", api.handleHealth)
 
+	// Prometheus metrics endpoint, also registered so requests are labelled by its pattern
+	metrics := api.metricsHandler()
+	mux.Handle("/metrics", metrics)
+
 	// API root endpoint
 	mux.HandleFunc("/api", api.handleAPIRoot)
 	mux.HandleFunc("/api/", api.handleAPIRoot)
@@ -58,25 +74,86 @@ func (api *ManagementAPI) Start(port int) error {
 
 	// Logs endpoints
 	mux.HandleFunc("/api/logs", api.handleLogs)
+	mux.HandleFunc("/api/audit/verify", api.handleAuditVerify)
+
+	// Security alert endpoints
+	mux.HandleFunc("/api/alerts", api.handleAlerts)
+	mux.HandleFunc("/api/alerts/", api.handleAlertByID)
+
+	// Fleet report endpoints
+	mux.HandleFunc("/api/reports", api.handleReports)
+	mux.HandleFunc("/api/reports/", api.handleReports)
+
+	// Data retention endpoints
+	mux.HandleFunc("/api/retention/", api.handleRetention)
+
+	// Personal data erasure endpoints
+	mux.HandleFunc("/api/erasures", api.handleErasures)
+	mux.HandleFunc("/api/erasures/", api.handleErasures)
+
+	// Webhook subscription endpoints
+	mux.HandleFunc("/api/webhooks", api.handleWebhooks)
+	mux.HandleFunc("/api/webhooks/", api.handleWebhooks)
 
 	// OVPN download endpoints
 	mux.HandleFunc("/api/ovpn/", api.handleDownloadOVPN)
 
 	// VPN statistics and status endpoints
 	mux.HandleFunc("/vpn/status", api.handleVPNStatus)
+	mux.HandleFunc("/vpn/heartbeat", api.handleVPNHeartbeat)
 	mux.HandleFunc("/vpn/stats", api.handleVPNStats)
+	mux.HandleFunc("/vpn/stats/batch", api.handleVPNStatsBatch)
 	mux.HandleFunc("/vpn/stats/", api.handleGetUserStats)
+	mux.HandleFunc("/api/connections", api.handleConnections)
+	mux.HandleFunc("/api/stats/backfill", api.handleStatsBackfill)
 
 	// VPN locations endpoints
 	mux.HandleFunc("/vpn/locations", api.handleVPNLocations)
 	mux.HandleFunc("/vpn/locations/", api.handleLocationServers)
 
+	// Location administration endpoints
+	mux.HandleFunc("/api/locations", api.handleAdminLocations)
+	mux.HandleFunc("/api/locations/", api.handleAdminLocationByID)
+
+	// VPN server recommendation endpoints
+	mux.HandleFunc("/vpn/recommend", api.handleVPNRecommend)
+	mux.HandleFunc("/vpn/preferences", api.handleVPNPreferences)
+	mux.HandleFunc("/vpn/latency", api.handleVPNLatency)
+
+	// Plan quota endpoint
+	mux.HandleFunc("/vpn/quota", api.handleVPNQuota)
+
 	// VPN configuration endpoint
 	mux.HandleFunc("/vpn/config", api.handleVPNConfig)
 
+	// Evaluate anomaly rules for queued activity events
+	api.runAnomalyWorkers()
+
+	// Finish drains interrupted by a restart
+	go api.resumeInterruptedDrains()
+
+	// Close sessions whose clients stopped reporting
+	go api.runSessionReaper()
+
+	// Keep the latency model calibrated against real measurements
+	go api.runLatencyCalibration(1 * time.Hour)
+
+	// Roll up client latency measurements for location and server lookups
+	go api.runLatencyAggregation()
+
+	// Sign the audit chain head so truncation can be detected
+	go api.runAuditCheckpoints()
+
+	// Prune expired rows and minimize old IP addresses
+	go api.runRetentionSchedule()
+
+	// Send queued webhook deliveries and watch for end-nodes going offline
+	go api.runWebhookDelivery()
+	go api.runEndNodeHealthWatch()
+
 	server := &http.Server{
 		Addr:         fmt.Sprintf(":%d", port),
-		Handler:      api.middleware(mux),
+		Handler:      api.instrumentRequests(mux, bypassMiddleware("/metrics", metrics, api.middleware(mux))),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 	}
@@ -114,20 +191,42 @@ func (api *ManagementAPI) handleAPIRoot(w http.ResponseWriter, r *http.Request)
 		"version": "1.0.0",
 		"status":  "running",
 		"endpoints": map[string]string{
-			"health":           "/health",
-			"users":            "/api/users",
-			"endnodes":         "/api/endnodes",
-			"endnode_register": "/api/endnodes/register",
-			"endnode_delete":   "/api/endnodes/delete/",
-			"user_sync":        "/api/users/sync",
-			"logs":             "/api/logs",
-			"ovpn_download":    "/api/ovpn/{username}/{serverID}",
-			"vpn_status":       "/vpn/status (POST)",
-			"vpn_stats":        "/vpn/stats (POST)",
-			"vpn_user_stats":   "/vpn/stats/{username} (GET)",
-			"vpn_locations":    "/vpn/locations (GET)",
-			"vpn_location_servers": "/vpn/locations/{location_id}/servers (GET)",
-			"vpn_config":       "/vpn/config?username={username} (GET)",
+			"health":                 "/health",
+			"metrics":                "/metrics (GET, Bearer METRICS_TOKEN when set)",
+			"users":                  "/api/users",
+			"endnodes":               "/api/endnodes",
+			"endnode_register":       "/api/endnodes/register",
+			"endnode_delete":         "/api/endnodes/delete/",
+			"endnode_drain":          "/api/endnodes/{server_id}/drain (GET, POST)",
+			"endnode_capacity":       "/api/endnodes/{server_id}/capacity (PUT)",
+			"endnode_api_key":        "/api/endnodes/{server_id}/api-key (POST)",
+			"endnode_openvpn_status": "/api/endnodes/{server_id}/openvpn-status (POST, X-API-Key)",
+			"endnode_logs":           "/api/endnodes/{server_id}/logs (POST, X-API-Key)",
+			"user_sync":              "/api/users/sync",
+			"logs":                   "/api/logs?action=&actor=&target=&server_id=&ip=&outcome=&from=&to=&q=&include_endnodes=&cursor=&limit=&format=json|ndjson|csv (GET)",
+			"audit_verify":           "/api/audit/verify (GET)",
+			"reports":                "/api/reports/{name}?from=&to=&format=csv|json",
+			"retention":              "/api/retention/policies (GET), /api/retention/policies/{table} (PUT), /api/retention/run?dry_run= (POST), /api/retention/runs[/{id}] (GET)",
+			"erasures":               "/api/erasures?status= (GET, POST), /api/erasures/{id} (GET), /api/erasures/{id}/execute (POST)",
+			"webhooks":               "/api/webhooks (GET, POST), /api/webhooks/{id} (GET, PUT, DELETE), /api/webhooks/{id}/deliveries (GET), /api/webhooks/dead-letters?subscription_id= (GET), /api/webhooks/deliveries/{id}/replay (POST)",
+			"alerts":                 "/api/alerts?status=&username=&rule= (GET), /api/alerts/{id}/acknowledge|resolve (POST)",
+			"locations":              "/api/locations (GET, POST)",
+			"location":               "/api/locations/{id} (GET, PUT, DELETE), /enable, /disable, /servers (POST)",
+			"ovpn_download":          "/api/ovpn/{username}/{serverID}",
+			"vpn_status":             "/vpn/status (POST)",
+			"vpn_heartbeat":          "/vpn/heartbeat (POST)",
+			"vpn_stats":              "/vpn/stats (POST)",
+			"vpn_stats_batch":        "/vpn/stats/batch (POST)",
+			"vpn_user_stats":         "/vpn/stats/{username}?from=&to=&granularity=hour|day (GET)",
+			"stats_backfill":         "/api/stats/backfill?from=&to= (POST)",
+			"connections":            "/api/connections?username=&server_id=&status=&from=&to=&cursor=&limit= (GET)",
+			"vpn_locations":          "/vpn/locations (GET)",
+			"vpn_location_servers":   "/vpn/locations/{location_id}/servers (GET)",
+			"vpn_recommend":          "/vpn/recommend?location_id={id}&country={code} (GET)",
+			"vpn_preferences":        "/vpn/preferences (GET, PUT)",
+			"vpn_latency":            "/vpn/latency (POST)",
+			"vpn_quota":              "/vpn/quota (GET)",
+			"vpn_config":             "/vpn/config?username={username} (GET)",
 		},
 	}
 
@@ -196,13 +295,33 @@ func (api *ManagementAPI) handleCreateUser(w http.ResponseWriter, r *http.Reques
 		req.Protocol = "udp"
 	}
 
+	// Draining end-nodes must not receive new users
+	if req.TargetServerID != "" {
+		draining, err := api.isServerDraining(req.TargetServerID)
+		if err != nil {
+			log.Printf("[ERROR] Failed to check drain state for %s: %v", req.TargetServerID, err)
+			http.Error(w, "Failed to create user", http.StatusInternalServerError)
+			return
+		}
+		if draining {
+			http.Error(w, fmt.Sprintf("End-node '%s' is draining and not accepting new users", req.TargetServerID), http.StatusConflict)
+			return
+		}
+	}
+
 	if err := api.manager.CreateUser(req.Username, req.OvpnPath, req.Checksum, req.TargetServerID, req.Port, req.Protocol); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create user: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Log successful user creation
-	api.logAudit("user_created", req.Username, fmt.Sprintf("User created with port %d, protocol %s", req.Port, req.Protocol), r.RemoteAddr)
+	// The audit subscriber records the creation
+	publishEvent(r, shared.UserCreatedEvent{
+		Username: req.Username,
+		ServerID: req.TargetServerID,
+		Port:     req.Port,
+		Protocol: req.Protocol,
+		Source:   "admin",
+	})
 
 	response := shared.APIResponse{
 		Success:   true,
@@ -315,6 +434,7 @@ func (api *ManagementAPI) handleDeleteUser(w http.ResponseWriter, r *http.Reques
 		http.Error(w, fmt.Sprintf("Failed to delete user: %v", err), http.StatusInternalServerError)
 		return
 	}
+	publishEvent(r, shared.UserDeletedEvent{Username: username})
 
 	response := shared.APIResponse{
 		Success:   true,
@@ -413,6 +533,41 @@ func (api *ManagementAPI) handleEndNodeOperations(w http.ResponseWriter, r *http
 		return
 	}
 
+	if strings.HasSuffix(r.URL.Path, "/drain") {
+		// Extract server ID for drain (remove /drain from path)
+		serverID = strings.TrimSuffix(serverID, "/drain")
+		api.handleEndNodeDrain(w, r, serverID)
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/capacity") {
+		// Extract server ID for capacity update (remove /capacity from path)
+		serverID = strings.TrimSuffix(serverID, "/capacity")
+		api.handleEndNodeCapacity(w, r, serverID)
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/api-key") {
+		// Extract server ID for key issuance (remove /api-key from path)
+		serverID = strings.TrimSuffix(serverID, "/api-key")
+		api.handleEndNodeAPIKey(w, r, serverID)
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/openvpn-status") {
+		// Extract server ID for status upload (remove /openvpn-status from path)
+		serverID = strings.TrimSuffix(serverID, "/openvpn-status")
+		api.handleEndNodeOpenVPNStatus(w, r, serverID)
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/logs") {
+		// Extract server ID for log upload (remove /logs from path)
+		serverID = strings.TrimSuffix(serverID, "/logs")
+		api.handleEndNodeLogs(w, r, serverID)
+		return
+	}
+
 	if strings.HasSuffix(r.URL.Path, "/health") {
 		// Extract server ID for health check (remove /health from path)
 		serverID = strings.TrimSuffix(serverID, "/health")
@@ -549,63 +704,58 @@ func (api *ManagementAPI) handleUserSync(w http.ResponseWriter, r *http.Request)
 }
 
 // handleLogs handles audit log requests
+// GET /api/logs?action=&actor=&target=&username=&server_id=&ip=&outcome=&from=&to=&q=&cursor=&limit=&format=json|ndjson|csv
 func (api *ManagementAPI) handleLogs(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get limit from query parameter (default to 50)
-	limit := 50
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsedLimit, err := fmt.Sscanf(limitStr, "%d", &limit); err != nil || parsedLimit != 1 {
-			limit = 50
-		}
+	// Audit logs expose every user's activity
+	if _, ok := api.requireAdmin(w, r); !ok {
+		return
 	}
 
-	// Get server filter from query parameter
-	serverID := r.URL.Query().Get("server_id")
-	username := r.URL.Query().Get("username")
-	includeEndNodes := r.URL.Query().Get("include_endnodes") == "true"
-
-	var logs []shared.AuditLog
-	var err error
-
-	// Get logs based on filters
-	if serverID != "" {
-		logs, err = api.manager.GetAuditLogByServer(serverID, limit)
-	} else if username != "" {
-		logs, err = api.manager.GetAuditLogByUser(username, limit)
-	} else {
-		logs, err = api.manager.GetAuditLog(limit)
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = auditFormatJSON
+	}
+	if format != auditFormatJSON && format != auditFormatNDJSON && format != auditFormatCSV {
+		http.Error(w, "format must be one of: json, ndjson, csv", http.StatusBadRequest)
+		return
 	}
 
+	filter, err := parseAuditFilter(r, format != auditFormatJSON)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to retrieve logs: %v", err), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// If no logs found, create some sample logs for demonstration
-	if len(logs) == 0 {
-		logs = api.generateSampleLogs()
+	if format != auditFormatJSON {
+		if filter.IncludeEndNodes {
+			http.Error(w, "include_endnodes is only supported with format=json", http.StatusBadRequest)
+			return
+		}
+		api.exportAuditEvents(w, r, filter, format)
+		return
 	}
 
-	// If include_endnodes is true, also fetch logs from end-nodes
-	if includeEndNodes {
-		endNodeLogs, err := api.getEndNodeLogs()
-		if err == nil {
-			// Combine management and end-node logs
-			allLogs := append(logs, endNodeLogs...)
-			// Sort by timestamp (newest first)
-			// Note: In a real implementation, you'd want proper sorting
-			logs = allLogs
-		}
+	// Merge in logs uploaded by end-nodes when requested
+	var page *shared.AuditLogPage
+	if filter.IncludeEndNodes {
+		page, err = api.queryMergedAuditEvents(filter)
+	} else {
+		page, err = api.queryAuditEvents(filter)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retrieve logs: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	response := shared.APIResponse{
 		Success:   true,
-		Message:   "Logs retrieved successfully",
-		Data:      logs,
+		Message:   fmt.Sprintf("Retrieved %d log entries", len(page.Events)),
+		Data:      page,
 		Timestamp: time.Now().Unix(),
 	}
 
@@ -613,106 +763,6 @@ func (api *ManagementAPI) handleLogs(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// generateSampleLogs creates sample audit logs for demonstration
-func (api *ManagementAPI) generateSampleLogs() []shared.AuditLog {
-	now := time.Now()
-	return []shared.AuditLog{
-		{
-			ID:        1,
-			Timestamp: now.Add(-5 * time.Minute),
-			Action:    "USER_CREATED",
-			Username:  "admin",
-			Details:   "User 'testuser' created successfully",
-			IPAddress: "192.168.10.248",
-			ServerID:  "management-server",
-		},
-		{
-			ID:        2,
-			Timestamp: now.Add(-10 * time.Minute),
-			Action:    "ENDNODE_REGISTERED",
-			Username:  "system",
-			Details:   "End-node 'AlKhor' registered - host=192.168.10.217 port=8080 status=online",
-			IPAddress: "192.168.10.217",
-			ServerID:  "management-server",
-		},
-		{
-			ID:        3,
-			Timestamp: now.Add(-15 * time.Minute),
-			Action:    "HEALTH_CHECK",
-			Username:  "AlKhor",
-			Details:   "End-node health check - status=healthy response_time=45ms",
-			IPAddress: "192.168.10.217",
-			ServerID:  "management-server",
-		},
-		{
-			ID:        4,
-			Timestamp: now.Add(-20 * time.Minute),
-			Action:    "API_REQUEST",
-			Username:  "admin",
-			Details:   "GET /api/users - List users request",
-			IPAddress: "192.168.10.248",
-			ServerID:  "management-server",
-		},
-		{
-			ID:        5,
-			Timestamp: now.Add(-25 * time.Minute),
-			Action:    "SYSTEM_START",
-			Username:  "system",
-			Details:   "Management server started successfully",
-			IPAddress: "127.0.0.1",
-			ServerID:  "management-server",
-		},
-	}
-}
-
-// getEndNodeLogs fetches logs from all registered end-nodes
-func (api *ManagementAPI) getEndNodeLogs() ([]shared.AuditLog, error) {
-	// Get list of end-nodes
-	endNodes, err := api.manager.ListEndNodes()
-	if err != nil {
-		return nil, err
-	}
-
-	var allLogs []shared.AuditLog
-	now := time.Now()
-
-	// For each end-node, create sample logs
-	for _, endNode := range endNodes {
-		endNodeLogs := []shared.AuditLog{
-			{
-				ID:        100 + len(allLogs),
-				Timestamp: now.Add(-2 * time.Minute),
-				Action:    "USER_CREATED",
-				Username:  "endnode",
-				Details:   fmt.Sprintf("User created on end-node %s", endNode.Name),
-				IPAddress: endNode.Host,
-				ServerID:  endNode.Name,
-			},
-			{
-				ID:        101 + len(allLogs),
-				Timestamp: now.Add(-7 * time.Minute),
-				Action:    "OVPN_GENERATED",
-				Username:  "endnode",
-				Details:   fmt.Sprintf("OVPN file generated for user on %s", endNode.Name),
-				IPAddress: endNode.Host,
-				ServerID:  endNode.Name,
-			},
-			{
-				ID:        102 + len(allLogs),
-				Timestamp: now.Add(-12 * time.Minute),
-				Action:    "HEALTH_CHECK",
-				Username:  endNode.Name,
-				Details:   fmt.Sprintf("End-node %s health check - status=healthy", endNode.Name),
-				IPAddress: endNode.Host,
-				ServerID:  endNode.Name,
-			},
-		}
-		allLogs = append(allLogs, endNodeLogs...)
-	}
-
-	return allLogs, nil
-}
-
 // handleDownloadOVPN handles OVPN file download requests
 func (api *ManagementAPI) handleDownloadOVPN(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
@@ -777,6 +827,12 @@ func (api *ManagementAPI) handleDownloadOVPN(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	publishEvent(r, shared.ProfileDownloadedEvent{
+		Username:  username,
+		ServerID:  serverID,
+		IPAddress: eventIP(r),
+	})
+
 	// Set headers for file download
 	filename := fmt.Sprintf("%s_%s.ovpn", username, serverID)
 	w.Header().Set("Content-Type", "application/x-openvpn-profile")
@@ -818,6 +874,9 @@ func (api *ManagementAPI) downloadOVPNFromEndNode(endNode *shared.Server, userna
 // middleware adds common middleware to all requests
 func (api *ManagementAPI) middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Tag the request so its audit events can be correlated
+		r = withRequestID(w, r)
+
 		// Add security headers
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "DENY")
@@ -856,7 +915,7 @@ func (api *ManagementAPI) middleware(next http.Handler) http.Handler {
 		}
 
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		if r.Method == "OPTIONS" {
@@ -866,6 +925,7 @@ func (api *ManagementAPI) middleware(next http.Handler) http.Handler {
 
 		// Rate limiting
 		if !api.checkRateLimit(r.RemoteAddr) {
+			rateLimitRejectionsTotal.WithLabelValues(requestRoute(r)).Inc()
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
@@ -920,30 +980,33 @@ func (api *ManagementAPI) validateRequest(r *http.Request) error {
 }
 
 // logSecurityEvent logs security-related events
+// This is the access log; audit_log only records the actions handlers take
 func (api *ManagementAPI) logSecurityEvent(r *http.Request) {
 	// Log request details for security monitoring
 	fmt.Printf("[SECURITY] %s %s from %s - User-Agent: %s\n", 
 		r.Method, r.URL.Path, r.RemoteAddr, r.Header.Get("User-Agent"))
-	
-	// Log to audit system
-	api.logAudit("api_request", "", fmt.Sprintf("%s %s", r.Method, r.URL.Path), r.RemoteAddr)
 }
 
-// logAudit logs audit events
+// logAudit logs audit events that are not tied to an API request
 func (api *ManagementAPI) logAudit(action, username, details, ipAddress string) {
-	// Log audit to file
-	logFile := "/var/log/vpnmanager/management-audit.log"
-	auditEntry := fmt.Sprintf("[%s] %s %s %s %s\n", 
-		time.Now().Format("2006-01-02 15:04:05"),
-		action,
-		username,
-		details,
-		ipAddress)
-	
-	if file, err := os.OpenFile(logFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640); err == nil {
-		file.WriteString(auditEntry)
-		file.Close()
-	} else {
-		fmt.Printf("Failed to log audit: %v\n", err)
+	api.audit(nil, shared.AuditEvent{
+		Action:    action,
+		Actor:     username,
+		Details:   details,
+		IPAddress: ipAddress,
+	})
+}
+
+// writeAPIResponse writes a successful API response
+func writeAPIResponse(w http.ResponseWriter, status int, message string, data interface{}) {
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   message,
+		Data:      data,
+		Timestamp: time.Now().Unix(),
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
 }
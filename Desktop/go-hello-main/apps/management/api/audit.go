@@ -0,0 +1,241 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// managementServerID identifies audit events written by the management server
+const managementServerID = "management-server"
+
+// AuditLogger records structured audit events
+type AuditLogger interface {
+	Log(event shared.AuditEvent) error
+}
+
+// dbAuditLogger writes audit events to the audit_log table
+type dbAuditLogger struct {
+	db       *sql.DB
+	serverID string
+}
+
+// newDBAuditLogger creates an audit logger backed by audit_log
+func newDBAuditLogger(db *sql.DB, serverID string) *dbAuditLogger {
+	return &dbAuditLogger{db: db, serverID: serverID}
+}
+
+// Log stores an event, filling in the timestamp, outcome and server ID when unset
+// Events that cannot be stored are written to the process log so they are not lost
+func (l *dbAuditLogger) Log(event shared.AuditEvent) error {
+	completeAuditEvent(&event, l.serverID)
+
+	if err := l.insert(event); err != nil {
+		log.Printf("[AUDIT] Failed to store event (%v): %s actor=%s target=%s outcome=%s request=%s %s",
+			err, event.Action, event.Actor, event.Target, event.Outcome, event.RequestID, event.Details)
+		return err
+	}
+
+	return nil
+}
+
+// insert appends the event to the hash chain
+func (l *dbAuditLogger) insert(event shared.AuditEvent) error {
+	// Store the timestamp at database precision so the hash can be recomputed from the row
+	event.Timestamp = event.Timestamp.UTC().Truncate(time.Microsecond)
+
+	metadata, err := canonicalMetadata(event.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit metadata: %v", err)
+	}
+
+	tx, err := l.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Locking the head row serializes writers without locking audit_log itself
+	prevHash, err := auditChainHead(tx)
+	if err != nil {
+		return err
+	}
+
+	entryHash := auditEntryHash(prevHash, event, metadata)
+
+	var auditID int64
+	err = tx.QueryRow(`
+		INSERT INTO audit_log
+			(timestamp, action, username, actor, target, outcome, request_id, details, ip_address, server_id,
+			 metadata, prev_hash, entry_hash)
+		VALUES ($1, $2, $3, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`, event.Timestamp, event.Action, event.Actor, nullString(event.Target), event.Outcome,
+		nullString(event.RequestID), event.Details, event.IPAddress, event.ServerID, metadata,
+		nullString(prevHash), entryHash).Scan(&auditID)
+	if err != nil {
+		return err
+	}
+
+	if err := advanceAuditChainHead(tx, auditID, entryHash); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// completeAuditEvent fills in the timestamp, outcome and server ID when unset
+func completeAuditEvent(event *shared.AuditEvent, serverID string) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.Outcome == "" {
+		event.Outcome = auditOutcome(event.Action)
+	}
+	if event.ServerID == "" {
+		event.ServerID = serverID
+	}
+}
+
+// auditOutcome derives the outcome of a legacy event from its action name
+func auditOutcome(action string) string {
+	upper := strings.ToUpper(action)
+	switch {
+	case strings.HasSuffix(upper, "_REJECTED"), strings.HasSuffix(upper, "_DENIED"):
+		return shared.AuditOutcomeDenied
+	case strings.HasSuffix(upper, "_FAILED"), strings.HasSuffix(upper, "_FAILURE"):
+		return shared.AuditOutcomeFailure
+	default:
+		return shared.AuditOutcomeSuccess
+	}
+}
+
+// requestIDKey is the context key holding a request's ID
+type requestIDKey struct{}
+
+// validRequestID limits client-supplied request IDs to safe, bounded values
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// withRequestID attaches the client's X-Request-ID, or a new one, to the request and response
+func withRequestID(w http.ResponseWriter, r *http.Request) *http.Request {
+	id := r.Header.Get("X-Request-ID")
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+
+	w.Header().Set("X-Request-ID", id)
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+}
+
+// newRequestID returns a random 128-bit hex request ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID attached by withRequestID, if any
+func requestID(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// audit records a structured event, taking the request ID and client address from r
+func (api *ManagementAPI) audit(r *http.Request, event shared.AuditEvent) {
+	if r != nil {
+		if event.RequestID == "" {
+			event.RequestID = requestID(r)
+		}
+		if event.IPAddress == "" {
+			event.IPAddress = r.RemoteAddr
+		}
+	}
+
+	// Log reports storage failures itself
+	api.auditLogger.Log(event)
+}
+
+// subscribeAudit records bus events in the audit log
+// Handlers publish the event and this subscriber writes the audit entry, so the two cannot drift apart
+func subscribeAudit(bus *eventBus, logger AuditLogger) {
+	bus.Subscribe("audit", func(envelope shared.EventEnvelope) {
+		event, ok := auditEventFor(envelope.Event)
+		if !ok {
+			return
+		}
+		event.Timestamp = envelope.OccurredAt
+		event.RequestID = envelope.RequestID
+		if event.IPAddress == "" {
+			event.IPAddress = envelope.RemoteAddr
+		}
+
+		// Log reports storage failures itself
+		logger.Log(event)
+	})
+}
+
+// auditEventFor describes a bus event as an audit entry
+func auditEventFor(e shared.Event) (shared.AuditEvent, bool) {
+	switch e := e.(type) {
+	case shared.UserCreatedEvent:
+		if e.Source == "registration" {
+			return shared.AuditEvent{Action: "USER_REGISTERED", Actor: e.PhoneNumber, Details: "User registered successfully"}, true
+		}
+		return shared.AuditEvent{
+			Action:  "user_created",
+			Actor:   e.Username,
+			Details: fmt.Sprintf("User created with port %d, protocol %s", e.Port, e.Protocol),
+		}, true
+	case shared.UserDeletedEvent:
+		return shared.AuditEvent{Action: "user_deleted", Actor: e.Username, Details: "User deleted"}, true
+	case shared.LoginSucceededEvent:
+		return shared.AuditEvent{Action: "LOGIN_SUCCESS", Actor: e.PhoneNumber, Details: "User logged in successfully"}, true
+	case shared.LoginFailedEvent:
+		return shared.AuditEvent{Action: "LOGIN_FAILED", Actor: e.PhoneNumber, Details: e.Reason}, true
+	case shared.ProfileDownloadedEvent:
+		return shared.AuditEvent{
+			Action:  "PROFILE_DOWNLOADED",
+			Actor:   e.Username,
+			Details: fmt.Sprintf("OVPN profile downloaded from end-node %s", e.ServerID),
+		}, true
+	case shared.QuotaExceededEvent:
+		return shared.AuditEvent{
+			Action:  "QUOTA_EXCEEDED",
+			Actor:   e.Username,
+			Details: fmt.Sprintf("%s quota at 100%% - used=%d limit=%d", e.Metric, e.Used, e.Limit),
+		}, true
+	case shared.EndNodeOfflineEvent:
+		return shared.AuditEvent{
+			Action:   "ENDNODE_OFFLINE",
+			Actor:    "system",
+			Target:   e.ServerID,
+			ServerID: e.ServerID,
+			Details:  "End-node health changed to " + e.Status,
+			Metadata: map[string]interface{}{"previous_status": e.PreviousStatus, "status": e.Status},
+		}, true
+	}
+	return shared.AuditEvent{}, false
+}
+
+// auditRequest records an event performed by actor while serving r
+func (api *ManagementAPI) auditRequest(r *http.Request, action, actor, details string) {
+	api.audit(r, shared.AuditEvent{
+		Action:  action,
+		Actor:   actor,
+		Details: details,
+	})
+}
@@ -0,0 +1,463 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	defaultAuditForwardBufferDir = "/var/lib/vpnmanager/audit-forward"
+	defaultAuditForwardBatchSize = 100
+	defaultAuditForwardFlush     = 5 * time.Second
+	defaultAuditForwardQueueSize = 10000
+	defaultAuditForwardMaxBuffer = 64 << 20
+
+	auditForwardMinBackoff = 5 * time.Second
+	auditForwardMaxBackoff = 5 * time.Minute
+)
+
+// auditForwarder delivers batches of audit events to an external system
+type auditForwarder interface {
+	Name() string
+	Send(events []shared.AuditEvent) error
+}
+
+// forwardingAuditLogger stores events and then hands them to every forwarder
+type forwardingAuditLogger struct {
+	next     AuditLogger
+	serverID string
+	queues   []*auditForwardQueue
+}
+
+// newAuditLogger creates the audit logger used by the API: audit_log plus any
+// forwarders configured in the environment
+func newAuditLogger(db *sql.DB, serverID string) AuditLogger {
+	var logger AuditLogger = newDBAuditLogger(db, serverID)
+
+	if queues := auditForwardQueues(); len(queues) > 0 {
+		logger = &forwardingAuditLogger{next: logger, serverID: serverID, queues: queues}
+	}
+
+	return logger
+}
+
+// Log stores the event and queues it for forwarding
+// Events are forwarded even when storage fails, so the SIEM still sees them
+func (l *forwardingAuditLogger) Log(event shared.AuditEvent) error {
+	completeAuditEvent(&event, l.serverID)
+
+	err := l.next.Log(event)
+	for _, q := range l.queues {
+		q.enqueue(event)
+	}
+	return err
+}
+
+var (
+	auditForwardOnce    sync.Once
+	auditForwardQueuesV []*auditForwardQueue
+)
+
+// auditForwardQueues starts the configured forwarders once per process
+// Every audit logger shares them, so events from all handlers arrive in one stream
+func auditForwardQueues() []*auditForwardQueue {
+	auditForwardOnce.Do(func() {
+		forwarders, err := auditForwardersFromEnv()
+		if err != nil {
+			log.Printf("[AUDIT_FORWARD] Forwarding disabled: %v", err)
+			return
+		}
+
+		dir := os.Getenv("AUDIT_FORWARD_BUFFER_DIR")
+		if dir == "" {
+			dir = defaultAuditForwardBufferDir
+		}
+
+		for _, f := range forwarders {
+			q, err := newAuditForwardQueue(f, dir)
+			if err != nil {
+				log.Printf("[AUDIT_FORWARD] Forwarder %s disabled: %v", f.Name(), err)
+				continue
+			}
+			go q.run()
+			log.Printf("[AUDIT_FORWARD] Forwarding audit events to %s", f.Name())
+			auditForwardQueuesV = append(auditForwardQueuesV, q)
+		}
+	})
+
+	return auditForwardQueuesV
+}
+
+// auditForwardersFromEnv builds the forwarders enabled in the environment
+//
+//	AUDIT_SYSLOG_ADDR     udp://host:514, tcp://host:514 or tls://host:6514
+//	AUDIT_SYSLOG_FORMAT   rfc5424 (default) or cef
+//	AUDIT_SYSLOG_CA_FILE  PEM bundle trusted for tls:// (system roots when unset)
+//	AUDIT_HTTP_URL        endpoint receiving JSON arrays of events
+//	AUDIT_HTTP_TOKEN      bearer token sent to AUDIT_HTTP_URL
+func auditForwardersFromEnv() ([]auditForwarder, error) {
+	var forwarders []auditForwarder
+
+	if addr := os.Getenv("AUDIT_SYSLOG_ADDR"); addr != "" {
+		f, err := newSyslogForwarder(addr, os.Getenv("AUDIT_SYSLOG_FORMAT"), os.Getenv("AUDIT_SYSLOG_CA_FILE"))
+		if err != nil {
+			return nil, err
+		}
+		forwarders = append(forwarders, f)
+	}
+
+	if url := os.Getenv("AUDIT_HTTP_URL"); url != "" {
+		forwarders = append(forwarders, newHTTPAuditForwarder(url, os.Getenv("AUDIT_HTTP_TOKEN")))
+	}
+
+	return forwarders, nil
+}
+
+// auditForwardQueue batches events for one forwarder
+// Batches that cannot be delivered are spooled to disk and retried with
+// exponential backoff; while the spool holds events, new batches queue behind
+// them so the destination receives events in order. Events arriving while the
+// in-memory queue is full go straight to the spool.
+type auditForwardQueue struct {
+	forwarder     auditForwarder
+	events        chan shared.AuditEvent
+	batchSize     int
+	flushInterval time.Duration
+	spoolPath     string
+	maxSpool      int64
+
+	backoff time.Duration
+	retryAt time.Time
+
+	// spoolMu guards the spool file and spooled, written by run and by enqueue on overflow
+	spoolMu sync.Mutex
+	spooled bool
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// newAuditForwardQueue creates a queue spooling to dir
+func newAuditForwardQueue(f auditForwarder, dir string) (*auditForwardQueue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %v", err)
+	}
+
+	q := &auditForwardQueue{
+		forwarder:     f,
+		events:        make(chan shared.AuditEvent, defaultAuditForwardQueueSize),
+		batchSize:     defaultAuditForwardBatchSize,
+		flushInterval: defaultAuditForwardFlush,
+		spoolPath:     filepath.Join(dir, f.Name()+".ndjson"),
+		maxSpool:      defaultAuditForwardMaxBuffer,
+	}
+	if n, err := strconv.Atoi(os.Getenv("AUDIT_FORWARD_BATCH_SIZE")); err == nil && n > 0 {
+		q.batchSize = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("AUDIT_FORWARD_FLUSH_INTERVAL")); err == nil && d > 0 {
+		q.flushInterval = d
+	}
+
+	// Resume delivery of events spooled before a restart
+	if info, err := os.Stat(q.spoolPath); err == nil && info.Size() > 0 {
+		q.spooled = true
+	}
+
+	return q, nil
+}
+
+// enqueue hands an event to the queue without waiting for delivery
+// When the queue is full the event is appended to the spool instead, so a
+// burst is delivered late rather than lost; it is only dropped when the spool is full
+func (q *auditForwardQueue) enqueue(event shared.AuditEvent) {
+	select {
+	case q.events <- event:
+		return
+	default:
+	}
+
+	q.spoolMu.Lock()
+	defer q.spoolMu.Unlock()
+
+	if err := q.spool(q.spoolPath, []shared.AuditEvent{event}); err != nil {
+		q.mu.Lock()
+		q.dropped++
+		q.mu.Unlock()
+		return
+	}
+	q.spooled = true
+}
+
+// run batches queued events until the process exits
+func (q *auditForwardQueue) run() {
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	var batch []shared.AuditEvent
+	for {
+		select {
+		case event := <-q.events:
+			batch = append(batch, event)
+			if len(batch) >= q.batchSize {
+				q.flush(batch)
+				batch = nil
+			}
+
+		case <-ticker.C:
+			if len(batch) > 0 {
+				q.flush(batch)
+				batch = nil
+			}
+			q.retrySpool()
+			q.reportDropped()
+		}
+	}
+}
+
+// flush delivers a batch, spooling it when delivery fails or older events are still spooled
+func (q *auditForwardQueue) flush(batch []shared.AuditEvent) {
+	q.spoolMu.Lock()
+	spooled := q.spooled
+	q.spoolMu.Unlock()
+
+	if !spooled {
+		err := q.forwarder.Send(batch)
+		if err == nil {
+			return
+		}
+		log.Printf("[AUDIT_FORWARD] %s: delivery of %d events failed, spooling: %v", q.forwarder.Name(), len(batch), err)
+		q.scheduleRetry()
+	}
+
+	q.spoolMu.Lock()
+	defer q.spoolMu.Unlock()
+
+	if err := q.spool(q.spoolPath, batch); err != nil {
+		log.Printf("[AUDIT_FORWARD] %s: failed to spool %d events, dropping them: %v", q.forwarder.Name(), len(batch), err)
+		return
+	}
+	q.spooled = true
+}
+
+// spool appends events to an on-disk buffer
+func (q *auditForwardQueue) spool(path string, events []shared.AuditEvent) error {
+	if info, err := os.Stat(path); err == nil && info.Size() >= q.maxSpool {
+		return fmt.Errorf("buffer is full (%d bytes)", info.Size())
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return file.Sync()
+}
+
+// retrySpool delivers spooled events once the backoff has elapsed
+// The spool is not locked while sending; events that overflow into it
+// meanwhile are kept behind the ones still undelivered
+func (q *auditForwardQueue) retrySpool() {
+	q.spoolMu.Lock()
+	spooled := q.spooled
+	q.spoolMu.Unlock()
+
+	if !spooled || time.Now().Before(q.retryAt) {
+		return
+	}
+
+	q.spoolMu.Lock()
+	events, offset, err := q.readSpool(0)
+	q.spoolMu.Unlock()
+	if err != nil {
+		log.Printf("[AUDIT_FORWARD] %s: failed to read buffer: %v", q.forwarder.Name(), err)
+		q.scheduleRetry()
+		return
+	}
+
+	sent := 0
+	for sent < len(events) {
+		end := sent + q.batchSize
+		if end > len(events) {
+			end = len(events)
+		}
+		if err := q.forwarder.Send(events[sent:end]); err != nil {
+			log.Printf("[AUDIT_FORWARD] %s: retry failed with %d events buffered: %v", q.forwarder.Name(), len(events)-sent, err)
+			break
+		}
+		sent = end
+	}
+
+	q.spoolMu.Lock()
+	defer q.spoolMu.Unlock()
+
+	appended, _, err := q.readSpool(offset)
+	if err != nil {
+		log.Printf("[AUDIT_FORWARD] %s: failed to read buffer, events may be sent twice: %v", q.forwarder.Name(), err)
+		q.scheduleRetry()
+		return
+	}
+
+	if sent == len(events) {
+		q.backoff = 0
+		if sent > 0 {
+			log.Printf("[AUDIT_FORWARD] %s: delivered %d buffered events", q.forwarder.Name(), sent)
+		}
+	} else {
+		q.scheduleRetry()
+	}
+
+	remaining := append(events[sent:], appended...)
+	if len(remaining) == 0 {
+		os.Remove(q.spoolPath)
+		q.spooled = false
+		return
+	}
+
+	if sent > 0 {
+		if err := q.rewriteSpool(remaining); err != nil {
+			log.Printf("[AUDIT_FORWARD] %s: failed to rewrite buffer, events may be sent twice: %v", q.forwarder.Name(), err)
+		}
+	}
+}
+
+// readSpool loads the spooled events after offset, skipping lines that cannot be decoded
+// It returns the offset of the end of the spool; callers hold spoolMu
+func (q *auditForwardQueue) readSpool(offset int64) ([]shared.AuditEvent, int64, error) {
+	file, err := os.Open(q.spoolPath)
+	if os.IsNotExist(err) {
+		return nil, offset, nil
+	}
+	if err != nil {
+		return nil, offset, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, offset, err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+
+	var events []shared.AuditEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var event shared.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			log.Printf("[AUDIT_FORWARD] %s: skipping corrupt buffered event: %v", q.forwarder.Name(), err)
+			continue
+		}
+		events = append(events, event)
+	}
+
+	return events, info.Size(), scanner.Err()
+}
+
+// rewriteSpool atomically replaces the buffer with the undelivered events
+func (q *auditForwardQueue) rewriteSpool(events []shared.AuditEvent) error {
+	tmp := q.spoolPath + ".tmp"
+	os.Remove(tmp)
+
+	if err := q.spool(tmp, events); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, q.spoolPath)
+}
+
+// scheduleRetry doubles the backoff up to auditForwardMaxBackoff
+func (q *auditForwardQueue) scheduleRetry() {
+	switch {
+	case q.backoff == 0:
+		q.backoff = auditForwardMinBackoff
+	case q.backoff < auditForwardMaxBackoff:
+		q.backoff *= 2
+		if q.backoff > auditForwardMaxBackoff {
+			q.backoff = auditForwardMaxBackoff
+		}
+	}
+	q.retryAt = time.Now().Add(q.backoff)
+}
+
+// reportDropped logs events dropped because both the in-memory queue and the spool were full
+func (q *auditForwardQueue) reportDropped() {
+	q.mu.Lock()
+	dropped := q.dropped
+	q.dropped = 0
+	q.mu.Unlock()
+
+	if dropped > 0 {
+		log.Printf("[AUDIT_FORWARD] %s: queue and buffer full, dropped %d events", q.forwarder.Name(), dropped)
+	}
+}
+
+// httpAuditForwarder posts batches of events as a JSON array
+type httpAuditForwarder struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// newHTTPAuditForwarder creates a forwarder posting to url
+func newHTTPAuditForwarder(url, token string) *httpAuditForwarder {
+	return &httpAuditForwarder{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Name identifies the forwarder in logs and buffer file names
+func (f *httpAuditForwarder) Name() string {
+	return "http"
+}
+
+// Send posts the batch; any non-2xx response is a failure and the batch is retried
+func (f *httpAuditForwarder) Send(events []shared.AuditEvent) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return fmt.Errorf("failed to encode events: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", f.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if f.token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.token)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,335 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 1000
+
+	// auditExportFlushRows is how many exported rows are written between flushes
+	auditExportFlushRows = 500
+
+	// auditExportTimeout replaces the server write timeout for streamed exports
+	auditExportTimeout = 10 * time.Minute
+)
+
+// Audit log output formats
+const (
+	auditFormatJSON   = "json"
+	auditFormatNDJSON = "ndjson"
+	auditFormatCSV    = "csv"
+)
+
+// auditCSVColumns is the header row of CSV exports
+var auditCSVColumns = []string{
+	"id", "timestamp", "action", "actor", "target", "outcome", "request_id",
+	"ip_address", "server_id", "details", "metadata", "entry_hash",
+}
+
+// auditFilter selects audit events; zero values are not filtered on
+type auditFilter struct {
+	Actions   []string
+	Actor     string
+	Target    string
+	Username  string // matches actor or target
+	ServerID  string
+	IPAddress string
+	Outcome   string
+	From      time.Time
+	To        time.Time
+	Search    string
+	Cursor    *pageCursor
+	Limit     int // 0 means no limit
+
+	// IncludeEndNodes merges end-node entries in, with a cursor per source
+	IncludeEndNodes bool
+	SourceCursors   map[string]*pageCursor
+}
+
+// parseAuditFilter reads action, actor, target, username, server_id, ip, outcome,
+// from, to, q, include_endnodes, cursor and limit query parameters
+// action accepts a comma-separated list. Exports default to no limit.
+func parseAuditFilter(r *http.Request, export bool) (*auditFilter, error) {
+	query := r.URL.Query()
+
+	filter := &auditFilter{
+		Actor:     query.Get("actor"),
+		Target:    query.Get("target"),
+		Username:  query.Get("username"),
+		ServerID:  query.Get("server_id"),
+		IPAddress: query.Get("ip"),
+		Outcome:   query.Get("outcome"),
+		Search:    strings.TrimSpace(query.Get("q")),
+
+		IncludeEndNodes: query.Get("include_endnodes") == "true",
+	}
+	if !export {
+		filter.Limit = defaultAuditPageSize
+	}
+
+	for _, action := range strings.Split(query.Get("action"), ",") {
+		if action = strings.TrimSpace(action); action != "" {
+			filter.Actions = append(filter.Actions, action)
+		}
+	}
+
+	switch filter.Outcome {
+	case "", shared.AuditOutcomeSuccess, shared.AuditOutcomeFailure, shared.AuditOutcomeDenied:
+	default:
+		return nil, fmt.Errorf("outcome must be one of: success, failure, denied")
+	}
+
+	if v := query.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %v", err)
+		}
+		filter.From = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to: %v", err)
+		}
+		filter.To = t
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+
+	if v := query.Get("cursor"); v != "" && filter.IncludeEndNodes {
+		cursors, err := decodeMergedCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		filter.SourceCursors = cursors
+	} else if v != "" {
+		cursor, err := decodePageCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		filter.Cursor = cursor
+	}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxAuditPageSize)
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+// where builds the WHERE clause and arguments for the filter
+// actor is the column expression holding the actor, which differs between audit tables
+func (f *auditFilter) where(actor string) (string, []interface{}) {
+	conditions := []string{"1=1"}
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.ReplaceAll(condition, "$?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if len(f.Actions) == 1 {
+		add("action = $?", f.Actions[0])
+	} else if len(f.Actions) > 1 {
+		placeholders := make([]string, len(f.Actions))
+		for i, action := range f.Actions {
+			args = append(args, action)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, "action IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if f.Actor != "" {
+		add(actor+" = $?", f.Actor)
+	}
+	if f.Target != "" {
+		add("target = $?", f.Target)
+	}
+	if f.Username != "" {
+		add("("+actor+" = $? OR target = $?)", f.Username)
+	}
+	if f.ServerID != "" {
+		add("server_id = $?", f.ServerID)
+	}
+	if f.IPAddress != "" {
+		// Addresses are stored as host:port, or [host]:port for IPv6
+		add("(ip_address = $? OR ip_address LIKE $? || ':%' OR ip_address LIKE '[' || $? || ']:%')", f.IPAddress)
+	}
+	if f.Outcome != "" {
+		add("outcome = $?", f.Outcome)
+	}
+	if !f.From.IsZero() {
+		add("timestamp >= $?", f.From)
+	}
+	if !f.To.IsZero() {
+		add("timestamp < $?", f.To)
+	}
+	if f.Search != "" {
+		add("to_tsvector('simple', COALESCE(details, '')) @@ plainto_tsquery('simple', $?)", f.Search)
+	}
+	if f.Cursor != nil {
+		args = append(args, f.Cursor.At, f.Cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(timestamp, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// streamAuditEvents calls fn for each matching event, newest first
+// Rows beyond limit are not read when limit is positive
+func (api *ManagementAPI) streamAuditEvents(filter *auditFilter, limit int, fn func(shared.AuditEvent) error) error {
+	return streamAuditLog(api.manager.GetDB().GetConnection(), filter, limit, fn)
+}
+
+// streamAuditLog reads matching audit_log events for streamAuditEvents
+func streamAuditLog(conn *sql.DB, filter *auditFilter, limit int, fn func(shared.AuditEvent) error) error {
+	where, args := filter.where("COALESCE(actor, username)")
+	query := `
+		SELECT id, timestamp, action, COALESCE(actor, username, ''), COALESCE(target, ''), outcome,
+		       COALESCE(request_id, ''), COALESCE(details, ''), COALESCE(ip_address, ''),
+		       COALESCE(server_id, ''), metadata, COALESCE(prev_hash, ''), COALESCE(entry_hash, '')
+		FROM audit_log
+		` + where + `
+		ORDER BY timestamp DESC, id DESC`
+	if limit > 0 {
+		args = append(args, limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event shared.AuditEvent
+		var metadata []byte
+		err := rows.Scan(&event.ID, &event.Timestamp, &event.Action, &event.Actor, &event.Target,
+			&event.Outcome, &event.RequestID, &event.Details, &event.IPAddress, &event.ServerID, &metadata,
+			&event.PrevHash, &event.EntryHash)
+		if err != nil {
+			return err
+		}
+		if len(metadata) > 0 {
+			json.Unmarshal(metadata, &event.Metadata)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// queryAuditEvents returns one page of matching audit events
+func (api *ManagementAPI) queryAuditEvents(filter *auditFilter) (*shared.AuditLogPage, error) {
+	page := &shared.AuditLogPage{Events: []shared.AuditEvent{}}
+
+	// Fetch one extra row to learn whether another page exists
+	err := api.streamAuditEvents(filter, filter.Limit+1, func(event shared.AuditEvent) error {
+		page.Events = append(page.Events, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Events) > filter.Limit {
+		page.Events = page.Events[:filter.Limit]
+		last := page.Events[len(page.Events)-1]
+		page.NextCursor = pageCursor{At: last.Timestamp, ID: last.ID}.encode()
+	}
+
+	return page, nil
+}
+
+// exportAuditEvents streams matching events as NDJSON or CSV
+// Rows are written as they are read, so exports of any size use constant memory
+func (api *ManagementAPI) exportAuditEvents(w http.ResponseWriter, r *http.Request, filter *auditFilter, format string) {
+	// Large exports outlive the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(auditExportTimeout))
+
+	filename := fmt.Sprintf("audit-log-%s.%s", time.Now().UTC().Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	flusher, _ := w.(http.Flusher)
+	flush := func(n int) {
+		if flusher != nil && n%auditExportFlushRows == 0 {
+			flusher.Flush()
+		}
+	}
+
+	count := 0
+	var err error
+	switch format {
+	case auditFormatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(w)
+		err = api.streamAuditEvents(filter, filter.Limit, func(event shared.AuditEvent) error {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+			count++
+			flush(count)
+			return nil
+		})
+
+	case auditFormatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+		writer := csv.NewWriter(w)
+		writer.Write(auditCSVColumns)
+		err = api.streamAuditEvents(filter, filter.Limit, func(event shared.AuditEvent) error {
+			metadata := ""
+			if len(event.Metadata) > 0 {
+				data, _ := json.Marshal(event.Metadata)
+				metadata = string(data)
+			}
+			writer.Write([]string{
+				strconv.FormatInt(event.ID, 10),
+				event.Timestamp.UTC().Format(time.RFC3339Nano),
+				event.Action,
+				event.Actor,
+				event.Target,
+				event.Outcome,
+				event.RequestID,
+				event.IPAddress,
+				event.ServerID,
+				event.Details,
+				metadata,
+				event.EntryHash,
+			})
+			count++
+			if count%auditExportFlushRows == 0 {
+				writer.Flush()
+				flush(count)
+			}
+			return writer.Error()
+		})
+		writer.Flush()
+	}
+
+	// Headers are already sent, so a failure can only truncate the export
+	if err != nil {
+		log.Printf("[AUDIT] Export failed after %d rows: %v", count, err)
+		return
+	}
+
+	api.auditRequest(r, "AUDIT_LOG_EXPORTED", "", fmt.Sprintf("Exported %d audit events as %s - %s", count, format, r.URL.RawQuery))
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	syslogAppName = "vpnmanager"
+
+	// syslogFacilityAuthPriv is the security/authorization facility (RFC 5424 code 10)
+	syslogFacilityAuthPriv = 10
+
+	// syslogEnterpriseID qualifies the structured data ID; 32473 is reserved for documentation
+	syslogEnterpriseID = "32473"
+
+	cefVendor  = "vpnmanager"
+	cefProduct = "management-server"
+	cefVersion = "1.0"
+)
+
+// Syslog message formats
+const (
+	syslogFormatRFC5424 = "rfc5424"
+	syslogFormatCEF     = "cef"
+)
+
+// syslogForwarder sends audit events as RFC 5424 syslog messages over UDP, TCP or TLS
+// TCP and TLS use octet-counting framing (RFC 6587, RFC 5425)
+type syslogForwarder struct {
+	network   string // udp, tcp or tls
+	address   string
+	format    string
+	tlsConfig *tls.Config
+	hostname  string
+	conn      net.Conn
+}
+
+// newSyslogForwarder parses a udp://, tcp:// or tls:// address
+func newSyslogForwarder(addr, format, caFile string) (*syslogForwarder, error) {
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid AUDIT_SYSLOG_ADDR %q: expected udp://, tcp:// or tls://host:port", addr)
+	}
+
+	f := &syslogForwarder{network: u.Scheme, address: u.Host, format: format}
+	if f.format == "" {
+		f.format = syslogFormatRFC5424
+	}
+	if f.format != syslogFormatRFC5424 && f.format != syslogFormatCEF {
+		return nil, fmt.Errorf("AUDIT_SYSLOG_FORMAT must be one of: rfc5424, cef")
+	}
+
+	switch f.network {
+	case "udp", "tcp":
+	case "tls":
+		f.tlsConfig = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+		if caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read AUDIT_SYSLOG_CA_FILE: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("AUDIT_SYSLOG_CA_FILE contains no certificates")
+			}
+			f.tlsConfig.RootCAs = pool
+		}
+	default:
+		return nil, fmt.Errorf("invalid AUDIT_SYSLOG_ADDR %q: scheme must be udp, tcp or tls", addr)
+	}
+
+	f.hostname, _ = os.Hostname()
+	if f.hostname == "" {
+		f.hostname = "-"
+	}
+
+	return f, nil
+}
+
+// Name identifies the forwarder in logs and buffer file names
+func (f *syslogForwarder) Name() string {
+	return "syslog-" + f.format
+}
+
+// Send writes each event as one syslog message, reconnecting after a failure
+func (f *syslogForwarder) Send(events []shared.AuditEvent) error {
+	if f.conn == nil {
+		if err := f.connect(); err != nil {
+			return err
+		}
+	}
+
+	f.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	for _, event := range events {
+		msg := f.message(event)
+		if f.network != "udp" {
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		if _, err := f.conn.Write([]byte(msg)); err != nil {
+			f.conn.Close()
+			f.conn = nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connect dials the syslog receiver
+func (f *syslogForwarder) connect() error {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+
+	var err error
+	if f.network == "tls" {
+		f.conn, err = tls.DialWithDialer(dialer, "tcp", f.address, f.tlsConfig)
+	} else {
+		f.conn, err = dialer.Dial(f.network, f.address)
+	}
+	return err
+}
+
+// message renders an event in the configured format
+func (f *syslogForwarder) message(event shared.AuditEvent) string {
+	if f.format == syslogFormatCEF {
+		return formatSyslog(event, f.hostname, "-", formatCEF(event))
+	}
+
+	sd := fmt.Sprintf(`[audit@%s actor="%s" target="%s" outcome="%s" requestId="%s" ip="%s" serverId="%s"]`,
+		syslogEnterpriseID,
+		escapeSDParam(event.Actor), escapeSDParam(event.Target), escapeSDParam(event.Outcome),
+		escapeSDParam(event.RequestID), escapeSDParam(event.IPAddress), escapeSDParam(event.ServerID))
+	return formatSyslog(event, f.hostname, sd, event.Details)
+}
+
+// formatSyslog builds an RFC 5424 message: <PRI>1 TIMESTAMP HOST APP PROCID MSGID SD MSG
+func formatSyslog(event shared.AuditEvent, hostname, sd, msg string) string {
+	pri := syslogFacilityAuthPriv*8 + syslogSeverity(event.Outcome)
+
+	line := fmt.Sprintf("<%d>1 %s %s %s %d %s %s",
+		pri,
+		event.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(hostname, 255),
+		syslogAppName,
+		os.Getpid(),
+		syslogHeaderField(event.Action, 32),
+		sd)
+	if msg != "" {
+		line += " " + msg
+	}
+	return line
+}
+
+// syslogSeverity maps an outcome to a syslog severity
+func syslogSeverity(outcome string) int {
+	switch outcome {
+	case shared.AuditOutcomeFailure:
+		return 3 // error
+	case shared.AuditOutcomeDenied:
+		return 4 // warning
+	default:
+		return 6 // informational
+	}
+}
+
+// syslogHeaderField restricts a header field to printable ASCII without spaces
+func syslogHeaderField(s string, max int) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 32 && r < 127 {
+			b.WriteRune(r)
+		}
+		if b.Len() == max {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "-"
+	}
+	return b.String()
+}
+
+// escapeSDParam escapes a structured data parameter value
+func escapeSDParam(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// formatCEF renders an event as an ArcSight Common Event Format record
+func formatCEF(event shared.AuditEvent) string {
+	header := strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	ext := strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+	host := event.IPAddress
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	requestIDLabel := ""
+	if event.RequestID != "" {
+		requestIDLabel = "requestId"
+	}
+
+	fields := []struct{ key, value string }{
+		{"rt", strconv.FormatInt(event.Timestamp.UnixMilli(), 10)},
+		{"suser", event.Actor},
+		{"duser", event.Target},
+		{"src", host},
+		{"outcome", event.Outcome},
+		{"dvchost", event.ServerID},
+		{"cs1Label", requestIDLabel},
+		{"cs1", event.RequestID},
+		{"msg", event.Details},
+	}
+
+	var extension []string
+	for _, field := range fields {
+		if field.value != "" {
+			extension = append(extension, field.key+"="+ext.Replace(field.value))
+		}
+	}
+
+	return fmt.Sprintf("CEF:0|%s|%s|%s|%s|%s|%d|%s",
+		header.Replace(cefVendor),
+		header.Replace(cefProduct),
+		header.Replace(cefVersion),
+		header.Replace(event.Action),
+		header.Replace(strings.ReplaceAll(strings.ToLower(event.Action), "_", " ")),
+		cefSeverity(event.Outcome),
+		strings.Join(extension, " "))
+}
+
+// cefSeverity maps an outcome to a CEF severity (0-10)
+func cefSeverity(outcome string) int {
+	switch outcome {
+	case shared.AuditOutcomeFailure:
+		return 7
+	case shared.AuditOutcomeDenied:
+		return 6
+	default:
+		return 3
+	}
+}
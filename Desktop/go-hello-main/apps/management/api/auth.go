@@ -75,13 +75,17 @@ func (m *MockOTPService) GenerateOTP() string {
 type AuthHandler struct {
 	db         *sql.DB
 	otpService OTPService
+	audit      AuditLogger
 }
 
 // NewAuthHandler creates a new authentication handler
 func NewAuthHandler(db *sql.DB, otpService OTPService) *AuthHandler {
+	startEventBus(db)
+
 	return &AuthHandler{
 		db:         db,
 		otpService: otpService,
+		audit:      newAuditLogger(db, managementServerID),
 	}
 }
 
@@ -167,14 +171,8 @@ func (h *AuthHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insert new user
-	var userID int
-	err = h.db.QueryRow(`
-		INSERT INTO auth_users (phone_number, password_hash, created_at, last_login, active)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id
-	`, req.PhoneNumber, string(hashedPassword), time.Now(), time.Now(), true).Scan(&userID)
-
+	// Insert new user, with its event in the outbox so the two commit together
+	userID, err := h.createAuthUser(r, req.PhoneNumber, string(hashedPassword))
 	if err != nil {
 		log.Printf("[AUTH] Failed to create user: %v", err)
 		h.sendError(w, "Failed to create user", http.StatusInternalServerError)
@@ -189,9 +187,6 @@ func (h *AuthHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Log successful registration
-	h.logAuditEvent("USER_REGISTERED", req.PhoneNumber, "User registered successfully", r.RemoteAddr)
-
 	// Send success response
 	response := AuthResponse{
 		Success: true,
@@ -209,6 +204,37 @@ func (h *AuthHandler) HandleRegister(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// createAuthUser inserts an active auth user and queues its user.created event
+// The audit subscriber records the registration when the event is relayed
+func (h *AuthHandler) createAuthUser(r *http.Request, phoneNumber, passwordHash string) (int, error) {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`
+		INSERT INTO auth_users (phone_number, password_hash, created_at, last_login, active)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, phoneNumber, passwordHash, time.Now(), time.Now(), true).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+
+	err = defaultEventBus.PublishTx(tx, r, shared.UserCreatedEvent{
+		UserID:      userID,
+		PhoneNumber: phoneNumber,
+		Source:      "registration",
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
 // HandleLogin handles user login
 // POST /auth/login
 func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
@@ -242,7 +268,7 @@ func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	if err == sql.ErrNoRows {
 		// Use generic error message to prevent user enumeration
 		h.sendError(w, "Invalid phone number or password", http.StatusUnauthorized)
-		h.logAuditEvent("LOGIN_FAILED", req.PhoneNumber, "Invalid credentials", r.RemoteAddr)
+		h.loginFailed(r, req.PhoneNumber, "Invalid credentials")
 		return
 	} else if err != nil {
 		log.Printf("[AUTH] Database error during login: %v", err)
@@ -253,7 +279,7 @@ func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	// Check if account is active
 	if !active {
 		h.sendError(w, "Account is disabled", http.StatusForbidden)
-		h.logAuditEvent("LOGIN_FAILED", req.PhoneNumber, "Account disabled", r.RemoteAddr)
+		h.loginFailed(r, req.PhoneNumber, "Account disabled")
 		return
 	}
 
@@ -262,7 +288,7 @@ func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		// Invalid password
 		h.sendError(w, "Invalid phone number or password", http.StatusUnauthorized)
-		h.logAuditEvent("LOGIN_FAILED", req.PhoneNumber, "Invalid password", r.RemoteAddr)
+		h.loginFailed(r, req.PhoneNumber, "Invalid password")
 		return
 	}
 
@@ -281,8 +307,12 @@ func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Log successful login
-	h.logAuditEvent("LOGIN_SUCCESS", req.PhoneNumber, "User logged in successfully", r.RemoteAddr)
+	// Published for the audit log, metrics and webhooks
+	publishEvent(r, shared.LoginSucceededEvent{
+		UserID:      userID,
+		PhoneNumber: req.PhoneNumber,
+		IPAddress:   eventIP(r),
+	})
 
 	// Send success response
 	response := AuthResponse{
@@ -300,6 +330,15 @@ func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// loginFailed publishes a rejected login, which the audit subscriber records
+func (h *AuthHandler) loginFailed(r *http.Request, phoneNumber, reason string) {
+	publishEvent(r, shared.LoginFailedEvent{
+		PhoneNumber: phoneNumber,
+		Reason:      reason,
+		IPAddress:   eventIP(r),
+	})
+}
+
 // HandleRefresh handles JWT token refresh
 // POST /auth/refresh
 func (h *AuthHandler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
@@ -328,7 +367,7 @@ func (h *AuthHandler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
 
 	// Extract phone number for logging (optional)
 	phoneNumber, _ := shared.ExtractPhoneNumber(newToken)
-	h.logAuditEvent("TOKEN_REFRESHED", phoneNumber, "JWT token refreshed", r.RemoteAddr)
+	h.logAuditEvent(r, "TOKEN_REFRESHED", phoneNumber, "JWT token refreshed")
 
 	// Send success response
 	response := AuthResponse{
@@ -378,7 +417,7 @@ func (h *AuthHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Log logout event
-	h.logAuditEvent("USER_LOGOUT", claims.PhoneNumber, "User logged out", r.RemoteAddr)
+	h.logAuditEvent(r, "USER_LOGOUT", claims.PhoneNumber, "User logged out")
 
 	// Send success response
 	response := AuthResponse{
@@ -417,15 +456,17 @@ func (h *AuthHandler) HandleSendOTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send OTP
-	otp, err := h.otpService.SendOTP(req.PhoneNumber)
+	_, err := h.otpService.SendOTP(req.PhoneNumber)
 	if err != nil {
+		otpSendsTotal.WithLabelValues("failure").Inc()
 		log.Printf("[AUTH] Failed to send OTP: %v", err)
 		h.sendError(w, "Failed to send OTP", http.StatusInternalServerError)
 		return
 	}
 
 	// Log OTP sent event
-	h.logAuditEvent("OTP_SENT", req.PhoneNumber, "OTP sent to phone number", r.RemoteAddr)
+	otpSendsTotal.WithLabelValues("success").Inc()
+	h.logAuditEvent(r, "OTP_SENT", req.PhoneNumber, "OTP sent to phone number")
 
 	// Send success response
 	// SECURITY FIX: NEVER send OTP in API response - it defeats the purpose of OTP verification
@@ -545,13 +586,13 @@ func (h *AuthHandler) sendError(w http.ResponseWriter, message string, statusCod
 }
 
 // logAuditEvent logs an authentication event to the audit log
-func (h *AuthHandler) logAuditEvent(action, username, details, ipAddress string) {
-	_, err := h.db.Exec(`
-		INSERT INTO audit_log (timestamp, action, username, details, ip_address, server_id)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`, time.Now(), action, username, details, ipAddress, "management-server")
-
-	if err != nil {
-		log.Printf("[AUTH] Failed to log audit event: %v", err)
-	}
+func (h *AuthHandler) logAuditEvent(r *http.Request, action, username, details string) {
+	// Log reports storage failures itself
+	h.audit.Log(shared.AuditEvent{
+		Action:    action,
+		Actor:     username,
+		Details:   details,
+		RequestID: requestID(r),
+		IPAddress: r.RemoteAddr,
+	})
 }
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	defaultConnectionPageSize = 50
+	maxConnectionPageSize     = 500
+)
+
+// connectionFilter selects a page of connection history
+// Zero values mean no filtering on that field
+type connectionFilter struct {
+	Username string
+	ServerID string
+	Status   string
+	From     time.Time
+	To       time.Time
+	Cursor   *pageCursor
+	Limit    int
+}
+
+// pageCursor is the position after the last row of a page, ordered by (timestamp, id) descending
+type pageCursor struct {
+	At time.Time
+	ID int64
+}
+
+// encode returns the opaque cursor string handed to clients
+func (c pageCursor) encode() string {
+	raw := fmt.Sprintf("%d:%d", c.At.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodePageCursor parses a cursor produced by pageCursor.encode
+func decodePageCursor(s string) (*pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	// TIMESTAMP columns scan as UTC; decoding in the local zone would shift
+	// the boundary by the server's offset when the cursor is bound as a parameter
+	return &pageCursor{At: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// parseConnectionFilter reads server_id, status, from, to, cursor and limit query parameters
+func parseConnectionFilter(r *http.Request) (*connectionFilter, error) {
+	query := r.URL.Query()
+
+	filter := &connectionFilter{
+		ServerID: query.Get("server_id"),
+		Status:   query.Get("status"),
+		Limit:    defaultConnectionPageSize,
+	}
+
+	if filter.Status != "" && filter.Status != "active" && !isSessionState(filter.Status) {
+		return nil, fmt.Errorf("status must be one of: active, connecting, connected, disconnected, error")
+	}
+
+	if v := query.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %v", err)
+		}
+		filter.From = t
+	}
+	if v := query.Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to: %v", err)
+		}
+		filter.To = t
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := decodePageCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		filter.Cursor = cursor
+	}
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxConnectionPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxConnectionPageSize)
+		}
+		filter.Limit = n
+	}
+
+	return filter, nil
+}
+
+// isSessionState reports whether s is a known session state
+func isSessionState(s string) bool {
+	switch s {
+	case shared.SessionStateConnecting, shared.SessionStateConnected,
+		shared.SessionStateDisconnected, shared.SessionStateError:
+		return true
+	}
+	return false
+}
+
+// where builds the WHERE clause and arguments for the filter
+// The cursor is only applied when withCursor is set, so totals cover all pages
+func (f *connectionFilter) where(withCursor bool) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.Username != "" {
+		add("username = $%d", f.Username)
+	}
+	if f.ServerID != "" {
+		add("server_id = $%d", f.ServerID)
+	}
+	if f.Status == "active" {
+		conditions = append(conditions, "disconnected_at IS NULL")
+	} else if f.Status != "" {
+		add("status = $%d", f.Status)
+	}
+	if !f.From.IsZero() {
+		add("created_at >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("created_at < $%d", f.To)
+	}
+	if withCursor && f.Cursor != nil {
+		args = append(args, f.Cursor.At, f.Cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// handleConnections lists connection history across all users
+// GET /api/connections?username=&server_id=&status=&from=&to=&cursor=&limit=
+func (api *ManagementAPI) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := api.requireAdmin(w, r); !ok {
+		return
+	}
+
+	filter, err := parseConnectionFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Username = r.URL.Query().Get("username")
+
+	page, err := api.getConnectionHistory(filter)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list connections: %v", err)
+		http.Error(w, "Failed to retrieve connections", http.StatusInternalServerError)
+		return
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Retrieved %d of %d connections", len(page.Connections), page.Total),
+		Data:      page,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	json.NewEncoder(w).Encode(response)
+}
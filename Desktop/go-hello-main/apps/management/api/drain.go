@@ -0,0 +1,495 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// errDrainInProgress is returned when a server already has a running drain
+var errDrainInProgress = fmt.Errorf("drain already in progress")
+
+// drainUser is a user assigned to a draining end-node
+type drainUser struct {
+	Username string
+	Port     int
+	Protocol string
+}
+
+// drainTarget is a candidate end-node for users migrated off a draining node
+type drainTarget struct {
+	server    *shared.Server
+	userCount int
+	capacity  int
+}
+
+// handleEndNodeDrain handles drain requests for an end-node
+// POST /api/endnodes/{server_id}/drain - start (or resume) draining
+// GET  /api/endnodes/{server_id}/drain - report drain progress
+func (api *ManagementAPI) handleEndNodeDrain(w http.ResponseWriter, r *http.Request, serverID string) {
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		api.handleGetEndNodeDrain(w, r, serverID)
+	case "POST":
+		api.handleStartEndNodeDrain(w, r, serverID, admin)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleStartEndNodeDrain marks an end-node as draining and starts migrating its users
+func (api *ManagementAPI) handleStartEndNodeDrain(w http.ResponseWriter, r *http.Request, serverID, admin string) {
+	if _, err := api.findEndNode(serverID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	drain, err := api.startDrain(serverID)
+	if err == errDrainInProgress {
+		http.Error(w, fmt.Sprintf("End-node '%s' is already being drained", serverID), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[DRAIN] Failed to start drain for %s: %v", serverID, err)
+		http.Error(w, "Failed to start drain", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(
+		r,
+		"ENDNODE_DRAIN_STARTED",
+		admin,
+		fmt.Sprintf("Drain started for end-node %s - users=%d", serverID, drain.TotalUsers),
+	)
+
+	go api.runDrain(drain)
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Drain started for end-node '%s'", serverID),
+		Data:      drain,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetEndNodeDrain returns the most recent drain for an end-node
+func (api *ManagementAPI) handleGetEndNodeDrain(w http.ResponseWriter, r *http.Request, serverID string) {
+	drain, err := api.getLatestDrain(serverID)
+	if err == sql.ErrNoRows {
+		http.Error(w, fmt.Sprintf("No drain found for end-node '%s'", serverID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[DRAIN] Failed to load drain for %s: %v", serverID, err)
+		http.Error(w, "Failed to retrieve drain status", http.StatusInternalServerError)
+		return
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Drain status retrieved successfully",
+		Data:      drain,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// startDrain flags the server as draining and records a new drain run
+func (api *ManagementAPI) startDrain(serverID string) (*shared.EndNodeDrain, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var running int
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM server_drains WHERE server_id = $1 AND status = $2
+	`, serverID, shared.DrainStatusRunning).Scan(&running)
+	if err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, errDrainInProgress
+	}
+
+	if _, err := tx.Exec("UPDATE servers SET draining = true WHERE name = $1", serverID); err != nil {
+		return nil, err
+	}
+
+	drain := &shared.EndNodeDrain{
+		ServerID:  serverID,
+		Status:    shared.DrainStatusRunning,
+		StartedAt: time.Now(),
+	}
+
+	err = tx.QueryRow(`
+		SELECT COUNT(*) FROM users WHERE server_id = $1 AND active = true
+	`, serverID).Scan(&drain.TotalUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO server_drains (server_id, status, total_users, started_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, serverID, drain.Status, drain.TotalUsers, drain.StartedAt).Scan(&drain.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return drain, tx.Commit()
+}
+
+// resumeInterruptedDrains restarts drains left running by a previous process
+// runDrain only sees users still on the node, so a resumed drain picks up where it stopped
+func (api *ManagementAPI) resumeInterruptedDrains() {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(`
+		SELECT id, server_id, total_users, migrated_users, failed_users, started_at
+		FROM server_drains
+		WHERE status = $1
+	`, shared.DrainStatusRunning)
+	if err != nil {
+		log.Printf("[DRAIN] Failed to load interrupted drains: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	var drains []*shared.EndNodeDrain
+	for rows.Next() {
+		drain := &shared.EndNodeDrain{Status: shared.DrainStatusRunning}
+		if err := rows.Scan(&drain.ID, &drain.ServerID, &drain.TotalUsers,
+			&drain.MigratedUsers, &drain.FailedUsers, &drain.StartedAt); err != nil {
+			log.Printf("[DRAIN] Failed to read interrupted drain: %v", err)
+			return
+		}
+		drains = append(drains, drain)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("[DRAIN] Failed to load interrupted drains: %v", err)
+		return
+	}
+
+	for _, drain := range drains {
+		log.Printf("[DRAIN] Resuming interrupted drain %d of end-node %s", drain.ID, drain.ServerID)
+		go api.runDrain(drain)
+	}
+}
+
+// runDrain migrates every user off the draining node and deregisters it once empty
+func (api *ManagementAPI) runDrain(drain *shared.EndNodeDrain) {
+	source, err := api.findEndNode(drain.ServerID)
+	if err != nil {
+		api.finishDrain(drain, err)
+		return
+	}
+
+	users, err := api.getDrainUsers(drain.ServerID)
+	if err != nil {
+		api.finishDrain(drain, fmt.Errorf("failed to list users: %v", err))
+		return
+	}
+
+	targets, err := api.getDrainTargets(drain.ServerID)
+	if err != nil {
+		api.finishDrain(drain, fmt.Errorf("failed to list target servers: %v", err))
+		return
+	}
+
+	// A resumed drain retries everyone still on the node, so only this run's failures count
+	drain.FailedUsers = 0
+
+	var lastErr error
+	for _, user := range users {
+		target := pickDrainTarget(targets)
+		if target == nil {
+			lastErr = fmt.Errorf("no capacity left in location for user %s", user.Username)
+			drain.FailedUsers++
+			api.updateDrainProgress(drain, lastErr)
+			continue
+		}
+
+		if err := api.migrateUser(user, source, target.server); err != nil {
+			log.Printf("[DRAIN] Failed to migrate %s from %s to %s: %v", user.Username, source.Name, target.server.Name, err)
+			lastErr = err
+			drain.FailedUsers++
+		} else {
+			target.userCount++
+			drain.MigratedUsers++
+		}
+
+		api.updateDrainProgress(drain, lastErr)
+	}
+
+	// Decide on what is left on the node rather than on the counters
+	remaining, err := api.getDrainUsers(drain.ServerID)
+	if err != nil {
+		api.finishDrain(drain, fmt.Errorf("failed to recheck users: %v", err))
+		return
+	}
+	if len(remaining) > 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("users were assigned to the node during the drain")
+		}
+		api.finishDrain(drain, fmt.Errorf("%d users could not be migrated: %v", len(remaining), lastErr))
+		return
+	}
+
+	// Deregister only after every user has a profile on another node
+	if err := api.manager.RemoveEndNode(drain.ServerID); err != nil {
+		api.finishDrain(drain, fmt.Errorf("users migrated but deregistration failed: %v", err))
+		return
+	}
+
+	api.finishDrain(drain, nil)
+}
+
+// migrateUser issues a profile on the target node, reassigns the user and revokes the old profile
+func (api *ManagementAPI) migrateUser(user drainUser, source, target *shared.Server) error {
+	if err := api.createUserOnEndNode(target, user.Username, user.Port, user.Protocol); err != nil {
+		return err
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	_, err := conn.Exec(`
+		UPDATE users SET server_id = $1 WHERE username = $2 AND server_id = $3
+	`, target.Name, user.Username, source.Name)
+	if err != nil {
+		return fmt.Errorf("failed to reassign user: %v", err)
+	}
+
+	// The user is already served by the target; a stale profile on the old node is harmless
+	if err := api.deleteUserOnEndNode(source, user.Username); err != nil {
+		log.Printf("[DRAIN] Failed to remove %s from %s: %v", user.Username, source.Name, err)
+	}
+
+	api.logAudit(
+		"USER_MIGRATED",
+		user.Username,
+		fmt.Sprintf("User migrated from end-node %s to %s", source.Name, target.Name),
+		"",
+	)
+
+	return nil
+}
+
+// getDrainUsers returns the active users still assigned to a server
+func (api *ManagementAPI) getDrainUsers(serverID string) ([]drainUser, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(`
+		SELECT username, port, protocol
+		FROM users
+		WHERE server_id = $1 AND active = true
+		ORDER BY username
+	`, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []drainUser
+	for rows.Next() {
+		var user drainUser
+		if err := rows.Scan(&user.Username, &user.Port, &user.Protocol); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// getDrainTargets returns the other enabled, non-draining servers in the same location
+func (api *ManagementAPI) getDrainTargets(serverID string) ([]*drainTarget, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(`
+		SELECT t.name, t.capacity,
+		       (SELECT COUNT(*) FROM users u WHERE u.server_id = t.name AND u.active = true)
+		FROM servers s
+		JOIN servers t ON t.location_id = s.location_id
+		WHERE s.name = $1
+		  AND t.name <> s.name
+		  AND t.enabled = true
+		  AND t.draining = false
+	`, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []drainTarget
+	var names []string
+	for rows.Next() {
+		var name string
+		var t drainTarget
+		if err := rows.Scan(&name, &t.capacity, &t.userCount); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, t)
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var targets []*drainTarget
+	for i := range candidates {
+		server, err := api.findEndNode(names[i])
+		if err != nil {
+			// Server row exists but the node is not registered - skip it
+			continue
+		}
+		candidates[i].server = server
+		targets = append(targets, &candidates[i])
+	}
+
+	return targets, nil
+}
+
+// pickDrainTarget returns the target with the most free capacity, or nil if all are full
+func pickDrainTarget(targets []*drainTarget) *drainTarget {
+	var best *drainTarget
+	for _, t := range targets {
+		free := t.capacity - t.userCount
+		if free <= 0 {
+			continue
+		}
+		if best == nil || free > best.capacity-best.userCount {
+			best = t
+		}
+	}
+	return best
+}
+
+// updateDrainProgress persists migration counters for a running drain
+func (api *ManagementAPI) updateDrainProgress(drain *shared.EndNodeDrain, lastErr error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	var errMsg interface{}
+	if lastErr != nil {
+		errMsg = lastErr.Error()
+	}
+
+	_, err := conn.Exec(`
+		UPDATE server_drains
+		SET migrated_users = $1, failed_users = $2, last_error = COALESCE($3, last_error)
+		WHERE id = $4
+	`, drain.MigratedUsers, drain.FailedUsers, errMsg, drain.ID)
+	if err != nil {
+		log.Printf("[DRAIN] Failed to update progress for drain %d: %v", drain.ID, err)
+	}
+}
+
+// finishDrain marks a drain as completed or failed
+// A failed drain leaves the server draining so it can be resumed
+func (api *ManagementAPI) finishDrain(drain *shared.EndNodeDrain, drainErr error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	now := time.Now()
+	drain.CompletedAt = &now
+	drain.Status = shared.DrainStatusCompleted
+
+	var errMsg interface{}
+	if drainErr != nil {
+		drain.Status = shared.DrainStatusFailed
+		drain.LastError = drainErr.Error()
+		errMsg = drain.LastError
+	}
+
+	_, err := conn.Exec(`
+		UPDATE server_drains
+		SET status = $1, migrated_users = $2, failed_users = $3,
+		    last_error = COALESCE($4, last_error), completed_at = $5
+		WHERE id = $6
+	`, drain.Status, drain.MigratedUsers, drain.FailedUsers, errMsg, now, drain.ID)
+	if err != nil {
+		log.Printf("[DRAIN] Failed to finish drain %d: %v", drain.ID, err)
+	}
+
+	action := "ENDNODE_DRAIN_COMPLETED"
+	if drainErr != nil {
+		action = "ENDNODE_DRAIN_FAILED"
+	}
+
+	api.logAudit(
+		action,
+		"",
+		fmt.Sprintf("Drain of end-node %s %s - migrated=%d failed=%d", drain.ServerID, drain.Status, drain.MigratedUsers, drain.FailedUsers),
+		"",
+	)
+}
+
+// getLatestDrain loads the most recent drain run for a server
+func (api *ManagementAPI) getLatestDrain(serverID string) (*shared.EndNodeDrain, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	var drain shared.EndNodeDrain
+	var lastError sql.NullString
+	var completedAt sql.NullTime
+
+	err := conn.QueryRow(`
+		SELECT id, server_id, status, total_users, migrated_users, failed_users,
+		       last_error, started_at, completed_at
+		FROM server_drains
+		WHERE server_id = $1
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, serverID).Scan(
+		&drain.ID,
+		&drain.ServerID,
+		&drain.Status,
+		&drain.TotalUsers,
+		&drain.MigratedUsers,
+		&drain.FailedUsers,
+		&lastError,
+		&drain.StartedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastError.Valid {
+		drain.LastError = lastError.String
+	}
+	if completedAt.Valid {
+		drain.CompletedAt = &completedAt.Time
+	}
+
+	return &drain, nil
+}
+
+// isServerDraining reports whether a server is draining and must not receive new users
+func (api *ManagementAPI) isServerDraining(serverID string) (bool, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	var draining bool
+	err := conn.QueryRow("SELECT draining FROM servers WHERE name = $1", serverID).Scan(&draining)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return draining, err
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"vpnmanager/pkg/shared"
+)
+
+// findEndNode looks up a registered end-node by its server ID
+func (api *ManagementAPI) findEndNode(serverID string) (*shared.Server, error) {
+	endNodes, err := api.manager.ListEndNodes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list end-nodes: %v", err)
+	}
+
+	for i := range endNodes {
+		if endNodes[i].Name == serverID {
+			return &endNodes[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("end-node '%s' not found", serverID)
+}
+
+// createUserOnEndNode asks an end-node to create a user and issue a new OVPN profile
+func (api *ManagementAPI) createUserOnEndNode(endNode *shared.Server, username string, port int, protocol string) error {
+	payload := map[string]interface{}{
+		"username": username,
+		"port":     port,
+		"protocol": protocol,
+	}
+
+	return api.callEndNode(endNode, "POST", "/api/users", payload)
+}
+
+// deleteUserOnEndNode asks an end-node to remove a user and revoke its profile
+func (api *ManagementAPI) deleteUserOnEndNode(endNode *shared.Server, username string) error {
+	return api.callEndNode(endNode, "DELETE", fmt.Sprintf("/api/users/%s", username), nil)
+}
+
+// disconnectUserOnEndNode asks an end-node to terminate a user's VPN session
+func (api *ManagementAPI) disconnectUserOnEndNode(endNode *shared.Server, username, sessionID string) error {
+	payload := map[string]interface{}{
+		"username":   username,
+		"session_id": sessionID,
+	}
+
+	return api.callEndNode(endNode, "POST", "/api/sessions/disconnect", payload)
+}
+
+// callEndNode sends a JSON request to an end-node API and checks the response status
+func (api *ManagementAPI) callEndNode(endNode *shared.Server, method, path string, payload interface{}) error {
+	url := fmt.Sprintf("http://%s:%d%s", endNode.Host, endNode.Port, path)
+
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %v", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := api.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request to end-node %s failed: %v", endNode.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("end-node %s returned status %d for %s %s", endNode.Name, resp.StatusCode, method, path)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const defaultEndNodeHealthWatchInterval = 1 * time.Minute
+
+// runEndNodeHealthWatch reports end-nodes whose latest health check turns offline or unhealthy
+// Health rows are written by the checker, so transitions are detected by polling
+// Interval is set by ENDNODE_HEALTH_WATCH_INTERVAL (Go duration)
+func (api *ManagementAPI) runEndNodeHealthWatch() {
+	interval := defaultEndNodeHealthWatchInterval
+	if d, err := time.ParseDuration(os.Getenv("ENDNODE_HEALTH_WATCH_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	log.Printf("[HEALTH] Watching end-node health every %v", interval)
+
+	// The first pass only records current states, so a restart does not re-report them
+	lastStatus, err := api.loadEndNodeHealth()
+	if err != nil {
+		log.Printf("[HEALTH] Failed to load end-node health: %v", err)
+		lastStatus = map[string]string{}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		current, err := api.loadEndNodeHealth()
+		if err != nil {
+			log.Printf("[HEALTH] Failed to load end-node health: %v", err)
+			continue
+		}
+
+		for serverID, status := range current {
+			previous, seen := lastStatus[serverID]
+			if seen && !isEndNodeDown(previous) && isEndNodeDown(status) {
+				api.reportEndNodeOffline(serverID, previous, status)
+			}
+		}
+		lastStatus = current
+	}
+}
+
+// loadEndNodeHealth returns the latest health status of every enabled end-node
+func (api *ManagementAPI) loadEndNodeHealth() (map[string]string, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(`
+		SELECT s.name, COALESCE((
+			SELECT h.status FROM server_health h
+			WHERE h.server_id = s.name
+			ORDER BY h.last_check DESC
+			LIMIT 1
+		), '')
+		FROM servers s
+		WHERE s.enabled = true
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make(map[string]string)
+	for rows.Next() {
+		var serverID, status string
+		if err := rows.Scan(&serverID, &status); err != nil {
+			return nil, err
+		}
+		statuses[serverID] = status
+	}
+
+	return statuses, rows.Err()
+}
+
+// isEndNodeDown reports whether a health status means the end-node cannot serve clients
+func isEndNodeDown(status string) bool {
+	return status == "offline" || status == "unhealthy"
+}
+
+// reportEndNodeOffline publishes an end-node going down; the audit subscriber records it
+func (api *ManagementAPI) reportEndNodeOffline(serverID, previous, status string) {
+	log.Printf("[HEALTH] End-node %s went %s (was %q)", serverID, status, previous)
+
+	publishEvent(nil, shared.EndNodeOfflineEvent{
+		ServerID:       serverID,
+		Status:         status,
+		PreviousStatus: previous,
+	})
+}
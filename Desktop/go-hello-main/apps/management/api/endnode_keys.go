@@ -0,0 +1,105 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+
+	"vpnmanager/pkg/shared"
+)
+
+// requireEndNodeKey checks the X-API-Key header against the key issued to serverID
+// Keys are bound to one end-node, so a node cannot upload under another node's ID
+// Writes the error response and returns false if the request must not proceed
+func (api *ManagementAPI) requireEndNodeKey(w http.ResponseWriter, r *http.Request, serverID string) bool {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+
+	var keyHash string
+	err := conn.QueryRow("SELECT key_hash FROM endnode_api_keys WHERE server_id = $1", serverID).Scan(&keyHash)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	if err != nil {
+		log.Printf("[ENDNODE] Failed to load API key for %s: %v", serverID, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashEndNodeKey(key)), []byte(keyHash)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// handleEndNodeAPIKey issues a new upload key for an end-node, replacing any previous one
+// POST /api/endnodes/{server_id}/api-key
+func (api *ManagementAPI) handleEndNodeAPIKey(w http.ResponseWriter, r *http.Request, serverID string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := api.findEndNode(serverID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("[ENDNODE] Failed to generate API key: %v", err)
+		http.Error(w, "Failed to generate API key", http.StatusInternalServerError)
+		return
+	}
+	key := hex.EncodeToString(b)
+
+	conn := api.manager.GetDB().GetConnection()
+	_, err := conn.Exec(`
+		INSERT INTO endnode_api_keys (server_id, key_hash, issued_by, issued_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (server_id) DO UPDATE
+		SET key_hash = EXCLUDED.key_hash, issued_by = EXCLUDED.issued_by, issued_at = EXCLUDED.issued_at
+	`, serverID, hashEndNodeKey(key), admin)
+	if err != nil {
+		log.Printf("[ENDNODE] Failed to store API key for %s: %v", serverID, err)
+		http.Error(w, "Failed to store API key", http.StatusInternalServerError)
+		return
+	}
+
+	api.audit(r, shared.AuditEvent{
+		Action:   "ENDNODE_API_KEY_ISSUED",
+		Actor:    admin,
+		Target:   serverID,
+		ServerID: serverID,
+		Details:  fmt.Sprintf("Upload API key issued for end-node %s", serverID),
+	})
+
+	writeAPIResponse(w, http.StatusCreated, "API key issued; it is only shown once", map[string]interface{}{
+		"server_id": serverID,
+		"api_key":   key,
+	})
+}
+
+// hashEndNodeKey returns the stored form of an end-node API key
+func hashEndNodeKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// maxEndNodeLogUpload caps the entries accepted in one upload
+const maxEndNodeLogUpload = 1000
+
+// handleEndNodeLogs ingests audit entries uploaded by an end-node
+// POST /api/endnodes/{server_id}/logs
+// Body: {"entries": [{"id": 1, "timestamp": "...", "action": "...", ...}]}
+// Uploads are idempotent per entry ID; an empty upload returns the resume point
+func (api *ManagementAPI) handleEndNodeLogs(w http.ResponseWriter, r *http.Request, serverID string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !api.requireEndNodeKey(w, r, serverID) {
+		return
+	}
+
+	if _, err := api.findEndNode(serverID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req shared.EndNodeLogUpload
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Entries) > maxEndNodeLogUpload {
+		http.Error(w, fmt.Sprintf("At most %d entries per upload", maxEndNodeLogUpload), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	for i := range req.Entries {
+		if err := normalizeEndNodeLogEntry(&req.Entries[i]); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid entry %d: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := api.storeEndNodeLogs(serverID, req.Entries)
+	if err != nil {
+		log.Printf("[ENDNODE_LOGS] Failed to store logs from %s: %v", serverID, err)
+		http.Error(w, "Failed to store logs", http.StatusInternalServerError)
+		return
+	}
+
+	if result.Stored > 0 {
+		log.Printf("[ENDNODE_LOGS] Stored %d entries from %s (%d duplicates, last_id=%d)",
+			result.Stored, serverID, result.Duplicates, result.LastID)
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Stored %d of %d entries", result.Stored, result.Received),
+		Data:      result,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// normalizeEndNodeLogEntry validates an uploaded entry and fills in defaults
+func normalizeEndNodeLogEntry(entry *shared.EndNodeLogEntry) error {
+	if entry.ID <= 0 {
+		return fmt.Errorf("id must be positive")
+	}
+	if entry.Action == "" {
+		return fmt.Errorf("action is required")
+	}
+	if entry.Timestamp.IsZero() {
+		return fmt.Errorf("timestamp is required")
+	}
+
+	if entry.Actor == "" {
+		entry.Actor = entry.Username
+	}
+
+	switch entry.Outcome {
+	case "":
+		entry.Outcome = auditOutcome(entry.Action)
+	case shared.AuditOutcomeSuccess, shared.AuditOutcomeFailure, shared.AuditOutcomeDenied:
+	default:
+		return fmt.Errorf("outcome must be one of: success, failure, denied")
+	}
+
+	entry.Timestamp = entry.Timestamp.UTC()
+	return nil
+}
+
+// storeEndNodeLogs stores new entries, skipping any already received
+func (api *ManagementAPI) storeEndNodeLogs(serverID string, entries []shared.EndNodeLogEntry) (*shared.EndNodeLogIngestResult, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	result := &shared.EndNodeLogIngestResult{
+		ServerID: serverID,
+		Received: len(entries),
+	}
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		metadata := []byte("{}")
+		if len(entry.Metadata) > 0 {
+			if metadata, err = json.Marshal(entry.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to encode metadata of entry %d: %v", entry.ID, err)
+			}
+		}
+
+		res, err := tx.Exec(`
+			INSERT INTO endnode_audit_log
+				(server_id, source_id, timestamp, action, actor, target, outcome, details, ip_address, metadata)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (server_id, source_id) DO NOTHING
+		`, serverID, entry.ID, entry.Timestamp, entry.Action, nullString(entry.Actor), nullString(entry.Target),
+			entry.Outcome, entry.Details, nullString(entry.IPAddress), metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store entry %d: %v", entry.ID, err)
+		}
+
+		if n, _ := res.RowsAffected(); n > 0 {
+			result.Stored++
+		} else {
+			result.Duplicates++
+		}
+	}
+
+	if err := tx.QueryRow(
+		"SELECT COALESCE(MAX(source_id), 0) FROM endnode_audit_log WHERE server_id = $1", serverID,
+	).Scan(&result.LastID); err != nil {
+		return nil, err
+	}
+
+	return result, tx.Commit()
+}
+
+// streamEndNodeLogs calls fn for each matching end-node entry, newest first
+// filter.SourceCursors holds the position reached in each end-node; end-nodes without
+// a cursor start at their newest entry
+func (api *ManagementAPI) streamEndNodeLogs(filter *auditFilter, limit int, fn func(shared.AuditEvent) error) error {
+	return streamEndNodeAuditLog(api.manager.GetDB().GetConnection(), filter, limit, fn)
+}
+
+// streamEndNodeAuditLog reads matching endnode_audit_log entries for streamEndNodeLogs
+func streamEndNodeAuditLog(conn *sql.DB, filter *auditFilter, limit int, fn func(shared.AuditEvent) error) error {
+	unpositioned := *filter
+	unpositioned.Cursor = nil
+	where, args := unpositioned.where("actor")
+
+	// Each end-node continues after its own cursor
+	cursors := filter.SourceCursors
+	sources := make([]string, 0, len(cursors))
+	for source := range cursors {
+		if source != managementServerID {
+			sources = append(sources, source)
+		}
+	}
+	sort.Strings(sources)
+
+	if len(sources) > 0 {
+		var positioned, seen []string
+		for _, source := range sources {
+			c := cursors[source]
+			args = append(args, source, c.At, c.ID)
+			n := len(args)
+			positioned = append(positioned, fmt.Sprintf("(server_id = $%d AND (timestamp, id) < ($%d, $%d))", n-2, n-1, n))
+			seen = append(seen, fmt.Sprintf("$%d", n-2))
+		}
+		where += " AND (" + strings.Join(positioned, " OR ") + " OR server_id NOT IN (" + strings.Join(seen, ", ") + "))"
+	}
+
+	args = append(args, limit)
+	rows, err := conn.Query(`
+		SELECT id, server_id, source_id, timestamp, action, COALESCE(actor, ''), COALESCE(target, ''),
+		       outcome, COALESCE(details, ''), COALESCE(ip_address, ''), metadata
+		FROM endnode_audit_log
+		`+where+fmt.Sprintf(`
+		ORDER BY timestamp DESC, id DESC
+		LIMIT $%d`, len(args)), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event shared.AuditEvent
+		var metadata []byte
+		err := rows.Scan(&event.ID, &event.ServerID, &event.SourceID, &event.Timestamp, &event.Action,
+			&event.Actor, &event.Target, &event.Outcome, &event.Details, &event.IPAddress, &metadata)
+		if err != nil {
+			return err
+		}
+		if len(metadata) > 0 {
+			json.Unmarshal(metadata, &event.Metadata)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// queryMergedAuditEvents returns one page of management and end-node events in time order
+// The page cursor holds a position per source, so each source resumes exactly where it stopped
+func (api *ManagementAPI) queryMergedAuditEvents(filter *auditFilter) (*shared.AuditLogPage, error) {
+	cursors := filter.SourceCursors
+
+	// The newest filter.Limit events overall are among the newest filter.Limit+1 of each side
+	management := *filter
+	management.Cursor = cursors[managementServerID]
+
+	var local, remote []shared.AuditEvent
+	err := api.streamAuditEvents(&management, filter.Limit+1, func(event shared.AuditEvent) error {
+		local = append(local, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = api.streamEndNodeLogs(filter, filter.Limit+1, func(event shared.AuditEvent) error {
+		remote = append(remote, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &shared.AuditLogPage{Events: []shared.AuditEvent{}}
+	next := make(map[string]*pageCursor, len(cursors))
+	for source, c := range cursors {
+		next[source] = c
+	}
+
+	i, j := 0, 0
+	for len(page.Events) < filter.Limit && (i < len(local) || j < len(remote)) {
+		// Ties go to the management server so the order is stable across pages
+		if j >= len(remote) || (i < len(local) && !remote[j].Timestamp.After(local[i].Timestamp)) {
+			next[managementServerID] = &pageCursor{At: local[i].Timestamp, ID: local[i].ID}
+			page.Events = append(page.Events, local[i])
+			i++
+		} else {
+			next[remote[j].ServerID] = &pageCursor{At: remote[j].Timestamp, ID: remote[j].ID}
+			page.Events = append(page.Events, remote[j])
+			j++
+		}
+	}
+
+	if i < len(local) || j < len(remote) {
+		page.NextCursor = encodeMergedCursor(next)
+	}
+
+	return page, nil
+}
+
+// encodeMergedCursor returns the opaque cursor holding a position per source
+func encodeMergedCursor(cursors map[string]*pageCursor) string {
+	raw := make(map[string]string, len(cursors))
+	for source, c := range cursors {
+		raw[source] = c.encode()
+	}
+
+	data, _ := json.Marshal(raw)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeMergedCursor parses a cursor produced by encodeMergedCursor
+func decodeMergedCursor(s string) (map[string]*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	cursors := make(map[string]*pageCursor, len(raw))
+	for source, v := range raw {
+		c, err := decodePageCursor(v)
+		if err != nil {
+			return nil, err
+		}
+		cursors[source] = c
+	}
+
+	return cursors, nil
+}
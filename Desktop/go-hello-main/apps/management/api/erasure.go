@@ -0,0 +1,468 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	erasureActionDeleted    = "deleted"
+	erasureActionAnonymized = "anonymized"
+)
+
+// erasureMu serializes erasures so a request is never executed twice at once
+var erasureMu sync.Mutex
+
+// erasureStep deletes or anonymizes one table's rows for a user
+// $1 is the username and $2 the pseudonym; deletions use only $1
+type erasureStep struct {
+	table  string
+	action string
+	query  string
+}
+
+// erasureSteps run in order in one transaction
+// Usage rows are anonymized rather than deleted so fleet totals and reports stay correct
+var erasureSteps = []erasureStep{
+	{"vpn_connections", erasureActionAnonymized, `
+		UPDATE vpn_connections
+		SET username = $2, ip_address = NULL,
+		    status = CASE WHEN disconnected_at IS NULL THEN 'disconnected' ELSE status END,
+		    close_reason = CASE WHEN disconnected_at IS NULL THEN '` + closeReasonRevoked + `' ELSE close_reason END,
+		    disconnected_at = COALESCE(disconnected_at, NOW())
+		WHERE username = $1`},
+	{"vpn_statistics", erasureActionAnonymized, "UPDATE vpn_statistics SET username = $2 WHERE username = $1"},
+	{"vpn_statistics_hourly", erasureActionAnonymized, "UPDATE vpn_statistics_hourly SET username = $2 WHERE username = $1"},
+	{"vpn_statistics_daily", erasureActionAnonymized, "UPDATE vpn_statistics_daily SET username = $2 WHERE username = $1"},
+	{"latency_measurements", erasureActionAnonymized, `
+		UPDATE latency_measurements
+		SET username = $2, client_latitude = NULL, client_longitude = NULL
+		WHERE username = $1`},
+	{"security_alerts", erasureActionAnonymized, "UPDATE security_alerts SET username = $2, ip_address = NULL WHERE username = $1"},
+	{"openvpn_clients", erasureActionDeleted, "DELETE FROM openvpn_clients WHERE username = $1"},
+	{"stats_ingest_cursors", erasureActionDeleted, "DELETE FROM stats_ingest_cursors WHERE username = $1"},
+	{"usage_periods", erasureActionDeleted, "DELETE FROM usage_periods WHERE username = $1"},
+	{"quota_notifications", erasureActionDeleted, "DELETE FROM quota_notifications WHERE username = $1"},
+	{"user_server_preferences", erasureActionDeleted, "DELETE FROM user_server_preferences WHERE username = $1"},
+	{"user_source_locations", erasureActionDeleted, "DELETE FROM user_source_locations WHERE username = $1"},
+	{"auth_users", erasureActionDeleted, "DELETE FROM auth_users WHERE phone_number = $1"},
+}
+
+// erasureRetained explains the records erasure deliberately keeps
+var erasureRetained = []string{
+	"audit_log: hash-chained security record, kept until removed by its retention policy",
+	"endnode_audit_log: security record, kept until removed by its retention policy",
+}
+
+// handleErasures handles data erasure administration
+// GET  /api/erasures?status=&limit=
+// POST /api/erasures                {"username": "...", "reason": "..."}
+// GET  /api/erasures/{id}
+// POST /api/erasures/{id}/execute
+func (api *ManagementAPI) handleErasures(w http.ResponseWriter, r *http.Request) {
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/erasures"), "/")
+	if path == "" {
+		switch r.Method {
+		case "GET":
+			api.handleListErasures(w, r)
+		case "POST":
+			api.handleCreateErasure(w, r, admin)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "execute") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		request, err := api.getErasureRequest(id)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Erasure request not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to load erasure request %d: %v", id, err)
+			http.Error(w, "Failed to retrieve erasure request", http.StatusInternalServerError)
+			return
+		}
+		writeAPIResponse(w, http.StatusOK, "Erasure request retrieved successfully", request)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	api.handleExecuteErasure(w, r, admin, id)
+}
+
+// handleListErasures lists erasure requests, newest first
+func (api *ManagementAPI) handleListErasures(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", shared.ErasureStatusPending, shared.ErasureStatusPartial, shared.ErasureStatusCompleted:
+	default:
+		http.Error(w, "status must be one of: pending, partial, completed", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := intParam(r, "limit", 50, 500)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	rows, err := conn.Query(`
+		SELECT `+erasureColumns+`
+		FROM data_erasure_requests
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY requested_at DESC, id DESC
+		LIMIT $2
+	`, status, limit)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list erasure requests: %v", err)
+		http.Error(w, "Failed to retrieve erasure requests", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	requests := []shared.DataErasureRequest{}
+	for rows.Next() {
+		request, err := scanErasureRequest(rows)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan erasure request: %v", err)
+			http.Error(w, "Failed to retrieve erasure requests", http.StatusInternalServerError)
+			return
+		}
+		requests = append(requests, *request)
+	}
+
+	writeAPIResponse(w, http.StatusOK, fmt.Sprintf("Retrieved %d erasure requests", len(requests)), requests)
+}
+
+// handleCreateErasure records an erasure request on a user's behalf
+func (api *ManagementAPI) handleCreateErasure(w http.ResponseWriter, r *http.Request, admin string) {
+	var req struct {
+		Username string `json:"username"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if req.Username == "" {
+		http.Error(w, "username is required", http.StatusBadRequest)
+		return
+	}
+
+	request, err := createErasureRequest(api.manager.GetDB().GetConnection(), req.Username, admin, req.Reason)
+	if err == errErasurePending {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to record erasure request for %s: %v", req.Username, err)
+		http.Error(w, "Failed to record erasure request", http.StatusInternalServerError)
+		return
+	}
+
+	api.audit(r, shared.AuditEvent{
+		Action:  "DATA_ERASURE_REQUESTED",
+		Actor:   admin,
+		Target:  request.Pseudonym,
+		Details: fmt.Sprintf("Erasure request %d recorded", request.ID),
+	})
+
+	writeAPIResponse(w, http.StatusCreated, "Erasure request recorded", request)
+}
+
+// handleExecuteErasure erases the user's data and returns the receipt
+// A partial erasure can be executed again to retry the end-nodes that failed
+func (api *ManagementAPI) handleExecuteErasure(w http.ResponseWriter, r *http.Request, admin string, id int64) {
+	erasureMu.Lock()
+	defer erasureMu.Unlock()
+
+	request, err := api.getErasureRequest(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Erasure request not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to load erasure request %d: %v", id, err)
+		http.Error(w, "Failed to retrieve erasure request", http.StatusInternalServerError)
+		return
+	}
+	if request.Status == shared.ErasureStatusCompleted {
+		http.Error(w, "Erasure request has already been completed", http.StatusConflict)
+		return
+	}
+
+	receipt, err := api.executeErasure(request, admin)
+	if err != nil {
+		log.Printf("[PRIVACY] Erasure %d failed: %v", id, err)
+		http.Error(w, "Failed to erase user data", http.StatusInternalServerError)
+		return
+	}
+
+	outcome := shared.AuditOutcomeSuccess
+	message := "User data erased"
+	if request.Status == shared.ErasureStatusPartial {
+		outcome = shared.AuditOutcomeFailure
+		message = "User data erased; some end-nodes still hold the profile, execute again to retry"
+	}
+
+	// The digest in the chained audit log makes later changes to the stored receipt detectable
+	api.audit(r, shared.AuditEvent{
+		Action:  "DATA_ERASURE_EXECUTED",
+		Actor:   admin,
+		Target:  request.Pseudonym,
+		Outcome: outcome,
+		Details: fmt.Sprintf("Erasure request %d %s", request.ID, request.Status),
+		Metadata: map[string]interface{}{
+			"request_id":     request.ID,
+			"receipt_digest": receipt.Digest,
+		},
+	})
+	log.Printf("[PRIVACY] Erasure request %d %s (receipt %s)", request.ID, request.Status, receipt.Digest)
+
+	writeAPIResponse(w, http.StatusOK, message, request)
+}
+
+// executeErasure removes the user's profiles from end-nodes, then deletes or anonymizes
+// their rows and stores the receipt on the request
+func (api *ManagementAPI) executeErasure(request *shared.DataErasureRequest, admin string) (*shared.DataErasureReceipt, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	// A retry builds on the receipt of the earlier attempt
+	receipt := request.Receipt
+	if receipt == nil {
+		receipt = &shared.DataErasureReceipt{
+			RequestID: request.ID,
+			Pseudonym: request.Pseudonym,
+			Tables:    []shared.DataErasureTableResult{},
+			EndNodes:  []shared.DataErasureEndNodeResult{},
+		}
+	}
+	receipt.ExecutedBy = admin
+	receipt.ExecutedAt = time.Now().UTC()
+	receipt.Retained = erasureRetained
+
+	endNodes, err := api.eraseEndNodeProfiles(request.Username)
+	if err != nil {
+		return nil, err
+	}
+	receipt.EndNodes = mergeEndNodeResults(receipt.EndNodes, endNodes)
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tables := make([]shared.DataErasureTableResult, 0, len(erasureSteps))
+	for _, step := range erasureSteps {
+		args := []interface{}{request.Username}
+		if step.action == erasureActionAnonymized {
+			args = append(args, request.Pseudonym)
+		}
+
+		result, err := tx.Exec(step.query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", step.table, err)
+		}
+		n, _ := result.RowsAffected()
+		tables = append(tables, shared.DataErasureTableResult{Table: step.table, Action: step.action, Rows: n})
+	}
+	receipt.Tables = mergeTableResults(receipt.Tables, tables)
+
+	request.Status = shared.ErasureStatusCompleted
+	for _, node := range receipt.EndNodes {
+		if !node.Removed {
+			request.Status = shared.ErasureStatusPartial
+		}
+	}
+
+	receipt.Digest = ""
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	receipt.Digest = hex.EncodeToString(sum[:])
+
+	data, err = json.Marshal(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	// The username is kept only while end-nodes remain to be retried
+	var username interface{} = request.Username
+	if request.Status == shared.ErasureStatusCompleted {
+		username = nil
+		request.Username = ""
+	}
+
+	_, err = tx.Exec(`
+		UPDATE data_erasure_requests
+		SET username = $1, status = $2, executed_by = $3, executed_at = $4, receipt = $5
+		WHERE id = $6
+	`, username, request.Status, admin, receipt.ExecutedAt, data, request.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	request.ExecutedBy = admin
+	request.ExecutedAt = &receipt.ExecutedAt
+	request.Receipt = receipt
+	return receipt, nil
+}
+
+// eraseEndNodeProfiles revokes the user's profile on every end-node serving them
+// Each removed assignment is deleted so a retry only contacts the end-nodes that failed
+func (api *ManagementAPI) eraseEndNodeProfiles(username string) ([]shared.DataErasureEndNodeResult, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query("SELECT DISTINCT server_id FROM users WHERE username = $1 ORDER BY server_id", username)
+	if err != nil {
+		return nil, err
+	}
+	var serverIDs []string
+	for rows.Next() {
+		var serverID string
+		if err := rows.Scan(&serverID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		serverIDs = append(serverIDs, serverID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make([]shared.DataErasureEndNodeResult, 0, len(serverIDs))
+	for _, serverID := range serverIDs {
+		result := shared.DataErasureEndNodeResult{ServerID: serverID}
+
+		// A deregistered end-node no longer holds any profiles
+		if endNode, err := api.findEndNode(serverID); err == nil {
+			if err := api.deleteUserOnEndNode(endNode, username); err != nil {
+				log.Printf("[PRIVACY] Failed to remove profile from %s: %v", serverID, err)
+				result.Error = err.Error()
+				results = append(results, result)
+				continue
+			}
+		}
+
+		if _, err := conn.Exec("DELETE FROM users WHERE username = $1 AND server_id = $2", username, serverID); err != nil {
+			return nil, err
+		}
+		result.Removed = true
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// mergeEndNodeResults replaces earlier end-node results with those of a retry
+func mergeEndNodeResults(previous, latest []shared.DataErasureEndNodeResult) []shared.DataErasureEndNodeResult {
+	merged := make([]shared.DataErasureEndNodeResult, 0, len(previous)+len(latest))
+	retried := make(map[string]bool, len(latest))
+	for _, result := range latest {
+		retried[result.ServerID] = true
+	}
+	for _, result := range previous {
+		if !retried[result.ServerID] {
+			merged = append(merged, result)
+		}
+	}
+	return append(merged, latest...)
+}
+
+// mergeTableResults adds the rows affected by a retry to the earlier totals
+func mergeTableResults(previous, latest []shared.DataErasureTableResult) []shared.DataErasureTableResult {
+	if len(previous) == 0 {
+		return latest
+	}
+
+	rows := make(map[string]int64, len(latest))
+	for _, result := range latest {
+		rows[result.Table] = result.Rows
+	}
+	for i := range previous {
+		previous[i].Rows += rows[previous[i].Table]
+		delete(rows, previous[i].Table)
+	}
+	for _, result := range latest {
+		if _, ok := rows[result.Table]; ok {
+			previous = append(previous, result)
+		}
+	}
+	return previous
+}
+
+// erasureColumns are the data_erasure_requests columns read by scanErasureRequest
+const erasureColumns = `id, COALESCE(username, ''), pseudonym, status, requested_by, COALESCE(reason, ''),
+		       requested_at, COALESCE(executed_by, ''), executed_at, receipt`
+
+// getErasureRequest loads one erasure request
+func (api *ManagementAPI) getErasureRequest(id int64) (*shared.DataErasureRequest, error) {
+	conn := api.manager.GetDB().GetConnection()
+	return scanErasureRequest(conn.QueryRow("SELECT "+erasureColumns+" FROM data_erasure_requests WHERE id = $1", id))
+}
+
+// scanErasureRequest scans a row selected with erasureColumns
+func scanErasureRequest(row rowScanner) (*shared.DataErasureRequest, error) {
+	var request shared.DataErasureRequest
+	var executedAt sql.NullTime
+	var receipt []byte
+
+	err := row.Scan(&request.ID, &request.Username, &request.Pseudonym, &request.Status, &request.RequestedBy,
+		&request.Reason, &request.RequestedAt, &request.ExecutedBy, &executedAt, &receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	if executedAt.Valid {
+		request.ExecutedAt = &executedAt.Time
+	}
+	if len(receipt) > 0 {
+		request.Receipt = &shared.DataErasureReceipt{}
+		if err := json.Unmarshal(receipt, request.Receipt); err != nil {
+			return nil, fmt.Errorf("invalid receipt: %v", err)
+		}
+	}
+
+	return &request, nil
+}
@@ -0,0 +1,390 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	defaultEventQueueSize      = 1000
+	defaultEventOutboxInterval = 5 * time.Second
+	eventOutboxBatchSize       = 100
+)
+
+// eventHandler receives published events
+type eventHandler func(shared.EventEnvelope)
+
+// durableEventHandler records an event through exec, the bus connection or the
+// outbox relay's transaction
+type durableEventHandler func(exec sqlExecer, envelope shared.EventEnvelope) error
+
+// sqlExecer is satisfied by *sql.DB and *sql.Tx
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// eventSubscriber is one handler registered on the bus
+// Synchronous subscribers run inside Publish; asynchronous ones drain a bounded
+// queue on their own goroutine and drop events when it is full. Durable ones
+// write to the database before an outbox event is marked published.
+type eventSubscriber struct {
+	name    string
+	types   map[string]bool
+	handler eventHandler
+	durable durableEventHandler
+	queue   chan shared.EventEnvelope
+
+	mu      sync.Mutex
+	dropped int64
+}
+
+// eventBus is an in-process publish/subscribe bus for domain events
+// Handlers raise events instead of calling every side effect inline
+type eventBus struct {
+	db          *sql.DB
+	mu          sync.RWMutex
+	subscribers []*eventSubscriber
+}
+
+// defaultEventBus is shared by the management API and the auth handler
+var defaultEventBus = newEventBus()
+
+// newEventBus creates an empty event bus
+func newEventBus() *eventBus {
+	return &eventBus{}
+}
+
+var eventBusOnce sync.Once
+
+// startEventBus registers the default bus subscribers and starts the outbox relay
+// The API and the auth handler both call it so either can run alone; only the first call counts
+func startEventBus(db *sql.DB) {
+	eventBusOnce.Do(func() {
+		defaultEventBus.db = db
+		subscribeWebhooks(defaultEventBus)
+		subscribeMetrics(defaultEventBus)
+		subscribeAudit(defaultEventBus, newAuditLogger(db, managementServerID))
+		go defaultEventBus.runOutboxRelay(db)
+	})
+}
+
+// publishEvent publishes an event raised while serving r (nil for background jobs) on the default bus
+func publishEvent(r *http.Request, event shared.Event) {
+	defaultEventBus.Publish(r, event)
+}
+
+// Subscribe registers a handler that runs inside Publish, before it returns
+// With no types the handler receives every event
+func (b *eventBus) Subscribe(name string, handler eventHandler, types ...string) {
+	b.add(&eventSubscriber{name: name, types: eventTypeSet(types), handler: handler})
+}
+
+// SubscribeAsync registers a handler that runs on its own goroutine
+// Up to queueSize events wait for it; further events are dropped and counted
+func (b *eventBus) SubscribeAsync(name string, queueSize int, handler eventHandler, types ...string) {
+	if queueSize <= 0 {
+		queueSize = defaultEventQueueSize
+	}
+
+	s := &eventSubscriber{
+		name:    name,
+		types:   eventTypeSet(types),
+		handler: handler,
+		queue:   make(chan shared.EventEnvelope, queueSize),
+	}
+	go s.run()
+	b.add(s)
+}
+
+// SubscribeDurable registers a handler whose writes must not be lost
+// Outbox events reach it inside the relay transaction, so a failure leaves the
+// event unpublished and it is retried; other events are written through the bus connection
+func (b *eventBus) SubscribeDurable(name string, handler durableEventHandler, types ...string) {
+	b.add(&eventSubscriber{name: name, types: eventTypeSet(types), durable: handler})
+}
+
+// add appends a subscriber
+func (b *eventBus) add(s *eventSubscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, s)
+}
+
+// Publish assigns the event an ID and hands it to every matching subscriber
+// Events published this way are lost if the process dies before async
+// subscribers handle them; use PublishTx when that matters
+func (b *eventBus) Publish(r *http.Request, event shared.Event) shared.EventEnvelope {
+	envelope := newEventEnvelope(r, event)
+	if b.db != nil {
+		if err := b.dispatchDurable(b.db, envelope); err != nil {
+			log.Printf("[EVENTS] %v", err)
+		}
+	}
+	b.dispatch(envelope)
+	return envelope
+}
+
+// PublishTx stores the event in the outbox as part of tx
+// The outbox relay dispatches it once tx has committed, so the event is
+// published if and only if the caller's changes are, even across a crash
+func (b *eventBus) PublishTx(tx *sql.Tx, r *http.Request, event shared.Event) error {
+	envelope := newEventEnvelope(r, event)
+
+	payload, err := json.Marshal(envelope.Event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %v", envelope.Type, err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO event_outbox (event_id, event_type, payload, occurred_at, request_id, remote_addr)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, envelope.ID, envelope.Type, payload, envelope.OccurredAt,
+		nullString(envelope.RequestID), nullString(envelope.RemoteAddr))
+	if err != nil {
+		return fmt.Errorf("failed to store %s event in outbox: %v", envelope.Type, err)
+	}
+
+	return nil
+}
+
+// dispatchDurable runs the durable subscribers interested in the envelope's type
+// It stops at the first failure so the caller can roll back and retry
+func (b *eventBus) dispatchDurable(exec sqlExecer, envelope shared.EventEnvelope) error {
+	for _, s := range b.matching(envelope) {
+		if s.durable == nil {
+			continue
+		}
+		if err := s.durable(exec, envelope); err != nil {
+			return fmt.Errorf("subscriber %s failed on %s event %s: %v", s.name, envelope.Type, envelope.ID, err)
+		}
+	}
+	return nil
+}
+
+// dispatch delivers an envelope to the in-memory subscribers interested in its type
+func (b *eventBus) dispatch(envelope shared.EventEnvelope) {
+	for _, s := range b.matching(envelope) {
+		if s.durable != nil {
+			continue
+		}
+		if s.queue == nil {
+			s.call(envelope)
+			continue
+		}
+
+		select {
+		case s.queue <- envelope:
+		default:
+			s.mu.Lock()
+			s.dropped++
+			dropped := s.dropped
+			s.mu.Unlock()
+			log.Printf("[EVENTS] Subscriber %s queue full, dropped %s event %s (%d dropped)",
+				s.name, envelope.Type, envelope.ID, dropped)
+		}
+	}
+}
+
+// matching returns the subscribers interested in the envelope's type
+func (b *eventBus) matching(envelope shared.EventEnvelope) []*eventSubscriber {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var matched []*eventSubscriber
+	for _, s := range b.subscribers {
+		if len(s.types) == 0 || s.types[envelope.Type] {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// run handles queued events until the process exits
+func (s *eventSubscriber) run() {
+	for envelope := range s.queue {
+		s.call(envelope)
+	}
+}
+
+// call runs the handler, keeping a panicking subscriber from taking down the publisher
+func (s *eventSubscriber) call(envelope shared.EventEnvelope) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			log.Printf("[EVENTS] Subscriber %s panicked on %s event %s: %v", s.name, envelope.Type, envelope.ID, rec)
+		}
+	}()
+	s.handler(envelope)
+}
+
+// newEventEnvelope wraps an event with a fresh ID and timestamp, and the request that raised it
+func newEventEnvelope(r *http.Request, event shared.Event) shared.EventEnvelope {
+	envelope := shared.EventEnvelope{
+		ID:         newRequestID(),
+		Type:       event.EventType(),
+		OccurredAt: time.Now().UTC(),
+		Event:      event,
+	}
+	if r != nil {
+		envelope.RequestID = requestID(r)
+		envelope.RemoteAddr = r.RemoteAddr
+	}
+	return envelope
+}
+
+// eventIP returns the client address recorded on events raised by r
+func eventIP(r *http.Request) string {
+	if ip := clientIP(r); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// eventTypeSet builds the filter used by a subscriber
+func eventTypeSet(types []string) map[string]bool {
+	set := make(map[string]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return set
+}
+
+// eventDecoders rebuild typed events read back from the outbox
+var eventDecoders = map[string]func([]byte) (shared.Event, error){
+	shared.EventUserCreated:       decodeEvent[shared.UserCreatedEvent],
+	shared.EventUserDeleted:       decodeEvent[shared.UserDeletedEvent],
+	shared.EventLoginSucceeded:    decodeEvent[shared.LoginSucceededEvent],
+	shared.EventLoginFailed:       decodeEvent[shared.LoginFailedEvent],
+	shared.EventLoginLocked:       decodeEvent[shared.LoginLockedEvent],
+	shared.EventProfileDownloaded: decodeEvent[shared.ProfileDownloadedEvent],
+	shared.EventQuotaExceeded:     decodeEvent[shared.QuotaExceededEvent],
+	shared.EventEndNodeOffline:    decodeEvent[shared.EndNodeOfflineEvent],
+}
+
+// decodeEvent unmarshals an outbox payload into event type T
+func decodeEvent[T shared.Event](payload []byte) (shared.Event, error) {
+	var event T
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// runOutboxRelay publishes committed outbox events until the process exits
+// Interval is set by EVENT_OUTBOX_INTERVAL (Go duration)
+func (b *eventBus) runOutboxRelay(db *sql.DB) {
+	interval := defaultEventOutboxInterval
+	if d, err := time.ParseDuration(os.Getenv("EVENT_OUTBOX_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for {
+			n, err := b.relayOutboxBatch(db)
+			if err != nil {
+				log.Printf("[EVENTS] Outbox relay failed: %v", err)
+				break
+			}
+			if n < eventOutboxBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// relayOutboxBatch dispatches the oldest unpublished outbox events and marks them published
+// Rows stay locked until the batch is marked, so several relays never publish the
+// same event. Durable subscribers write in the same transaction, so their rows
+// commit with the published mark; in-memory subscribers run after the commit and
+// see the batch again if the process dies first (at-least-once)
+func (b *eventBus) relayOutboxBatch(db *sql.DB) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, event_id, event_type, payload, occurred_at, request_id, remote_addr
+		FROM event_outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	`, eventOutboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []int64
+	var envelopes []shared.EventEnvelope
+	for rows.Next() {
+		var id int64
+		var envelope shared.EventEnvelope
+		var payload []byte
+		var requestID, remoteAddr sql.NullString
+		if err := rows.Scan(&id, &envelope.ID, &envelope.Type, &payload, &envelope.OccurredAt, &requestID, &remoteAddr); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+		envelope.RequestID = requestID.String
+		envelope.RemoteAddr = remoteAddr.String
+
+		decode, ok := eventDecoders[envelope.Type]
+		if !ok {
+			log.Printf("[EVENTS] Skipping outbox event %s of unknown type %s", envelope.ID, envelope.Type)
+			continue
+		}
+		envelope.Event, err = decode(payload)
+		if err != nil {
+			log.Printf("[EVENTS] Skipping undecodable outbox event %s: %v", envelope.ID, err)
+			continue
+		}
+		envelopes = append(envelopes, envelope)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, envelope := range envelopes {
+		if err := b.dispatchDurable(tx, envelope); err != nil {
+			return 0, err
+		}
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+	_, err = tx.Exec("UPDATE event_outbox SET published_at = NOW() WHERE id IN ("+strings.Join(placeholders, ", ")+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	for _, envelope := range envelopes {
+		b.dispatch(envelope)
+	}
+
+	return len(ids), nil
+}
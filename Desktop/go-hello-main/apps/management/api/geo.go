@@ -0,0 +1,347 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// earthRadiusKm is the mean Earth radius used for great-circle distances
+const earthRadiusKm = 6371.0
+
+// Default latency model: light in fiber covers ~200km/ms, so RTT is ~0.01ms/km,
+// inflated by ~1.5x for real-world routing, plus fixed last-mile overhead
+const (
+	defaultLatencyBaseMs  = 10.0
+	defaultLatencyMsPerKm = 0.015
+
+	// Minimum number of measurements required before recalibrating the model
+	minLatencyCalibrationSamples = 5
+)
+
+// geoPoint is a coordinate on the Earth's surface
+type geoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// haversineKm returns the great-circle distance between two points in kilometers
+func haversineKm(a, b geoPoint) float64 {
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := (b.Latitude - a.Latitude) * math.Pi / 180
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// geoIPResolver maps client IP addresses to coordinates and ASNs using MaxMind-format databases
+type geoIPResolver struct {
+	reader    *maxminddb.Reader
+	asnReader *maxminddb.Reader
+}
+
+// geoIPCityRecord is the subset of a GeoIP2/GeoLite2 City record we use
+type geoIPCityRecord struct {
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// geoIPASNRecord is the subset of a GeoLite2 ASN record we use
+type geoIPASNRecord struct {
+	AutonomousSystemNumber uint `maxminddb:"autonomous_system_number"`
+}
+
+// newGeoIPResolver opens the MaxMind city database at path and, if asnPath is set, the ASN database
+func newGeoIPResolver(path, asnPath string) (*geoIPResolver, error) {
+	resolver := &geoIPResolver{}
+
+	if path != "" {
+		reader, err := maxminddb.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP database %s: %v", path, err)
+		}
+		resolver.reader = reader
+	}
+
+	if asnPath != "" {
+		reader, err := maxminddb.Open(asnPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open GeoIP ASN database %s: %v", asnPath, err)
+		}
+		resolver.asnReader = reader
+	}
+
+	return resolver, nil
+}
+
+// newGeoIPResolverFromEnv opens the databases named by GEOIP_DB_PATH and GEOIP_ASN_DB_PATH
+// Returns nil when geolocation is not configured or a file can't be opened
+func newGeoIPResolverFromEnv() *geoIPResolver {
+	path := os.Getenv("GEOIP_DB_PATH")
+	asnPath := os.Getenv("GEOIP_ASN_DB_PATH")
+	if path == "" && asnPath == "" {
+		return nil
+	}
+
+	resolver, err := newGeoIPResolver(path, asnPath)
+	if err != nil {
+		log.Printf("[GEOIP] %v - latency estimates will not be client-specific", err)
+		return nil
+	}
+
+	log.Printf("[GEOIP] Loaded GeoIP databases (city=%q, asn=%q)", path, asnPath)
+	return resolver
+}
+
+// Lookup returns the coordinates of an IP address, if known
+func (g *geoIPResolver) Lookup(ip net.IP) (*geoPoint, bool) {
+	if g == nil || g.reader == nil || ip == nil {
+		return nil, false
+	}
+
+	var record geoIPCityRecord
+	if err := g.reader.Lookup(ip, &record); err != nil {
+		return nil, false
+	}
+
+	// Records without location data decode as 0,0
+	if record.Location.Latitude == 0 && record.Location.Longitude == 0 {
+		return nil, false
+	}
+
+	return &geoPoint{
+		Latitude:  record.Location.Latitude,
+		Longitude: record.Location.Longitude,
+	}, true
+}
+
+// LookupASN returns the autonomous system number of an IP address, if known
+func (g *geoIPResolver) LookupASN(ip net.IP) (uint, bool) {
+	if g == nil || g.asnReader == nil || ip == nil {
+		return 0, false
+	}
+
+	var record geoIPASNRecord
+	if err := g.asnReader.Lookup(ip, &record); err != nil || record.AutonomousSystemNumber == 0 {
+		return 0, false
+	}
+
+	return record.AutonomousSystemNumber, true
+}
+
+// clientInfo describes where a client connects from
+type clientInfo struct {
+	IP       net.IP
+	Location *geoPoint
+	Network  string
+	ASN      uint
+}
+
+// clientNetwork returns the client's network prefix (/24 for IPv4, /48 for IPv6)
+func clientNetwork(ip net.IP) string {
+	if ip == nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// clientIP extracts the client IP address from a request
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// clientInfo resolves the network, ASN and geographic location of the requesting client
+func (api *ManagementAPI) clientInfo(r *http.Request) *clientInfo {
+	ip := clientIP(r)
+	info := &clientInfo{
+		IP:      ip,
+		Network: clientNetwork(ip),
+	}
+
+	if point, ok := api.geoIP.Lookup(ip); ok {
+		info.Location = point
+	}
+	if asn, ok := api.geoIP.LookupASN(ip); ok {
+		info.ASN = asn
+	}
+
+	return info
+}
+
+// latencySample is a measured round-trip time over a known distance
+type latencySample struct {
+	DistanceKm float64
+	RTTMs      float64
+}
+
+// latencyModel estimates round-trip latency as a linear function of distance
+type latencyModel struct {
+	mu      sync.RWMutex
+	baseMs  float64
+	msPerKm float64
+}
+
+// newLatencyModel creates a latency model from LATENCY_BASE_MS and LATENCY_MS_PER_KM,
+// falling back to physically derived defaults
+func newLatencyModel() *latencyModel {
+	model := &latencyModel{
+		baseMs:  defaultLatencyBaseMs,
+		msPerKm: defaultLatencyMsPerKm,
+	}
+
+	if v, err := strconv.ParseFloat(os.Getenv("LATENCY_BASE_MS"), 64); err == nil && v >= 0 {
+		model.baseMs = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("LATENCY_MS_PER_KM"), 64); err == nil && v > 0 {
+		model.msPerKm = v
+	}
+
+	return model
+}
+
+// Estimate returns the expected RTT in milliseconds for a distance
+func (m *latencyModel) Estimate(distanceKm float64) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return int(math.Round(m.baseMs + m.msPerKm*distanceKm))
+}
+
+// Calibrate fits the model to measured samples using least squares
+// The model is left unchanged if there are too few samples or the fit is not physical
+func (m *latencyModel) Calibrate(samples []latencySample) bool {
+	if len(samples) < minLatencyCalibrationSamples {
+		return false
+	}
+
+	var sumX, sumY, sumXX, sumXY float64
+	for _, s := range samples {
+		sumX += s.DistanceKm
+		sumY += s.RTTMs
+		sumXX += s.DistanceKm * s.DistanceKm
+		sumXY += s.DistanceKm * s.RTTMs
+	}
+
+	n := float64(len(samples))
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return false
+	}
+
+	slope := (n*sumXY - sumX*sumY) / denom
+	intercept := (sumY - slope*sumX) / n
+
+	// Latency can't shrink with distance, and negative base latency is meaningless
+	if slope <= 0 || intercept < 0 {
+		return false
+	}
+
+	m.mu.Lock()
+	m.baseMs = intercept
+	m.msPerKm = slope
+	m.mu.Unlock()
+
+	return true
+}
+
+// managementLocation returns the configured coordinates of the management server
+// from MANAGEMENT_LATITUDE and MANAGEMENT_LONGITUDE
+func managementLocation() (*geoPoint, bool) {
+	lat, errLat := strconv.ParseFloat(os.Getenv("MANAGEMENT_LATITUDE"), 64)
+	lon, errLon := strconv.ParseFloat(os.Getenv("MANAGEMENT_LONGITUDE"), 64)
+	if errLat != nil || errLon != nil {
+		return nil, false
+	}
+	return &geoPoint{Latitude: lat, Longitude: lon}, true
+}
+
+// calibrateLatencyModel fits the latency model to client-reported RTTs and to
+// health-check RTTs measured from the management server to each end-node
+func (api *ManagementAPI) calibrateLatencyModel() {
+	samples, err := api.getClientLatencySamples()
+	if err != nil {
+		log.Printf("[LATENCY] Failed to load client calibration samples: %v", err)
+	}
+
+	if origin, ok := managementLocation(); ok {
+		healthSamples, err := api.getHealthLatencySamples(origin)
+		if err != nil {
+			log.Printf("[LATENCY] Failed to load health-check calibration samples: %v", err)
+		}
+		samples = append(samples, healthSamples...)
+	}
+
+	if api.latency.Calibrate(samples) {
+		log.Printf("[LATENCY] Latency model calibrated from %d samples", len(samples))
+	}
+}
+
+// getHealthLatencySamples returns health-check RTTs from the management server paired with distances
+func (api *ManagementAPI) getHealthLatencySamples(origin *geoPoint) ([]latencySample, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(`
+		SELECT l.latitude, l.longitude, h.response_time_ms
+		FROM servers s
+		JOIN server_locations l ON l.id = s.location_id
+		JOIN LATERAL (
+			SELECT response_time_ms
+			FROM server_health
+			WHERE server_id = s.name AND status = 'healthy'
+			ORDER BY last_check DESC
+			LIMIT 1
+		) h ON true
+		WHERE l.latitude IS NOT NULL AND l.longitude IS NOT NULL
+		  AND h.response_time_ms > 0
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []latencySample
+	for rows.Next() {
+		var point geoPoint
+		var rtt float64
+		if err := rows.Scan(&point.Latitude, &point.Longitude, &rtt); err != nil {
+			return nil, err
+		}
+		samples = append(samples, latencySample{
+			DistanceKm: haversineKm(*origin, point),
+			RTTMs:      rtt,
+		})
+	}
+
+	return samples, rows.Err()
+}
+
+// runLatencyCalibration periodically recalibrates the latency model
+func (api *ManagementAPI) runLatencyCalibration(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	api.calibrateLatencyModel()
+	for range ticker.C {
+		api.calibrateLatencyModel()
+	}
+}
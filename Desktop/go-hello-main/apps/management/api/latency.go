@@ -0,0 +1,338 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	// Only measurements newer than this are aggregated
+	latencyMeasurementWindow = 24 * time.Hour
+
+	// Minimum samples before a percentile is trusted over the model
+	minLatencyPercentileSamples = 5
+
+	// Client measurements used to calibrate the distance model
+	latencyCalibrationWindow = 7 * 24 * time.Hour
+
+	maxLatencyMeasurementsPerRequest = 100
+	maxLatencyRTTMs                  = 10000
+
+	defaultLatencyAggregateInterval = 5 * time.Minute
+
+	latencySubjectLocation = "location"
+	latencySubjectServer   = "server"
+)
+
+// latencyMeasurement is a single client-reported RTT to a server
+type latencyMeasurement struct {
+	ServerID   string `json:"server_id"`
+	RTTMs      int    `json:"rtt_ms"`
+	MeasuredAt int64  `json:"measured_at,omitempty"`
+}
+
+// handleVPNLatency handles client-reported latency measurements
+// POST /vpn/latency
+func (api *ManagementAPI) handleVPNLatency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Validate JWT token
+	username, err := api.validateJWTToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Measurements []latencyMeasurement `json:"measurements"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Validate input
+	if len(req.Measurements) == 0 {
+		http.Error(w, "At least one measurement is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Measurements) > maxLatencyMeasurementsPerRequest {
+		http.Error(w, fmt.Sprintf("At most %d measurements per request", maxLatencyMeasurementsPerRequest), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	for _, m := range req.Measurements {
+		if m.ServerID == "" {
+			http.Error(w, "server_id is required for each measurement", http.StatusBadRequest)
+			return
+		}
+		if m.RTTMs <= 0 || m.RTTMs > maxLatencyRTTMs {
+			http.Error(w, fmt.Sprintf("rtt_ms must be between 1 and %d", maxLatencyRTTMs), http.StatusBadRequest)
+			return
+		}
+		if m.MeasuredAt != 0 {
+			measuredAt := time.Unix(m.MeasuredAt, 0)
+			if measuredAt.After(now.Add(time.Minute)) || measuredAt.Before(now.Add(-latencyMeasurementWindow)) {
+				http.Error(w, "measured_at must be within the last 24 hours", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	client := api.clientInfo(r)
+	stored, err := api.storeLatencyMeasurements(username, client, req.Measurements)
+	if err != nil {
+		log.Printf("[ERROR] Failed to store latency measurements for user %s: %v", username, err)
+		http.Error(w, "Failed to store latency measurements", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(
+		r,
+		"VPN_LATENCY_REPORTED",
+		username,
+		fmt.Sprintf("Latency measurements uploaded - stored=%d submitted=%d", stored, len(req.Measurements)),
+	)
+
+	response := shared.APIResponse{
+		Success: true,
+		Message: "Latency measurements stored successfully",
+		Data: map[string]interface{}{
+			"stored":  stored,
+			"skipped": len(req.Measurements) - stored,
+		},
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// storeLatencyMeasurements stores measurements for known servers and returns how many were stored
+// Measurements for servers without a location are skipped
+func (api *ManagementAPI) storeLatencyMeasurements(username string, client *clientInfo, measurements []latencyMeasurement) (int, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var network, asn, lat, lon interface{}
+	if client.Network != "" {
+		network = client.Network
+	}
+	if client.ASN != 0 {
+		asn = int64(client.ASN)
+	}
+	if client.Location != nil {
+		lat = client.Location.Latitude
+		lon = client.Location.Longitude
+	}
+
+	stored := 0
+	for _, m := range measurements {
+		measuredAt := time.Now()
+		if m.MeasuredAt != 0 {
+			measuredAt = time.Unix(m.MeasuredAt, 0)
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO latency_measurements
+				(username, server_id, location_id, client_network, client_asn,
+				 client_latitude, client_longitude, rtt_ms, measured_at)
+			SELECT $1, s.name, s.location_id, $3, $4, $5, $6, $7, $8
+			FROM servers s
+			WHERE s.name = $2 AND s.location_id IS NOT NULL
+		`, username, m.ServerID, network, asn, lat, lon, m.RTTMs, measuredAt)
+		if err != nil {
+			return 0, err
+		}
+
+		if n, _ := result.RowsAffected(); n > 0 {
+			stored++
+		}
+	}
+
+	return stored, tx.Commit()
+}
+
+// getMeasuredLocationLatency returns the recent median RTT from the client to a location,
+// preferring measurements from the client's own network, then its ASN
+func (api *ManagementAPI) getMeasuredLocationLatency(locationID int, client *clientInfo) (int, bool) {
+	return api.getMeasuredLatency(latencySubjectLocation, strconv.Itoa(locationID), client)
+}
+
+// getMeasuredServerLatency returns the recent median RTT from the client's network or ASN to a server
+func (api *ManagementAPI) getMeasuredServerLatency(serverID string, client *clientInfo) (int, bool) {
+	return api.getMeasuredLatency(latencySubjectServer, serverID, client)
+}
+
+// getMeasuredLatency looks up the client's network aggregate for a subject, then its ASN aggregate
+func (api *ManagementAPI) getMeasuredLatency(subjectType, subjectID string, client *clientInfo) (int, bool) {
+	if client == nil {
+		return 0, false
+	}
+
+	if client.Network != "" {
+		if rtt, ok := api.latencyAggregate(subjectType, subjectID, client.Network, 0); ok {
+			return rtt, true
+		}
+	}
+
+	if client.ASN != 0 {
+		if rtt, ok := api.latencyAggregate(subjectType, subjectID, "", int64(client.ASN)); ok {
+			return rtt, true
+		}
+	}
+
+	return 0, false
+}
+
+// getLocationLatency returns the recent median RTT to a location across all clients
+func (api *ManagementAPI) getLocationLatency(locationID int) (int, bool) {
+	return api.latencyAggregate(latencySubjectLocation, strconv.Itoa(locationID), "", 0)
+}
+
+// latencyAggregate reads a rolled-up median RTT
+// An empty network and zero ASN select the aggregate over all clients
+func (api *ManagementAPI) latencyAggregate(subjectType, subjectID, network string, asn int64) (int, bool) {
+	conn := api.manager.GetDB().GetConnection()
+
+	var samples int
+	var median float64
+	err := conn.QueryRow(`
+		SELECT samples, median_rtt_ms
+		FROM latency_aggregates
+		WHERE subject_type = $1 AND subject_id = $2 AND client_network = $3 AND client_asn = $4
+	`, subjectType, subjectID, network, asn).Scan(&samples, &median)
+	if err == sql.ErrNoRows {
+		return 0, false
+	}
+	if err != nil {
+		log.Printf("[LATENCY] Failed to read latency aggregate: %v", err)
+		return 0, false
+	}
+
+	if samples < minLatencyPercentileSamples {
+		return 0, false
+	}
+
+	return int(median + 0.5), true
+}
+
+// latencyAggregateScopes are the groupings rolled up from latency_measurements
+var latencyAggregateScopes = []struct {
+	subjectType string
+	subject     string // column expression for subject_id
+	network     string // column expression for client_network, or NULL
+	asn         string // column expression for client_asn, or NULL
+	filter      string // measurements included in the scope
+}{
+	{latencySubjectLocation, "location_id::text", "client_network", "NULL", "client_network IS NOT NULL"},
+	{latencySubjectLocation, "location_id::text", "NULL", "client_asn", "client_asn IS NOT NULL"},
+	{latencySubjectLocation, "location_id::text", "NULL", "NULL", "true"},
+	{latencySubjectServer, "server_id", "client_network", "NULL", "client_network IS NOT NULL"},
+	{latencySubjectServer, "server_id", "NULL", "client_asn", "client_asn IS NOT NULL"},
+}
+
+// runLatencyAggregation rebuilds the latency aggregates periodically
+// Interval is set by LATENCY_AGGREGATE_INTERVAL (Go duration)
+func (api *ManagementAPI) runLatencyAggregation() {
+	interval := defaultLatencyAggregateInterval
+	if d, err := time.ParseDuration(os.Getenv("LATENCY_AGGREGATE_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := api.aggregateLatency(); err != nil {
+			log.Printf("[LATENCY] Failed to aggregate latency measurements: %v", err)
+		}
+		<-ticker.C
+	}
+}
+
+// aggregateLatency replaces the aggregates with medians over the measurement window
+// Readers see the previous aggregates until the new ones commit
+func (api *ManagementAPI) aggregateLatency() error {
+	conn := api.manager.GetDB().GetConnection()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM latency_aggregates"); err != nil {
+		return err
+	}
+
+	since := time.Now().Add(-latencyMeasurementWindow)
+	for _, scope := range latencyAggregateScopes {
+		query := fmt.Sprintf(`
+			INSERT INTO latency_aggregates
+				(subject_type, subject_id, client_network, client_asn, samples, median_rtt_ms, computed_at)
+			SELECT '%s', %s, COALESCE(%s, ''), COALESCE(%s, 0),
+			       COUNT(*), percentile_cont(0.5) WITHIN GROUP (ORDER BY rtt_ms), NOW()
+			FROM latency_measurements
+			WHERE measured_at >= $1 AND %s
+			GROUP BY 2, 3, 4
+		`, scope.subjectType, scope.subject, scope.network, scope.asn, scope.filter)
+
+		if _, err := tx.Exec(query, since); err != nil {
+			return fmt.Errorf("%s aggregates: %v", scope.subjectType, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// getClientLatencySamples returns recent client measurements paired with client-to-location distance
+func (api *ManagementAPI) getClientLatencySamples() ([]latencySample, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(`
+		SELECT m.client_latitude, m.client_longitude, l.latitude, l.longitude, m.rtt_ms
+		FROM latency_measurements m
+		JOIN server_locations l ON l.id = m.location_id
+		WHERE m.measured_at >= $1
+		  AND m.client_latitude IS NOT NULL AND m.client_longitude IS NOT NULL
+		  AND l.latitude IS NOT NULL AND l.longitude IS NOT NULL
+	`, time.Now().Add(-latencyCalibrationWindow))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []latencySample
+	for rows.Next() {
+		var client, server geoPoint
+		var rtt float64
+		if err := rows.Scan(&client.Latitude, &client.Longitude, &server.Latitude, &server.Longitude, &rtt); err != nil {
+			return nil, err
+		}
+		samples = append(samples, latencySample{
+			DistanceKm: haversineKm(client, server),
+			RTTMs:      rtt,
+		})
+	}
+
+	return samples, rows.Err()
+}
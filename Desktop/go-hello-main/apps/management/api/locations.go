@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -27,8 +28,8 @@ func (api *ManagementAPI) handleVPNLocations(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Get all server locations with metadata
-	locations, err := api.getServerLocationsWithMetadata()
+	// Get all server locations with metadata, with latency specific to the requesting client
+	locations, err := api.getServerLocationsWithMetadata(api.clientInfo(r))
 	if err != nil {
 		// SECURITY FIX: Log detailed error for admins, return generic message to user
 		log.Printf("[ERROR] Failed to retrieve locations for user %s: %v", username, err)
@@ -37,11 +38,11 @@ func (api *ManagementAPI) handleVPNLocations(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Log the access
-	api.logAudit(
+	api.auditRequest(
+		r,
 		"VPN_LOCATIONS_ACCESSED",
 		username,
 		"Server locations list accessed",
-		r.RemoteAddr,
 	)
 
 	response := shared.APIResponse{
@@ -95,11 +96,11 @@ func (api *ManagementAPI) handleLocationServers(w http.ResponseWriter, r *http.R
 	}
 
 	// Log the access
-	api.logAudit(
+	api.auditRequest(
+		r,
 		"VPN_LOCATION_SERVERS_ACCESSED",
 		username,
 		fmt.Sprintf("Servers for location %d accessed", locationID),
-		r.RemoteAddr,
 	)
 
 	response := shared.APIResponse{
@@ -114,7 +115,8 @@ func (api *ManagementAPI) handleLocationServers(w http.ResponseWriter, r *http.R
 }
 
 // getServerLocationsWithMetadata retrieves all server locations with metadata
-func (api *ManagementAPI) getServerLocationsWithMetadata() ([]shared.ServerLocationWithMetadata, error) {
+// client describes the requesting client, or nil if unknown
+func (api *ManagementAPI) getServerLocationsWithMetadata(client *clientInfo) ([]shared.ServerLocationWithMetadata, error) {
 	db := api.manager.GetDB()
 	conn := db.GetConnection()
 
@@ -132,7 +134,7 @@ func (api *ManagementAPI) getServerLocationsWithMetadata() ([]shared.ServerLocat
 	}
 	defer rows.Close()
 
-	var locations []shared.ServerLocationWithMetadata
+	locations := []shared.ServerLocationWithMetadata{}
 	for rows.Next() {
 		var loc shared.ServerLocationWithMetadata
 		var lat, lon sql.NullFloat64
@@ -159,7 +161,7 @@ func (api *ManagementAPI) getServerLocationsWithMetadata() ([]shared.ServerLocat
 		}
 
 		// Get metadata for this location
-		if err := api.enrichLocationMetadata(&loc); err != nil {
+		if err := api.enrichLocationMetadata(&loc, client); err != nil {
 			// Log error but continue
 			fmt.Printf("Failed to enrich metadata for location %d: %v\n", loc.ID, err)
 		}
@@ -167,16 +169,11 @@ func (api *ManagementAPI) getServerLocationsWithMetadata() ([]shared.ServerLocat
 		locations = append(locations, loc)
 	}
 
-	// If no locations exist, return sample locations
-	if len(locations) == 0 {
-		locations = api.generateSampleLocations()
-	}
-
 	return locations, rows.Err()
 }
 
 // enrichLocationMetadata adds server count, load, and latency information to a location
-func (api *ManagementAPI) enrichLocationMetadata(loc *shared.ServerLocationWithMetadata) error {
+func (api *ManagementAPI) enrichLocationMetadata(loc *shared.ServerLocationWithMetadata, client *clientInfo) error {
 	db := api.manager.GetDB()
 	conn := db.GetConnection()
 
@@ -184,7 +181,7 @@ func (api *ManagementAPI) enrichLocationMetadata(loc *shared.ServerLocationWithM
 	query := `
 		SELECT COUNT(*) as server_count
 		FROM servers
-		WHERE location_id = $1 AND enabled = true
+		WHERE location_id = $1 AND enabled = true AND draining = false
 	`
 
 	err := conn.QueryRow(query, loc.ID).Scan(&loc.ServerCount)
@@ -192,26 +189,37 @@ func (api *ManagementAPI) enrichLocationMetadata(loc *shared.ServerLocationWithM
 		return err
 	}
 
-	// Calculate average load (simplified - based on user count)
+	// Load is the users connected to the location's available servers against their combined capacity
 	loadQuery := `
-		SELECT COUNT(DISTINCT u.username) as user_count
-		FROM users u
-		JOIN servers s ON u.server_id = s.name
-		WHERE s.location_id = $1 AND u.active = true
+		SELECT
+			(SELECT COUNT(DISTINCT c.username)
+			 FROM vpn_connections c
+			 JOIN servers s ON c.server_id = s.name
+			 WHERE s.location_id = $1 AND s.enabled = true AND s.draining = false AND c.disconnected_at IS NULL),
+			(SELECT COALESCE(SUM(capacity), 0)
+			 FROM servers
+			 WHERE location_id = $1 AND enabled = true AND draining = false)
 	`
 
-	var userCount int
-	if err := conn.QueryRow(loadQuery, loc.ID).Scan(&userCount); err == nil {
-		// Assume max 100 users per location as capacity
-		loc.LoadPercentage = float64(userCount) / float64(100) * 100
+	var userCount, capacity int
+	if err := conn.QueryRow(loadQuery, loc.ID).Scan(&userCount, &capacity); err == nil && capacity > 0 {
+		loc.LoadPercentage = float64(userCount) / float64(capacity) * 100
 		if loc.LoadPercentage > 100 {
 			loc.LoadPercentage = 100
 		}
 	}
 
-	// Estimate latency based on geographic distance (simplified)
-	// In production, this should use actual ping measurements
-	loc.EstimatedLatency = api.estimateLatency(loc.Latitude, loc.Longitude)
+	// Prefer recent measurements from the client's network or ASN, then the
+	// distance model, then the median across all clients of this location
+	if rtt, ok := api.getMeasuredLocationLatency(loc.ID, client); ok {
+		loc.EstimatedLatency = rtt
+	} else if client != nil && client.Location != nil {
+		loc.EstimatedLatency = api.estimateLatency(client.Location, loc.Latitude, loc.Longitude)
+	} else if rtt, ok := api.getLocationLatency(loc.ID); ok {
+		loc.EstimatedLatency = rtt
+	} else {
+		loc.EstimatedLatency = api.estimateLatency(nil, loc.Latitude, loc.Longitude)
+	}
 
 	return nil
 }
@@ -225,9 +233,9 @@ func (api *ManagementAPI) getServersForLocation(locationID int) ([]shared.Server
 	// SECURITY FIX: NEVER return server passwords in API responses
 	query := `
 		SELECT s.id, s.name, s.host, s.port, s.username, s.enabled,
-		       s.last_sync, s.server_type, s.management_url, s.created_at
+		       s.last_sync, s.server_type, s.management_url, s.created_at, s.capacity
 		FROM servers s
-		WHERE s.location_id = $1 AND s.enabled = true
+		WHERE s.location_id = $1 AND s.enabled = true AND s.draining = false
 		ORDER BY s.name
 	`
 
@@ -242,6 +250,7 @@ func (api *ManagementAPI) getServersForLocation(locationID int) ([]shared.Server
 		var srv shared.ServerWithHealth
 		var lastSync sql.NullTime
 		var username, managementURL sql.NullString
+		var capacity int
 		// SECURITY FIX: Removed password variable - never expose server credentials
 
 		err := rows.Scan(
@@ -256,6 +265,7 @@ func (api *ManagementAPI) getServersForLocation(locationID int) ([]shared.Server
 			&srv.ServerType,
 			&managementURL,
 			&srv.CreatedAt,
+			&capacity,
 		)
 
 		if err != nil {
@@ -292,8 +302,8 @@ func (api *ManagementAPI) getServersForLocation(locationID int) ([]shared.Server
 		userCount, err := api.getServerUserCount(srv.Name)
 		if err == nil {
 			srv.UserCount = userCount
-			// Calculate load percentage (assume max 50 users per server)
-			srv.LoadPercent = float64(userCount) / float64(50) * 100
+			// Calculate load percentage against the configured server capacity
+			srv.LoadPercent = float64(userCount) / float64(capacity) * 100
 			if srv.LoadPercent > 100 {
 				srv.LoadPercent = 100
 			}
@@ -341,15 +351,15 @@ func (api *ManagementAPI) getServerHealth(serverID string) (*shared.ServerHealth
 	return &health, nil
 }
 
-// getServerUserCount returns the number of active users on a server
+// getServerUserCount returns the number of users with an open session on a server
 func (api *ManagementAPI) getServerUserCount(serverID string) (int, error) {
 	db := api.manager.GetDB()
 	conn := db.GetConnection()
 
 	query := `
-		SELECT COUNT(*)
-		FROM users
-		WHERE server_id = $1 AND active = true
+		SELECT COUNT(DISTINCT username)
+		FROM vpn_connections
+		WHERE server_id = $1 AND disconnected_at IS NULL
 	`
 
 	var count int
@@ -357,81 +367,20 @@ func (api *ManagementAPI) getServerUserCount(serverID string) (int, error) {
 	return count, err
 }
 
-// estimateLatency estimates network latency based on geographic coordinates
-// This is a simplified estimation - in production, use actual measurements
-func (api *ManagementAPI) estimateLatency(latitude, longitude float64) int {
-	// Base latency
-	baseLatency := 50
-
-	// Add latency based on distance (simplified)
-	// In production, calculate actual geographic distance and use real measurements
-	// For now, return a range based on latitude to simulate different regions
-	if latitude > 50 || latitude < -50 {
-		return baseLatency + 100 // Far regions
-	} else if latitude > 30 || latitude < -30 {
-		return baseLatency + 50 // Medium distance
+// estimateLatency estimates network latency from the client to a server location
+// using great-circle distance and the calibrated latency model
+func (api *ManagementAPI) estimateLatency(client *geoPoint, latitude, longitude float64) int {
+	if client == nil {
+		// Without a client location, fall back to a rough regional estimate
+		baseLatency := 50
+		if latitude > 50 || latitude < -50 {
+			return baseLatency + 100 // Far regions
+		} else if latitude > 30 || latitude < -30 {
+			return baseLatency + 50 // Medium distance
+		}
+		return baseLatency // Close regions
 	}
-	return baseLatency // Close regions
-}
 
-// generateSampleLocations creates sample locations for demonstration
-func (api *ManagementAPI) generateSampleLocations() []shared.ServerLocationWithMetadata {
-	return []shared.ServerLocationWithMetadata{
-		{
-			ServerLocation: shared.ServerLocation{
-				ID:          1,
-				Country:     "United States",
-				City:        "New York",
-				CountryCode: "US",
-				Latitude:    40.7128,
-				Longitude:   -74.0060,
-				Enabled:     true,
-			},
-			ServerCount:      3,
-			LoadPercentage:   45.5,
-			EstimatedLatency: 25,
-		},
-		{
-			ServerLocation: shared.ServerLocation{
-				ID:          2,
-				Country:     "United Kingdom",
-				City:        "London",
-				CountryCode: "GB",
-				Latitude:    51.5074,
-				Longitude:   -0.1278,
-				Enabled:     true,
-			},
-			ServerCount:      2,
-			LoadPercentage:   62.3,
-			EstimatedLatency: 85,
-		},
-		{
-			ServerLocation: shared.ServerLocation{
-				ID:          3,
-				Country:     "Singapore",
-				City:        "Singapore",
-				CountryCode: "SG",
-				Latitude:    1.3521,
-				Longitude:   103.8198,
-				Enabled:     true,
-			},
-			ServerCount:      2,
-			LoadPercentage:   38.7,
-			EstimatedLatency: 180,
-		},
-		{
-			ServerLocation: shared.ServerLocation{
-				ID:          4,
-				Country:     "Germany",
-				City:        "Frankfurt",
-				CountryCode: "DE",
-				Latitude:    50.1109,
-				Longitude:   8.6821,
-				Enabled:     true,
-			},
-			ServerCount:      4,
-			LoadPercentage:   55.2,
-			EstimatedLatency: 95,
-		},
-	}
+	distance := haversineKm(*client, geoPoint{Latitude: latitude, Longitude: longitude})
+	return api.latency.Estimate(distance)
 }
@@ -0,0 +1,511 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// locationRequest is the body for creating or updating a server location
+type locationRequest struct {
+	Country     string   `json:"country"`
+	City        string   `json:"city"`
+	CountryCode string   `json:"country_code"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+	Enabled     *bool    `json:"enabled"`
+}
+
+// handleAdminLocations handles listing and creating server locations
+// GET  /api/locations
+// POST /api/locations
+func (api *ManagementAPI) handleAdminLocations(w http.ResponseWriter, r *http.Request) {
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		api.handleListAllLocations(w, r)
+	case "POST":
+		api.handleCreateLocation(w, r, admin)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAdminLocationByID handles operations on a single server location
+// GET    /api/locations/{id}
+// PUT    /api/locations/{id}
+// DELETE /api/locations/{id}
+// POST   /api/locations/{id}/enable
+// POST   /api/locations/{id}/disable
+// POST   /api/locations/{id}/servers
+func (api *ManagementAPI) handleAdminLocationByID(w http.ResponseWriter, r *http.Request) {
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	pathParts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/locations/"), "/"), "/")
+	if len(pathParts) < 1 || pathParts[0] == "" {
+		http.Error(w, "Location ID required", http.StatusBadRequest)
+		return
+	}
+
+	locationID, err := strconv.Atoi(pathParts[0])
+	if err != nil {
+		http.Error(w, "Invalid location ID", http.StatusBadRequest)
+		return
+	}
+
+	if len(pathParts) == 2 {
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		switch pathParts[1] {
+		case "enable":
+			api.handleSetLocationEnabled(w, r, admin, locationID, true)
+		case "disable":
+			api.handleSetLocationEnabled(w, r, admin, locationID, false)
+		case "servers":
+			api.handleAssignLocationServers(w, r, admin, locationID)
+		default:
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		api.handleGetLocation(w, r, locationID)
+	case "PUT":
+		api.handleUpdateLocation(w, r, admin, locationID)
+	case "DELETE":
+		api.handleDeleteLocation(w, r, admin, locationID)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleListAllLocations lists every location, including disabled ones
+func (api *ManagementAPI) handleListAllLocations(w http.ResponseWriter, r *http.Request) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(`
+		SELECT id, country, city, country_code, latitude, longitude, enabled
+		FROM server_locations
+		ORDER BY country, city
+	`)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list locations: %v", err)
+		http.Error(w, "Failed to list locations", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	locations := []shared.ServerLocation{}
+	for rows.Next() {
+		loc, err := scanServerLocation(rows)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan location: %v", err)
+			http.Error(w, "Failed to list locations", http.StatusInternalServerError)
+			return
+		}
+		locations = append(locations, *loc)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("[ERROR] Failed to list locations: %v", err)
+		http.Error(w, "Failed to list locations", http.StatusInternalServerError)
+		return
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Locations retrieved successfully",
+		Data:      locations,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleGetLocation returns a single location
+func (api *ManagementAPI) handleGetLocation(w http.ResponseWriter, r *http.Request, locationID int) {
+	loc, err := api.getServerLocation(locationID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Location not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to get location %d: %v", locationID, err)
+		http.Error(w, "Failed to retrieve location", http.StatusInternalServerError)
+		return
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Location retrieved successfully",
+		Data:      loc,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleCreateLocation creates a new server location
+func (api *ManagementAPI) handleCreateLocation(w http.ResponseWriter, r *http.Request, admin string) {
+	var req locationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	loc, err := validateLocationRequest(&req, nil)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid input: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	err = conn.QueryRow(`
+		INSERT INTO server_locations (country, city, country_code, latitude, longitude, enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $7)
+		RETURNING id
+	`, loc.Country, loc.City, loc.CountryCode, loc.Latitude, loc.Longitude, loc.Enabled, time.Now()).Scan(&loc.ID)
+	if isUniqueViolation(err) {
+		http.Error(w, fmt.Sprintf("Location %s, %s already exists", loc.City, loc.CountryCode), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to create location: %v", err)
+		http.Error(w, "Failed to create location", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(
+		r,
+		"LOCATION_CREATED",
+		admin,
+		fmt.Sprintf("Location %d created - %s, %s (%s)", loc.ID, loc.City, loc.Country, loc.CountryCode),
+	)
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Location created successfully",
+		Data:      loc,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleUpdateLocation updates an existing server location
+// Fields omitted from the request keep their current values
+func (api *ManagementAPI) handleUpdateLocation(w http.ResponseWriter, r *http.Request, admin string, locationID int) {
+	var req locationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := api.getServerLocation(locationID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Location not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to get location %d: %v", locationID, err)
+		http.Error(w, "Failed to update location", http.StatusInternalServerError)
+		return
+	}
+
+	loc, err := validateLocationRequest(&req, existing)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid input: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	_, err = conn.Exec(`
+		UPDATE server_locations
+		SET country = $1, city = $2, country_code = $3, latitude = $4, longitude = $5,
+		    enabled = $6, updated_at = $7
+		WHERE id = $8
+	`, loc.Country, loc.City, loc.CountryCode, loc.Latitude, loc.Longitude, loc.Enabled, time.Now(), locationID)
+	if isUniqueViolation(err) {
+		http.Error(w, fmt.Sprintf("Location %s, %s already exists", loc.City, loc.CountryCode), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to update location %d: %v", locationID, err)
+		http.Error(w, "Failed to update location", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(
+		r,
+		"LOCATION_UPDATED",
+		admin,
+		fmt.Sprintf("Location %d updated - %s, %s (%s) enabled=%t", locationID, loc.City, loc.Country, loc.CountryCode, loc.Enabled),
+	)
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Location updated successfully",
+		Data:      loc,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleSetLocationEnabled enables or disables a location
+func (api *ManagementAPI) handleSetLocationEnabled(w http.ResponseWriter, r *http.Request, admin string, locationID int, enabled bool) {
+	conn := api.manager.GetDB().GetConnection()
+
+	result, err := conn.Exec(`
+		UPDATE server_locations SET enabled = $1, updated_at = $2 WHERE id = $3
+	`, enabled, time.Now(), locationID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to set enabled=%t on location %d: %v", enabled, locationID, err)
+		http.Error(w, "Failed to update location", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Location not found", http.StatusNotFound)
+		return
+	}
+
+	action := "LOCATION_ENABLED"
+	if !enabled {
+		action = "LOCATION_DISABLED"
+	}
+	api.auditRequest(r, action, admin, fmt.Sprintf("Location %d enabled=%t", locationID, enabled))
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Location %d updated successfully", locationID),
+		Data:      map[string]interface{}{"id": locationID, "enabled": enabled},
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleDeleteLocation deletes a location that has no servers assigned
+func (api *ManagementAPI) handleDeleteLocation(w http.ResponseWriter, r *http.Request, admin string, locationID int) {
+	conn := api.manager.GetDB().GetConnection()
+
+	var serverCount int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM servers WHERE location_id = $1", locationID).Scan(&serverCount); err != nil {
+		log.Printf("[ERROR] Failed to count servers for location %d: %v", locationID, err)
+		http.Error(w, "Failed to delete location", http.StatusInternalServerError)
+		return
+	}
+	if serverCount > 0 {
+		http.Error(w, fmt.Sprintf("Location %d still has %d servers assigned; reassign or disable it instead", locationID, serverCount), http.StatusConflict)
+		return
+	}
+
+	result, err := conn.Exec("DELETE FROM server_locations WHERE id = $1", locationID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete location %d: %v", locationID, err)
+		http.Error(w, "Failed to delete location", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Location not found", http.StatusNotFound)
+		return
+	}
+
+	api.auditRequest(r, "LOCATION_DELETED", admin, fmt.Sprintf("Location %d deleted", locationID))
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Location %d deleted successfully", locationID),
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleAssignLocationServers assigns servers to a location
+func (api *ManagementAPI) handleAssignLocationServers(w http.ResponseWriter, r *http.Request, admin string, locationID int) {
+	var req struct {
+		ServerIDs []string `json:"server_ids"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.ServerIDs) == 0 {
+		http.Error(w, "server_ids is required", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := api.getServerLocation(locationID); err == sql.ErrNoRows {
+		http.Error(w, "Location not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("[ERROR] Failed to get location %d: %v", locationID, err)
+		http.Error(w, "Failed to assign servers", http.StatusInternalServerError)
+		return
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	tx, err := conn.Begin()
+	if err != nil {
+		log.Printf("[ERROR] Failed to begin transaction: %v", err)
+		http.Error(w, "Failed to assign servers", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, serverID := range req.ServerIDs {
+		result, err := tx.Exec("UPDATE servers SET location_id = $1 WHERE name = $2", locationID, serverID)
+		if err != nil {
+			log.Printf("[ERROR] Failed to assign server %s to location %d: %v", serverID, locationID, err)
+			http.Error(w, "Failed to assign servers", http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, fmt.Sprintf("Server '%s' not found", serverID), http.StatusNotFound)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("[ERROR] Failed to commit server assignment: %v", err)
+		http.Error(w, "Failed to assign servers", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(
+		r,
+		"LOCATION_SERVERS_ASSIGNED",
+		admin,
+		fmt.Sprintf("Servers %s assigned to location %d", strings.Join(req.ServerIDs, ","), locationID),
+	)
+
+	response := shared.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d servers assigned to location %d", len(req.ServerIDs), locationID),
+		Data: map[string]interface{}{
+			"location_id": locationID,
+			"server_ids":  req.ServerIDs,
+		},
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// validateLocationRequest validates a create/update request and merges it over existing (if any)
+func validateLocationRequest(req *locationRequest, existing *shared.ServerLocation) (*shared.ServerLocation, error) {
+	loc := &shared.ServerLocation{Enabled: true}
+	if existing != nil {
+		*loc = *existing
+	}
+
+	if req.Country != "" {
+		loc.Country = strings.TrimSpace(req.Country)
+	}
+	if req.City != "" {
+		loc.City = strings.TrimSpace(req.City)
+	}
+	if req.CountryCode != "" {
+		loc.CountryCode = strings.ToUpper(strings.TrimSpace(req.CountryCode))
+	}
+	if req.Latitude != nil {
+		loc.Latitude = *req.Latitude
+	}
+	if req.Longitude != nil {
+		loc.Longitude = *req.Longitude
+	}
+	if req.Enabled != nil {
+		loc.Enabled = *req.Enabled
+	}
+
+	if loc.Country == "" || len(loc.Country) > 100 {
+		return nil, fmt.Errorf("country must be 1-100 characters")
+	}
+	if loc.City == "" || len(loc.City) > 100 {
+		return nil, fmt.Errorf("city must be 1-100 characters")
+	}
+	if !shared.IsValidCountryCode(loc.CountryCode) {
+		return nil, fmt.Errorf("country_code must be an ISO 3166-1 alpha-2 code")
+	}
+	if existing == nil && (req.Latitude == nil || req.Longitude == nil) {
+		return nil, fmt.Errorf("latitude and longitude are required")
+	}
+	if loc.Latitude < -90 || loc.Latitude > 90 {
+		return nil, fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if loc.Longitude < -180 || loc.Longitude > 180 {
+		return nil, fmt.Errorf("longitude must be between -180 and 180")
+	}
+
+	return loc, nil
+}
+
+// getServerLocation loads a single location by ID
+func (api *ManagementAPI) getServerLocation(locationID int) (*shared.ServerLocation, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	row := conn.QueryRow(`
+		SELECT id, country, city, country_code, latitude, longitude, enabled
+		FROM server_locations
+		WHERE id = $1
+	`, locationID)
+
+	return scanServerLocation(row)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanServerLocation scans a server_locations row
+func scanServerLocation(row rowScanner) (*shared.ServerLocation, error) {
+	var loc shared.ServerLocation
+	var lat, lon sql.NullFloat64
+
+	if err := row.Scan(&loc.ID, &loc.Country, &loc.City, &loc.CountryCode, &lat, &lon, &loc.Enabled); err != nil {
+		return nil, err
+	}
+
+	if lat.Valid {
+		loc.Latitude = lat.Float64
+	}
+	if lon.Valid {
+		loc.Longitude = lon.Float64
+	}
+
+	return &loc, nil
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "duplicate key value violates unique constraint")
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"vpnmanager/pkg/shared"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics updated as requests are served; database-backed gauges are read at
+// scrape time by dbMetricsCollector
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vpnmanager_http_requests_total",
+		Help: "HTTP requests served, by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vpnmanager_http_request_duration_seconds",
+		Help:    "HTTP request latency, by route pattern, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	rateLimitRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vpnmanager_rate_limit_rejections_total",
+		Help: "Requests rejected by the per-IP rate limiter, by route pattern.",
+	}, []string{"route"})
+
+	loginsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vpnmanager_logins_total",
+		Help: "Password login attempts, by result (success or failure) and failure reason.",
+	}, []string{"result", "reason"})
+
+	otpSendsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "vpnmanager_otp_sends_total",
+		Help: "OTP send attempts, by result (success or failure).",
+	}, []string{"result"})
+
+	endNodeRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "vpnmanager_endnode_request_duration_seconds",
+		Help:    "Latency of calls to end-node APIs, by end-node address, method and status code (error when no response).",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endnode", "method", "status"})
+)
+
+var (
+	activeSessionsDesc = prometheus.NewDesc("vpnmanager_active_sessions",
+		"Open VPN sessions, by end-node.", []string{"server_id"}, nil)
+	endNodeHealthDesc = prometheus.NewDesc("vpnmanager_endnode_health",
+		"Latest health check status of each enabled end-node; always 1, the status is the label.", []string{"server_id", "status"}, nil)
+)
+
+func init() {
+	prometheus.MustRegister(
+		httpRequestsTotal,
+		httpRequestDuration,
+		rateLimitRejectionsTotal,
+		loginsTotal,
+		otpSendsTotal,
+		endNodeRequestDuration,
+	)
+}
+
+// unmatchedRoute labels requests no route pattern matched, keeping label values bounded
+const unmatchedRoute = "unmatched"
+
+// routeKey is the context key holding a request's matched route pattern
+type routeKey struct{}
+
+// withRoute attaches the matched route pattern to the request
+func withRoute(r *http.Request, route string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), routeKey{}, route))
+}
+
+// requestRoute returns the pattern attached by withRoute, if any
+func requestRoute(r *http.Request) string {
+	route, _ := r.Context().Value(routeKey{}).(string)
+	if route == "" {
+		return unmatchedRoute
+	}
+	return route
+}
+
+// instrumentRequests records the count and latency of every request served by mux
+// Routes are labelled with the mux pattern rather than the path, so IDs in URLs do
+// not create new series
+func (api *ManagementAPI) instrumentRequests(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		route := unmatchedRoute
+		if _, pattern := mux.Handler(r); pattern != "" {
+			route = pattern
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, withRoute(r, route))
+
+		status := strconv.Itoa(rec.status())
+		httpRequestsTotal.WithLabelValues(route, r.Method, status).Inc()
+		httpRequestDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusRecorder remembers the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+// WriteHeader records the status code before sending it
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.code == 0 {
+		s.code = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Write records an implicit 200 when the handler did not set a status
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.code == 0 {
+		s.code = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses such as audit exports working
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// status returns the recorded status, 200 when nothing was written
+func (s *statusRecorder) status() int {
+	if s.code == 0 {
+		return http.StatusOK
+	}
+	return s.code
+}
+
+// metricsTransport times requests sent to end-nodes
+type metricsTransport struct {
+	next http.RoundTripper
+}
+
+// RoundTrip sends the request and observes its latency
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	endNodeRequestDuration.WithLabelValues(req.URL.Host, req.Method, status).Observe(time.Since(start).Seconds())
+
+	return resp, err
+}
+
+// subscribeMetrics counts login outcomes published on the bus
+func subscribeMetrics(bus *eventBus) {
+	bus.Subscribe("metrics", func(envelope shared.EventEnvelope) {
+		switch event := envelope.Event.(type) {
+		case shared.LoginSucceededEvent:
+			loginsTotal.WithLabelValues("success", "").Inc()
+		case shared.LoginFailedEvent:
+			loginsTotal.WithLabelValues("failure", event.Reason).Inc()
+		}
+	}, shared.EventLoginSucceeded, shared.EventLoginFailed)
+}
+
+// dbMetricsCollector reports gauges that live in the database
+type dbMetricsCollector struct {
+	api *ManagementAPI
+}
+
+// Describe implements prometheus.Collector
+func (c dbMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- activeSessionsDesc
+	ch <- endNodeHealthDesc
+}
+
+// Collect implements prometheus.Collector
+// A failed query only drops its own series so the rest of the scrape still succeeds
+func (c dbMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	sessions, err := c.api.countActiveSessions()
+	if err != nil {
+		log.Printf("[METRICS] Failed to count active sessions: %v", err)
+	}
+	for serverID, n := range sessions {
+		ch <- prometheus.MustNewConstMetric(activeSessionsDesc, prometheus.GaugeValue, float64(n), serverID)
+	}
+
+	health, err := c.api.loadEndNodeHealth()
+	if err != nil {
+		log.Printf("[METRICS] Failed to load end-node health: %v", err)
+	}
+	for serverID, status := range health {
+		if status == "" {
+			status = "unknown"
+		}
+		ch <- prometheus.MustNewConstMetric(endNodeHealthDesc, prometheus.GaugeValue, 1, serverID, status)
+	}
+}
+
+// countActiveSessions returns the number of open sessions on each end-node
+func (api *ManagementAPI) countActiveSessions() (map[string]int, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(`
+		SELECT server_id, COUNT(*)
+		FROM vpn_connections
+		WHERE disconnected_at IS NULL AND status = $1
+		GROUP BY server_id
+	`, shared.SessionStateConnected)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var serverID string
+		var n int
+		if err := rows.Scan(&serverID, &n); err != nil {
+			return nil, err
+		}
+		counts[serverID] = n
+	}
+
+	return counts, rows.Err()
+}
+
+// bypassMiddleware serves path with handler directly and everything else with next
+// Scrapes are frequent and machine-driven, so they skip rate limiting and request logging
+func bypassMiddleware(path string, handler, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == path {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// dbMetricsOnce guards registration of the database-backed collector
+var dbMetricsOnce sync.Once
+
+// metricsHandler serves the Prometheus exposition format
+// When METRICS_TOKEN is set, scrapers must send it as a bearer token
+func (api *ManagementAPI) metricsHandler() http.Handler {
+	// Registering twice panics, and Start may run more than once per process
+	dbMetricsOnce.Do(func() {
+		prometheus.MustRegister(dbMetricsCollector{api: api})
+	})
+
+	token := os.Getenv("METRICS_TOKEN")
+	handler := promhttp.Handler()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" {
+			expected := "Bearer " + token
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// errStaleStatus is returned when an uploaded snapshot is not newer than the last one
+var errStaleStatus = fmt.Errorf("status snapshot is not newer than the last upload")
+
+// openVPNClientRow is the stored state of a live OpenVPN connection
+type openVPNClientRow struct {
+	CommonName     string
+	ConnectedSince time.Time
+	Username       string
+	BytesReceived  int64
+	BytesSent      int64
+	ConnectionID   sql.NullInt64
+	LastStatusAt   time.Time
+}
+
+// openVPNUsage is usage derived for one user from a snapshot
+type openVPNUsage struct {
+	BytesIn  int64
+	BytesOut int64
+	Duration int
+}
+
+// handleEndNodeOpenVPNStatus ingests OpenVPN status output uploaded by an end-node
+// POST /api/endnodes/{server_id}/openvpn-status
+// Body: {"output": "<status-version 2/3 file or management interface status output>"}
+func (api *ManagementAPI) handleEndNodeOpenVPNStatus(w http.ResponseWriter, r *http.Request, serverID string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !api.requireEndNodeKey(w, r, serverID) {
+		return
+	}
+
+	if _, err := api.findEndNode(serverID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Output string `json:"output"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	status, err := shared.ParseOpenVPNStatus(strings.NewReader(req.Output))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid OpenVPN status: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	result, err := api.ingestOpenVPNStatus(serverID, status)
+	if err == errStaleStatus {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[OPENVPN] Failed to ingest status from %s: %v", serverID, err)
+		http.Error(w, "Failed to ingest status", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(
+		r,
+		"ENDNODE_STATUS_INGESTED",
+		"",
+		fmt.Sprintf("OpenVPN status from %s - clients=%d opened=%d closed=%d bytes_in=%d bytes_out=%d",
+			serverID, result.Clients, result.SessionsOpened, result.SessionsClosed, result.BytesIn, result.BytesOut),
+	)
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "OpenVPN status ingested successfully",
+		Data:      result,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ingestOpenVPNStatus derives sessions and usage from an end-node snapshot
+// Counters are diffed against the previous snapshot of the same connection; connections
+// missing from the snapshot have ended and their sessions are closed
+func (api *ManagementAPI) ingestOpenVPNStatus(serverID string, status *shared.OpenVPNStatus) (*shared.OpenVPNIngestResult, error) {
+	statusTime := status.Time
+	if statusTime.IsZero() {
+		statusTime = time.Now()
+	}
+
+	result := &shared.OpenVPNIngestResult{
+		ServerID:   serverID,
+		StatusTime: statusTime,
+		Clients:    len(status.Clients),
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serialize uploads per end-node
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", "openvpn:"+serverID); err != nil {
+		return nil, err
+	}
+
+	previous, err := loadOpenVPNClients(tx, serverID)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range previous {
+		if !statusTime.After(row.LastStatusAt) {
+			return nil, errStaleStatus
+		}
+	}
+
+	usage := map[string]*openVPNUsage{}
+	now := time.Now()
+
+	for _, client := range status.Clients {
+		key := openVPNClientKey(client.CommonName, client.ConnectedSince)
+		prev, seen := previous[key]
+		delete(previous, key)
+
+		username := client.Account()
+
+		// bytes_in/bytes_out are from the user's perspective
+		bytesIn, bytesOut := client.BytesSent, client.BytesReceived
+		since := client.ConnectedSince
+		connectionID := sql.NullInt64{}
+		if seen {
+			// Counters only reset on reconnect, which changes the key; a drop means
+			// the end-node restarted its counters, so count the new value in full
+			if client.BytesSent >= prev.BytesSent {
+				bytesIn -= prev.BytesSent
+			}
+			if client.BytesReceived >= prev.BytesReceived {
+				bytesOut -= prev.BytesReceived
+			}
+			since = prev.LastStatusAt
+			connectionID = prev.ConnectionID
+		}
+		duration := int(statusTime.Sub(since).Seconds())
+		if duration < 0 {
+			duration = 0
+		}
+
+		id, opened, err := trackOpenVPNSession(tx, username, serverID, client, connectionID, statusTime)
+		if err != nil {
+			return nil, err
+		}
+		if opened {
+			result.SessionsOpened++
+		}
+
+		if bytesIn > 0 || bytesOut > 0 || duration > 0 {
+			streamID := fmt.Sprintf("openvpn:%s:%s:%d", serverID, client.CommonName, client.ConnectedSince.Unix())
+			inserted, err := insertVPNStatistics(tx, username, serverID, streamID, statusTime.Unix(), bytesIn, bytesOut, duration, now)
+			if err != nil {
+				return nil, err
+			}
+
+			// A status file uploaded twice adds nothing the second time
+			if inserted {
+				u := usage[username]
+				if u == nil {
+					u = &openVPNUsage{}
+					usage[username] = u
+				}
+				u.BytesIn += bytesIn
+				u.BytesOut += bytesOut
+				u.Duration += duration
+				result.BytesIn += bytesIn
+				result.BytesOut += bytesOut
+			}
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO openvpn_clients
+				(server_id, common_name, connected_since, username, real_address, virtual_address,
+				 bytes_received, bytes_sent, connection_id, last_status_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (server_id, common_name, connected_since) DO UPDATE
+			SET username = EXCLUDED.username,
+			    real_address = EXCLUDED.real_address,
+			    virtual_address = EXCLUDED.virtual_address,
+			    bytes_received = EXCLUDED.bytes_received,
+			    bytes_sent = EXCLUDED.bytes_sent,
+			    connection_id = EXCLUDED.connection_id,
+			    last_status_at = EXCLUDED.last_status_at
+		`, serverID, client.CommonName, client.ConnectedSince, username, client.RealAddress,
+			client.VirtualAddress, client.BytesReceived, client.BytesSent, id, statusTime)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Anything left in previous disconnected since the last snapshot
+	for _, row := range previous {
+		if row.ConnectionID.Valid {
+			_, err := tx.Exec(`
+				UPDATE vpn_connections
+				SET status = $1, disconnected_at = $2, close_reason = $3,
+				    duration_seconds = CASE
+				        WHEN connected_at IS NULL THEN 0
+				        ELSE GREATEST(0, EXTRACT(EPOCH FROM ($2 - connected_at))::INTEGER)
+				    END
+				WHERE id = $4 AND disconnected_at IS NULL
+			`, shared.SessionStateDisconnected, row.LastStatusAt, closeReasonDisconnected, row.ConnectionID.Int64)
+			if err != nil {
+				return nil, err
+			}
+			result.SessionsClosed++
+		}
+
+		_, err := tx.Exec(`
+			DELETE FROM openvpn_clients
+			WHERE server_id = $1 AND common_name = $2 AND connected_since = $3
+		`, serverID, row.CommonName, row.ConnectedSince)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for username, u := range usage {
+		api.recordIngestedUsage(username, serverID, u.BytesIn, u.BytesOut, u.Duration)
+	}
+
+	return result, nil
+}
+
+// trackOpenVPNSession keeps the vpn_connections session backing an OpenVPN connection alive
+// On first sight the user's open session on the server is adopted, or a new one is opened
+func trackOpenVPNSession(tx *sql.Tx, username, serverID string, client shared.OpenVPNClient, connectionID sql.NullInt64, statusTime time.Time) (int64, bool, error) {
+	if connectionID.Valid {
+		result, err := tx.Exec(`
+			UPDATE vpn_connections SET last_seen_at = $1
+			WHERE id = $2 AND disconnected_at IS NULL
+		`, statusTime, connectionID.Int64)
+		if err != nil {
+			return 0, false, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			return connectionID.Int64, false, nil
+		}
+		// The session was closed elsewhere (e.g. reaped) while OpenVPN kept it up
+	}
+
+	var id int64
+	err := tx.QueryRow(`
+		SELECT id FROM vpn_connections
+		WHERE username = $1 AND server_id = $2 AND disconnected_at IS NULL
+		  AND id NOT IN (SELECT connection_id FROM openvpn_clients WHERE connection_id IS NOT NULL)
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE
+	`, username, serverID).Scan(&id)
+	if err == nil {
+		_, err = tx.Exec(`
+			UPDATE vpn_connections
+			SET status = $1, connected_at = COALESCE(connected_at, $2), last_seen_at = $3,
+			    ip_address = COALESCE(ip_address, $4)
+			WHERE id = $5
+		`, shared.SessionStateConnected, client.ConnectedSince, statusTime, nullString(client.VirtualAddress), id)
+		return id, false, err
+	}
+	if err != sql.ErrNoRows {
+		return 0, false, err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO vpn_connections
+			(username, status, server_id, ip_address, connected_at, last_seen_at, duration_seconds, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 0, $5)
+		RETURNING id
+	`, username, shared.SessionStateConnected, serverID, nullString(client.VirtualAddress),
+		client.ConnectedSince, statusTime).Scan(&id)
+	if err != nil {
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
+
+// loadOpenVPNClients returns the stored connections for an end-node keyed by openVPNClientKey
+func loadOpenVPNClients(tx *sql.Tx, serverID string) (map[string]*openVPNClientRow, error) {
+	rows, err := tx.Query(`
+		SELECT common_name, connected_since, username, bytes_received, bytes_sent,
+		       connection_id, last_status_at
+		FROM openvpn_clients
+		WHERE server_id = $1
+	`, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := map[string]*openVPNClientRow{}
+	for rows.Next() {
+		var row openVPNClientRow
+		if err := rows.Scan(&row.CommonName, &row.ConnectedSince, &row.Username, &row.BytesReceived,
+			&row.BytesSent, &row.ConnectionID, &row.LastStatusAt); err != nil {
+			return nil, err
+		}
+		clients[openVPNClientKey(row.CommonName, row.ConnectedSince)] = &row
+	}
+
+	return clients, rows.Err()
+}
+
+// openVPNClientKey identifies one OpenVPN connection on an end-node
+func openVPNClientKey(commonName string, connectedSince time.Time) string {
+	return fmt.Sprintf("%s|%d", commonName, connectedSince.Unix())
+}
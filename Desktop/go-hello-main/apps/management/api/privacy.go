@@ -0,0 +1,382 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"vpnmanager/pkg/shared"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errErasurePending = errors.New("an erasure request is already open for this user")
+
+// HandleExport returns everything held about the authenticated user as a JSON archive
+// GET /auth/me/export (behind JWTAuthMiddleware)
+func (h *AuthHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	phoneNumber, ok := r.Context().Value("phone_number").(string)
+	if !ok || phoneNumber == "" {
+		h.sendError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	export, err := buildUserDataExport(h.db, phoneNumber)
+	if err == sql.ErrNoRows {
+		h.sendError(w, "Account not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[PRIVACY] Failed to export data for %s: %v", phoneNumber, err)
+		h.sendError(w, "Failed to export account data", http.StatusInternalServerError)
+		return
+	}
+
+	h.logAuditEvent(r, "USER_DATA_EXPORTED", phoneNumber,
+		fmt.Sprintf("Data export with %d connections and %d audit events",
+			len(export.ConnectionHistory), len(export.AuditEvents)))
+
+	filename := fmt.Sprintf("vpn-account-export-%s.json", export.GeneratedAt.Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(export)
+}
+
+// HandleErasureRequest records the authenticated user's request to erase their data
+// POST /auth/me/erasure (behind JWTAuthMiddleware)
+// Body: {"password": "...", "reason": "..."}
+// An administrator executes the request (POST /api/erasures/{id}/execute)
+func (h *AuthHandler) HandleErasureRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		h.sendError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	phoneNumber, ok := r.Context().Value("phone_number").(string)
+	if !ok || phoneNumber == "" {
+		h.sendError(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+
+	// Erasure is irreversible, so a stolen token alone must not be enough
+	var passwordHash string
+	err := h.db.QueryRow("SELECT password_hash FROM auth_users WHERE phone_number = $1", phoneNumber).Scan(&passwordHash)
+	if err == sql.ErrNoRows {
+		h.sendError(w, "Account not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("[PRIVACY] Database error loading %s: %v", phoneNumber, err)
+		h.sendError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)) != nil {
+		h.logAuditEvent(r, "DATA_ERASURE_DENIED", phoneNumber, "Erasure request with invalid password")
+		h.sendError(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+
+	request, err := createErasureRequest(h.db, phoneNumber, phoneNumber, req.Reason)
+	if err == errErasurePending {
+		h.sendError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[PRIVACY] Failed to record erasure request for %s: %v", phoneNumber, err)
+		h.sendError(w, "Failed to record erasure request", http.StatusInternalServerError)
+		return
+	}
+
+	// The audit log outlives the erasure, so it only ever names the pseudonym
+	h.logAuditEvent(r, "DATA_ERASURE_REQUESTED", request.Pseudonym, fmt.Sprintf("Erasure request %d recorded", request.ID))
+
+	response := AuthResponse{
+		Success: true,
+		Message: "Erasure request recorded. Your data will be erased once the request is processed.",
+		Data:    request,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// buildUserDataExport collects a user's account, sessions, usage and audit trail
+// Returns sql.ErrNoRows when the user has no account
+func buildUserDataExport(db *sql.DB, username string) (*shared.UserDataExport, error) {
+	export := &shared.UserDataExport{
+		Version:     shared.UserDataExportVersion,
+		GeneratedAt: time.Now().UTC(),
+		AuditEvents: []shared.AuditEvent{},
+	}
+
+	if err := loadExportProfile(db, username, &export.Profile); err != nil {
+		return nil, err
+	}
+
+	prefs, err := loadExportPreferences(db, username)
+	if err != nil {
+		return nil, fmt.Errorf("preferences: %v", err)
+	}
+	export.Preferences = prefs
+
+	if export.ConnectionHistory, err = loadExportSessions(db, username); err != nil {
+		return nil, fmt.Errorf("connections: %v", err)
+	}
+	export.Sessions = []shared.VPNSession{}
+	for _, session := range export.ConnectionHistory {
+		if session.DisconnectedAt == nil {
+			export.Sessions = append(export.Sessions, session)
+		}
+	}
+
+	if err := loadExportUsage(db, username, &export.Usage); err != nil {
+		return nil, fmt.Errorf("usage: %v", err)
+	}
+
+	// Audit entries where the user acted or was acted upon, from every source
+	filter := &auditFilter{Username: username}
+	collect := func(event shared.AuditEvent) error {
+		export.AuditEvents = append(export.AuditEvents, event)
+		return nil
+	}
+	if err := streamAuditLog(db, filter, 0, collect); err != nil {
+		return nil, fmt.Errorf("audit log: %v", err)
+	}
+	if err := streamEndNodeAuditLog(db, filter, 0, collect); err != nil {
+		return nil, fmt.Errorf("end-node audit log: %v", err)
+	}
+	sort.SliceStable(export.AuditEvents, func(i, j int) bool {
+		return export.AuditEvents[i].Timestamp.After(export.AuditEvents[j].Timestamp)
+	})
+
+	return export, nil
+}
+
+// loadExportProfile loads the account record; the password hash is never exported
+func loadExportProfile(db *sql.DB, username string, profile *shared.UserProfileExport) error {
+	var plan sql.NullString
+	var maxSessions sql.NullInt64
+	var lastLogin sql.NullTime
+
+	err := db.QueryRow(`
+		SELECT a.id, a.phone_number, a.active, p.name, a.max_concurrent_sessions, a.created_at, a.last_login
+		FROM auth_users a
+		LEFT JOIN plans p ON p.id = a.plan_id
+		WHERE a.phone_number = $1
+	`, username).Scan(&profile.ID, &profile.PhoneNumber, &profile.Active, &plan, &maxSessions,
+		&profile.CreatedAt, &lastLogin)
+	if err != nil {
+		return err
+	}
+
+	profile.Plan = plan.String
+	if maxSessions.Valid {
+		n := int(maxSessions.Int64)
+		profile.MaxConcurrentSessions = &n
+	}
+	if lastLogin.Valid {
+		profile.LastLogin = &lastLogin.Time
+	}
+
+	return nil
+}
+
+// loadExportPreferences loads saved server preferences, or nil when none were saved
+func loadExportPreferences(db *sql.DB, username string) (*shared.UserServerPreferences, error) {
+	prefs := &shared.UserServerPreferences{Username: username}
+	var locationID sql.NullInt64
+	var countryCode sql.NullString
+
+	err := db.QueryRow(`
+		SELECT preferred_location_id, preferred_country_code, updated_at
+		FROM user_server_preferences
+		WHERE username = $1
+	`, username).Scan(&locationID, &countryCode, &prefs.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefs.PreferredLocationID = int(locationID.Int64)
+	prefs.PreferredCountryCode = countryCode.String
+	return prefs, nil
+}
+
+// loadExportSessions loads every VPN session of the user, newest first
+func loadExportSessions(db *sql.DB, username string) ([]shared.VPNSession, error) {
+	rows, err := db.Query(`
+		SELECT id, session_id, username, server_id, status, ip_address, connected_at, disconnected_at,
+		       last_seen_at, COALESCE(duration_seconds, 0), COALESCE(close_reason, ''), created_at
+		FROM vpn_connections
+		WHERE username = $1
+		ORDER BY created_at DESC, id DESC
+	`, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []shared.VPNSession{}
+	for rows.Next() {
+		var session shared.VPNSession
+		var ipAddress sql.NullString
+		var connectedAt, disconnectedAt, lastSeenAt sql.NullTime
+
+		err := rows.Scan(&session.ID, &session.SessionID, &session.Username, &session.ServerID, &session.State,
+			&ipAddress, &connectedAt, &disconnectedAt, &lastSeenAt, &session.DurationSeconds,
+			&session.CloseReason, &session.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		session.IPAddress = ipAddress.String
+		if connectedAt.Valid {
+			session.ConnectedAt = &connectedAt.Time
+		}
+		if disconnectedAt.Valid {
+			session.DisconnectedAt = &disconnectedAt.Time
+		}
+		session.LastSeenAt = session.CreatedAt
+		if lastSeenAt.Valid {
+			session.LastSeenAt = lastSeenAt.Time
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// loadExportUsage loads usage totals, daily usage, billing periods and quota notifications
+func loadExportUsage(db *sql.DB, username string, usage *shared.UserUsageExport) error {
+	usage.Daily = []shared.UsageBucket{}
+	usage.Periods = []shared.UsagePeriodRecord{}
+	usage.Notifications = []shared.QuotaNotification{}
+
+	rows, err := db.Query(`
+		SELECT bucket_start, SUM(bytes_in), SUM(bytes_out), SUM(duration_seconds), SUM(sample_count)
+		FROM vpn_statistics_daily
+		WHERE username = $1
+		GROUP BY bucket_start
+		ORDER BY bucket_start
+	`, username)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b shared.UsageBucket
+		if err := rows.Scan(&b.BucketStart, &b.BytesIn, &b.BytesOut, &b.DurationSeconds, &b.SampleCount); err != nil {
+			return err
+		}
+		usage.TotalBytesIn += b.BytesIn
+		usage.TotalBytesOut += b.BytesOut
+		usage.TotalDurationSeconds += b.DurationSeconds
+		usage.Daily = append(usage.Daily, b)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	periods, err := db.Query(`
+		SELECT period_start, period_end, bytes_in, bytes_out, duration_seconds, exhausted_at
+		FROM usage_periods
+		WHERE username = $1
+		ORDER BY period_start
+	`, username)
+	if err != nil {
+		return err
+	}
+	defer periods.Close()
+
+	for periods.Next() {
+		var p shared.UsagePeriodRecord
+		var exhaustedAt sql.NullTime
+		if err := periods.Scan(&p.PeriodStart, &p.PeriodEnd, &p.BytesIn, &p.BytesOut, &p.DurationSeconds, &exhaustedAt); err != nil {
+			return err
+		}
+		if exhaustedAt.Valid {
+			p.ExhaustedAt = &exhaustedAt.Time
+		}
+		usage.Periods = append(usage.Periods, p)
+	}
+	if err := periods.Err(); err != nil {
+		return err
+	}
+
+	notifications, err := db.Query(`
+		SELECT threshold, metric, used, quota_limit, created_at
+		FROM quota_notifications
+		WHERE username = $1
+		ORDER BY created_at
+	`, username)
+	if err != nil {
+		return err
+	}
+	defer notifications.Close()
+
+	for notifications.Next() {
+		var n shared.QuotaNotification
+		if err := notifications.Scan(&n.Threshold, &n.Metric, &n.Used, &n.Limit, &n.CreatedAt); err != nil {
+			return err
+		}
+		usage.Notifications = append(usage.Notifications, n)
+	}
+
+	return notifications.Err()
+}
+
+// createErasureRequest records a pending erasure request for username
+// Returns errErasurePending when the user already has an open request
+func createErasureRequest(db *sql.DB, username, requestedBy, reason string) (*shared.DataErasureRequest, error) {
+	request := &shared.DataErasureRequest{
+		Username:    username,
+		Pseudonym:   "erased-" + newRequestID()[:16],
+		Status:      shared.ErasureStatusPending,
+		RequestedBy: requestedBy,
+		Reason:      reason,
+		RequestedAt: time.Now(),
+	}
+
+	err := db.QueryRow(`
+		INSERT INTO data_erasure_requests (username, pseudonym, status, requested_by, reason, requested_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (username) WHERE status IN ('pending', 'partial') DO NOTHING
+		RETURNING id
+	`, request.Username, request.Pseudonym, request.Status, request.RequestedBy,
+		nullString(request.Reason), request.RequestedAt).Scan(&request.ID)
+	if err == sql.ErrNoRows {
+		return nil, errErasurePending
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
@@ -0,0 +1,397 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// Over-quota actions signalled to end-nodes
+const (
+	overQuotaSuspend  = "suspend"
+	overQuotaThrottle = "throttle"
+)
+
+// Quota metrics
+const (
+	quotaMetricData = "data"
+	quotaMetricTime = "time"
+)
+
+// quotaWarningThreshold is the usage percentage at which users are warned
+const quotaWarningThreshold = 80
+
+// userPlan holds the quota settings that apply to a user
+type userPlan struct {
+	Name            string
+	DataBytes       int64 // 0 means unlimited
+	DurationSeconds int64 // 0 means unlimited
+	OverQuotaAction string
+	ThrottleKbps    int
+	BillingAnchor   time.Time
+}
+
+// getUserPlan loads the user's plan quotas and billing anchor
+// Users without an account or plan get an unlimited plan
+func (api *ManagementAPI) getUserPlan(username string) (*userPlan, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	plan := &userPlan{
+		OverQuotaAction: overQuotaSuspend,
+		BillingAnchor:   time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	var name, action sql.NullString
+	var dataBytes, durationSeconds, throttleKbps sql.NullInt64
+	var anchor sql.NullTime
+
+	err := conn.QueryRow(`
+		SELECT p.name, p.monthly_data_bytes, p.monthly_duration_seconds,
+		       p.over_quota_action, p.throttle_kbps, a.created_at
+		FROM auth_users a
+		LEFT JOIN plans p ON p.id = a.plan_id
+		WHERE a.phone_number = $1
+	`, username).Scan(&name, &dataBytes, &durationSeconds, &action, &throttleKbps, &anchor)
+	if err == sql.ErrNoRows {
+		return plan, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plan.Name = name.String
+	plan.DataBytes = dataBytes.Int64
+	plan.DurationSeconds = durationSeconds.Int64
+	plan.ThrottleKbps = int(throttleKbps.Int64)
+	if action.Valid {
+		plan.OverQuotaAction = action.String
+	}
+	if anchor.Valid {
+		plan.BillingAnchor = anchor.Time
+	}
+
+	return plan, nil
+}
+
+// billingPeriod returns the monthly billing period containing now
+// Periods start on the anchor's day of month, clamped to shorter months
+func billingPeriod(anchor, now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	day := anchor.UTC().Day()
+
+	start := monthDay(now.Year(), now.Month(), day)
+	if start.After(now) {
+		start = monthDay(now.Year(), now.Month()-1, day)
+	}
+	end := monthDay(start.Year(), start.Month()+1, day)
+
+	return start, end
+}
+
+// monthDay returns midnight UTC on the given day of a month, clamped to the month's last day
+func monthDay(year int, month time.Month, day int) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, time.UTC)
+}
+
+// quotaPercent returns used as a percentage of limit (0 for unlimited)
+func quotaPercent(used, limit int64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return float64(used) / float64(limit) * 100
+}
+
+// accountUsage adds usage to the user's current billing period, raises threshold
+// notifications and signals end-nodes once a quota is exhausted
+func (api *ManagementAPI) accountUsage(username, serverID string, bytesIn, bytesOut int64, duration int) error {
+	plan, err := api.getUserPlan(username)
+	if err != nil {
+		return err
+	}
+
+	start, end := billingPeriod(plan.BillingAnchor, time.Now())
+	conn := api.manager.GetDB().GetConnection()
+
+	var totalBytes, totalDuration int64
+	err = conn.QueryRow(`
+		INSERT INTO usage_periods (username, period_start, period_end, bytes_in, bytes_out, duration_seconds, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (username, period_start) DO UPDATE
+		SET bytes_in = usage_periods.bytes_in + EXCLUDED.bytes_in,
+		    bytes_out = usage_periods.bytes_out + EXCLUDED.bytes_out,
+		    duration_seconds = usage_periods.duration_seconds + EXCLUDED.duration_seconds,
+		    updated_at = NOW()
+		RETURNING bytes_in + bytes_out, duration_seconds
+	`, username, start, end, bytesIn, bytesOut, duration).Scan(&totalBytes, &totalDuration)
+	if err != nil {
+		return fmt.Errorf("failed to update usage period: %v", err)
+	}
+
+	dataPercent := quotaPercent(totalBytes, plan.DataBytes)
+	timePercent := quotaPercent(totalDuration, plan.DurationSeconds)
+
+	usage := []struct {
+		metric      string
+		percent     float64
+		used, limit int64
+	}{
+		{quotaMetricData, dataPercent, totalBytes, plan.DataBytes},
+		{quotaMetricTime, timePercent, totalDuration, plan.DurationSeconds},
+	}
+
+	for _, u := range usage {
+		for _, threshold := range []int{quotaWarningThreshold, 100} {
+			if u.percent < float64(threshold) {
+				continue
+			}
+
+			// Flip the metric's flag atomically so each threshold is only notified once per period
+			flag := fmt.Sprintf("notified_%s_%d", u.metric, threshold)
+			extra := ""
+			if threshold == 100 {
+				extra = ", exhausted_at = COALESCE(exhausted_at, NOW())"
+			}
+			result, err := conn.Exec(fmt.Sprintf(`
+				UPDATE usage_periods SET %s = true%s
+				WHERE username = $1 AND period_start = $2 AND %s = false
+			`, flag, extra, flag), username, start)
+			if err != nil {
+				return fmt.Errorf("failed to flag quota threshold: %v", err)
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				continue
+			}
+
+			api.notifyQuotaThreshold(username, start, threshold, u.metric, u.used, u.limit)
+
+			if threshold == 100 {
+				api.enforceQuota(username, serverID, plan, end)
+			}
+		}
+	}
+
+	return nil
+}
+
+// notifyQuotaThreshold records a threshold notification for the user
+func (api *ManagementAPI) notifyQuotaThreshold(username string, periodStart time.Time, threshold int, metric string, used, limit int64) {
+	conn := api.manager.GetDB().GetConnection()
+
+	_, err := conn.Exec(`
+		INSERT INTO quota_notifications (username, period_start, threshold, metric, used, quota_limit, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, username, periodStart, threshold, metric, used, limit, time.Now())
+	if err != nil {
+		log.Printf("[QUOTA] Failed to record %d%% notification for %s: %v", threshold, username, err)
+	}
+
+	// Exceeding the quota is a bus event, audited by the audit subscriber
+	if threshold >= 100 {
+		publishEvent(nil, shared.QuotaExceededEvent{
+			Username:    username,
+			Metric:      metric,
+			Used:        used,
+			Limit:       limit,
+			PeriodStart: periodStart,
+		})
+		return
+	}
+
+	api.logAudit(
+		"QUOTA_THRESHOLD_REACHED",
+		username,
+		fmt.Sprintf("%s quota at %d%% - used=%d limit=%d", metric, threshold, used, limit),
+		"",
+	)
+}
+
+// enforceQuota signals end-nodes serving the user to suspend or throttle them until the period ends
+func (api *ManagementAPI) enforceQuota(username, serverID string, plan *userPlan, until time.Time) {
+	conn := api.manager.GetDB().GetConnection()
+
+	servers := map[string]bool{}
+	if serverID != "" {
+		servers[serverID] = true
+	}
+
+	rows, err := conn.Query(`
+		SELECT DISTINCT server_id FROM vpn_connections
+		WHERE username = $1 AND disconnected_at IS NULL
+	`, username)
+	if err != nil {
+		log.Printf("[QUOTA] Failed to list open sessions for %s: %v", username, err)
+	} else {
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err == nil {
+				servers[id] = true
+			}
+		}
+		rows.Close()
+	}
+
+	path := fmt.Sprintf("/api/users/%s/suspend", username)
+	payload := map[string]interface{}{
+		"until": until.Unix(),
+	}
+	if plan.OverQuotaAction == overQuotaThrottle {
+		path = fmt.Sprintf("/api/users/%s/throttle", username)
+		payload["rate_kbps"] = plan.ThrottleKbps
+	}
+
+	for id := range servers {
+		endNode, err := api.findEndNode(id)
+		if err != nil {
+			log.Printf("[QUOTA] Cannot signal %s for %s: %v", plan.OverQuotaAction, username, err)
+			continue
+		}
+		if err := api.callEndNode(endNode, "POST", path, payload); err != nil {
+			log.Printf("[QUOTA] Failed to signal %s for %s on %s: %v", plan.OverQuotaAction, username, id, err)
+			continue
+		}
+
+		api.logAudit(
+			"QUOTA_ENFORCED",
+			username,
+			fmt.Sprintf("End-node %s instructed to %s user until %s", id, plan.OverQuotaAction, until.Format(time.RFC3339)),
+			"",
+		)
+	}
+}
+
+// isQuotaSuspended reports whether the user exhausted a quota whose plan suspends access
+func (api *ManagementAPI) isQuotaSuspended(username string) (bool, error) {
+	plan, err := api.getUserPlan(username)
+	if err != nil {
+		return false, err
+	}
+	if plan.OverQuotaAction != overQuotaSuspend {
+		return false, nil
+	}
+
+	start, _ := billingPeriod(plan.BillingAnchor, time.Now())
+	conn := api.manager.GetDB().GetConnection()
+
+	var exhaustedAt sql.NullTime
+	err = conn.QueryRow(`
+		SELECT exhausted_at FROM usage_periods WHERE username = $1 AND period_start = $2
+	`, username, start).Scan(&exhaustedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return exhaustedAt.Valid, nil
+}
+
+// handleVPNQuota returns the authenticated user's remaining allowance
+// GET /vpn/quota
+func (api *ManagementAPI) handleVPNQuota(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Validate JWT token
+	username, err := api.validateJWTToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	allowance, err := api.getQuotaAllowance(username)
+	if err != nil {
+		log.Printf("[ERROR] Failed to compute quota for user %s: %v", username, err)
+		http.Error(w, "Failed to retrieve quota. Please try again later.", http.StatusInternalServerError)
+		return
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Quota retrieved successfully",
+		Data:      allowance,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// getQuotaAllowance builds the user's allowance for the current billing period
+func (api *ManagementAPI) getQuotaAllowance(username string) (*shared.QuotaAllowance, error) {
+	plan, err := api.getUserPlan(username)
+	if err != nil {
+		return nil, err
+	}
+
+	start, end := billingPeriod(plan.BillingAnchor, time.Now())
+	conn := api.manager.GetDB().GetConnection()
+
+	var usedBytes, usedSeconds int64
+	var exhaustedAt sql.NullTime
+	err = conn.QueryRow(`
+		SELECT bytes_in + bytes_out, duration_seconds, exhausted_at
+		FROM usage_periods
+		WHERE username = $1 AND period_start = $2
+	`, username, start).Scan(&usedBytes, &usedSeconds, &exhaustedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	allowance := &shared.QuotaAllowance{
+		Username:        username,
+		Plan:            plan.Name,
+		PeriodStart:     start,
+		PeriodEnd:       end,
+		Data:            quotaMetric(usedBytes, plan.DataBytes),
+		Time:            quotaMetric(usedSeconds, plan.DurationSeconds),
+		Exhausted:       exhaustedAt.Valid,
+		OverQuotaAction: plan.OverQuotaAction,
+		Notifications:   []shared.QuotaNotification{},
+	}
+
+	rows, err := conn.Query(`
+		SELECT threshold, metric, used, quota_limit, created_at
+		FROM quota_notifications
+		WHERE username = $1 AND period_start = $2
+		ORDER BY created_at DESC
+	`, username, start)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n shared.QuotaNotification
+		if err := rows.Scan(&n.Threshold, &n.Metric, &n.Used, &n.Limit, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		allowance.Notifications = append(allowance.Notifications, n)
+	}
+
+	return allowance, rows.Err()
+}
+
+// quotaMetric summarizes usage against a limit (0 means unlimited)
+func quotaMetric(used, limit int64) shared.QuotaMetric {
+	metric := shared.QuotaMetric{
+		Limit:   limit,
+		Used:    used,
+		Percent: quotaPercent(used, limit),
+	}
+	if limit > 0 && used < limit {
+		metric.Remaining = limit - used
+	}
+	return metric
+}
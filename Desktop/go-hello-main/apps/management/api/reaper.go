@@ -0,0 +1,228 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	defaultSessionTimeout      = 10 * time.Minute
+	defaultSessionReapInterval = 1 * time.Minute
+)
+
+// reapedSession identifies a session closed by the reaper
+type reapedSession struct {
+	SessionID string
+	Username  string
+	ServerID  string
+}
+
+// sessionReaperConfig returns the heartbeat timeout and scan interval from
+// SESSION_TIMEOUT and SESSION_REAP_INTERVAL (Go duration strings)
+func sessionReaperConfig() (timeout, interval time.Duration) {
+	timeout = defaultSessionTimeout
+	interval = defaultSessionReapInterval
+
+	if d, err := time.ParseDuration(os.Getenv("SESSION_TIMEOUT")); err == nil && d > 0 {
+		timeout = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("SESSION_REAP_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+
+	return timeout, interval
+}
+
+// runSessionReaper periodically closes sessions that can no longer be active
+func (api *ManagementAPI) runSessionReaper() {
+	timeout, interval := sessionReaperConfig()
+	log.Printf("[REAPER] Closing sessions idle for more than %v (scan every %v)", timeout, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, err := api.reapSessions(timeout); err != nil {
+			log.Printf("[REAPER] Session reap failed: %v", err)
+		}
+	}
+}
+
+// reapSessions closes revoked, server-offline and timed-out sessions, returning how many were closed
+func (api *ManagementAPI) reapSessions(timeout time.Duration) (int, error) {
+	now := time.Now()
+	total := 0
+
+	// Users whose account was disabled lose their sessions immediately
+	revoked, err := api.closeSessionsWhere(closeReasonRevoked, "$1", `
+		EXISTS (
+			SELECT 1 FROM auth_users a
+			WHERE a.phone_number = c.username AND a.active = false
+		)`, now)
+	if err != nil {
+		return total, fmt.Errorf("revoked sessions: %v", err)
+	}
+	total += revoked
+
+	// Sessions on disabled servers, or servers whose latest health check failed
+	offline, err := api.closeSessionsWhere(closeReasonServerOffline, "$1", `
+		(
+			NOT EXISTS (SELECT 1 FROM servers s WHERE s.name = c.server_id AND s.enabled = true)
+			OR (
+				SELECT h.status FROM server_health h
+				WHERE h.server_id = c.server_id
+				ORDER BY h.last_check DESC
+				LIMIT 1
+			) IN ('offline', 'unhealthy')
+		)`, now)
+	if err != nil {
+		return total, fmt.Errorf("server-offline sessions: %v", err)
+	}
+	total += offline
+
+	// Sessions without heartbeat or stats upload end at their last sign of life
+	timedOut, err := api.closeSessionsWhere(closeReasonTimeout, "COALESCE(c.last_seen_at, c.created_at)",
+		"COALESCE(c.last_seen_at, c.created_at) < $1", now.Add(-timeout))
+	if err != nil {
+		return total, fmt.Errorf("timed-out sessions: %v", err)
+	}
+	total += timedOut
+
+	return total, nil
+}
+
+// closeSessionsWhere closes every open session matching condition with the given reason
+// closedAt is the SQL expression used as the disconnect time; $1 is bound to arg
+func (api *ManagementAPI) closeSessionsWhere(reason, closedAt, condition string, arg interface{}) (int, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	query := fmt.Sprintf(`
+		UPDATE vpn_connections c
+		SET status = '%s',
+		    disconnected_at = %s,
+		    close_reason = $2,
+		    duration_seconds = CASE
+		        WHEN c.connected_at IS NULL THEN 0
+		        ELSE GREATEST(0, EXTRACT(EPOCH FROM (%s - c.connected_at))::INTEGER)
+		    END
+		WHERE c.disconnected_at IS NULL AND %s
+		RETURNING c.session_id, c.username, c.server_id
+	`, shared.SessionStateDisconnected, closedAt, closedAt, condition)
+
+	rows, err := conn.Query(query, arg, reason)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var reaped []reapedSession
+	for rows.Next() {
+		var s reapedSession
+		if err := rows.Scan(&s.SessionID, &s.Username, &s.ServerID); err != nil {
+			return 0, err
+		}
+		reaped = append(reaped, s)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, s := range reaped {
+		api.logAudit(
+			"VPN_SESSION_REAPED",
+			s.Username,
+			fmt.Sprintf("Session %s on server %s closed - reason=%s", s.SessionID, s.ServerID, reason),
+			"",
+		)
+	}
+
+	if len(reaped) > 0 {
+		log.Printf("[REAPER] Closed %d sessions - reason=%s", len(reaped), reason)
+	}
+
+	return len(reaped), nil
+}
+
+// touchSessions records activity on the user's open sessions for a server
+func (api *ManagementAPI) touchSessions(username, serverID, sessionID string) (int64, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	query := `
+		UPDATE vpn_connections SET last_seen_at = $1
+		WHERE username = $2 AND disconnected_at IS NULL AND `
+	var arg interface{}
+	if sessionID != "" {
+		query += "session_id = $3::uuid"
+		arg = sessionID
+	} else {
+		query += "server_id = $3"
+		arg = serverID
+	}
+
+	result, err := conn.Exec(query, time.Now(), username, arg)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// handleVPNHeartbeat keeps a session alive between status and stats uploads
+// POST /vpn/heartbeat
+func (api *ManagementAPI) handleVPNHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Validate JWT token
+	username, err := api.validateJWTToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		ServerID  string `json:"server_id"`
+		SessionID string `json:"session_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.ServerID == "" && req.SessionID == "" {
+		http.Error(w, "server_id or session_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.SessionID != "" && !validSessionID.MatchString(req.SessionID) {
+		http.Error(w, "session_id must be a UUID", http.StatusBadRequest)
+		return
+	}
+
+	touched, err := api.touchSessions(username, req.ServerID, req.SessionID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to record heartbeat for user %s: %v", username, err)
+		http.Error(w, "Failed to record heartbeat", http.StatusInternalServerError)
+		return
+	}
+	if touched == 0 {
+		http.Error(w, "No open session found", http.StatusNotFound)
+		return
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Heartbeat recorded",
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
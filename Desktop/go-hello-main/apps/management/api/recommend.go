@@ -0,0 +1,464 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// Recommendation scoring weights (sum to 1.0 before preference bonuses)
+const (
+	recommendHealthWeight  = 0.35
+	recommendLoadWeight    = 0.35
+	recommendLatencyWeight = 0.30
+
+	// Bonuses added for servers matching the user's preferences
+	recommendLocationBonus = 0.25
+	recommendCountryBonus  = 0.15
+
+	// Latency used when no measurement is available for a server
+	recommendUnknownLatencyMs = 150
+
+	defaultRecommendFallbacks = 3
+	maxRecommendFallbacks     = 10
+)
+
+// healthScores maps server health status to a score between 0 and 1
+// Statuses not listed here (offline, unhealthy) exclude the server
+var healthScores = map[string]float64{
+	"healthy":  1.0,
+	"online":   1.0,
+	"degraded": 0.5,
+	"unknown":  0.3,
+}
+
+// recommendCandidate is a server considered for recommendation
+type recommendCandidate struct {
+	shared.ServerRecommendation
+	weight   float64
+	location *geoPoint
+}
+
+// handleVPNRecommend recommends the best server for the authenticated user
+// GET /vpn/recommend?location_id={id}&country={code}&fallbacks={n}
+func (api *ManagementAPI) handleVPNRecommend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Validate JWT token
+	username, err := api.validateJWTToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	prefs, err := api.getUserServerPreferences(username)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load server preferences for user %s: %v", username, err)
+		prefs = &shared.UserServerPreferences{Username: username}
+	}
+
+	// Query parameters override stored preferences for this request
+	query := r.URL.Query()
+	if locStr := query.Get("location_id"); locStr != "" {
+		locationID, err := strconv.Atoi(locStr)
+		if err != nil {
+			http.Error(w, "Invalid location ID", http.StatusBadRequest)
+			return
+		}
+		prefs.PreferredLocationID = locationID
+	}
+	if country := query.Get("country"); country != "" {
+		prefs.PreferredCountryCode = strings.ToUpper(country)
+	}
+
+	fallbacks := defaultRecommendFallbacks
+	if fbStr := query.Get("fallbacks"); fbStr != "" {
+		n, err := strconv.Atoi(fbStr)
+		if err != nil || n < 0 || n > maxRecommendFallbacks {
+			http.Error(w, fmt.Sprintf("fallbacks must be between 0 and %d", maxRecommendFallbacks), http.StatusBadRequest)
+			return
+		}
+		fallbacks = n
+	}
+
+	candidates, err := api.getRecommendCandidates()
+	if err != nil {
+		log.Printf("[ERROR] Failed to load server candidates for user %s: %v", username, err)
+		http.Error(w, "Failed to recommend a server. Please try again later.", http.StatusInternalServerError)
+		return
+	}
+
+	// Prefer latency measured from the client's network, then a per-client distance
+	// estimate, over the management server's health-check RTT
+	client := api.clientInfo(r)
+	for i := range candidates {
+		if rtt, ok := api.getMeasuredServerLatency(candidates[i].ServerID, client); ok {
+			candidates[i].LatencyMs = rtt
+		} else if client.Location != nil && candidates[i].location != nil {
+			candidates[i].LatencyMs = api.latency.Estimate(haversineKm(*client.Location, *candidates[i].location))
+		}
+	}
+
+	result := rankServers(candidates, prefs, fallbacks)
+	if result.Best == nil {
+		http.Error(w, "No servers are currently available", http.StatusServiceUnavailable)
+		return
+	}
+
+	api.auditRequest(
+		r,
+		"VPN_SERVER_RECOMMENDED",
+		username,
+		fmt.Sprintf("Server %s recommended (score=%.3f, fallbacks=%d)", result.Best.ServerID, result.Best.Score, len(result.Fallbacks)),
+	)
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Server recommendation generated successfully",
+		Data:      result,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// getRecommendCandidates loads every assignable server with its location, load and latest health
+func (api *ManagementAPI) getRecommendCandidates() ([]recommendCandidate, error) {
+	db := api.manager.GetDB()
+	conn := db.GetConnection()
+
+	query := `
+		SELECT s.name, s.host, s.port, s.capacity, s.weight,
+		       l.id, l.city, l.country, l.country_code, l.latitude, l.longitude,
+		       (SELECT COUNT(DISTINCT c.username) FROM vpn_connections c
+		        WHERE c.server_id = s.name AND c.disconnected_at IS NULL),
+		       h.status, h.response_time_ms
+		FROM servers s
+		JOIN server_locations l ON l.id = s.location_id
+		LEFT JOIN LATERAL (
+			SELECT status, response_time_ms
+			FROM server_health
+			WHERE server_id = s.name
+			ORDER BY last_check DESC
+			LIMIT 1
+		) h ON true
+		WHERE s.enabled = true AND s.draining = false AND l.enabled = true
+	`
+
+	rows, err := conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []recommendCandidate
+	for rows.Next() {
+		var c recommendCandidate
+		var status sql.NullString
+		var responseTime sql.NullInt64
+		var lat, lon sql.NullFloat64
+
+		err := rows.Scan(
+			&c.ServerID,
+			&c.Host,
+			&c.Port,
+			&c.Capacity,
+			&c.weight,
+			&c.LocationID,
+			&c.City,
+			&c.Country,
+			&c.CountryCode,
+			&lat,
+			&lon,
+			&c.UserCount,
+			&status,
+			&responseTime,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		c.HealthStatus = "unknown"
+		if status.Valid {
+			c.HealthStatus = status.String
+		}
+
+		if lat.Valid && lon.Valid {
+			c.location = &geoPoint{Latitude: lat.Float64, Longitude: lon.Float64}
+		}
+
+		c.LatencyMs = recommendUnknownLatencyMs
+		if responseTime.Valid && responseTime.Int64 > 0 {
+			c.LatencyMs = int(responseTime.Int64)
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	return candidates, rows.Err()
+}
+
+// rankServers scores candidates and returns the best pick plus up to maxFallbacks alternatives
+// Unhealthy and full servers are never recommended
+func rankServers(candidates []recommendCandidate, prefs *shared.UserServerPreferences, maxFallbacks int) *shared.RecommendationResult {
+	var ranked []shared.ServerRecommendation
+	for _, c := range candidates {
+		healthScore, ok := healthScores[c.HealthStatus]
+		if !ok {
+			continue
+		}
+
+		if c.Capacity <= 0 || c.UserCount >= c.Capacity {
+			continue
+		}
+
+		load := float64(c.UserCount) / float64(c.Capacity)
+		latencyScore := 1.0 / (1.0 + float64(c.LatencyMs)/100.0)
+
+		score := recommendHealthWeight*healthScore +
+			recommendLoadWeight*(1-load) +
+			recommendLatencyWeight*latencyScore
+
+		if prefs != nil {
+			if prefs.PreferredLocationID != 0 && prefs.PreferredLocationID == c.LocationID {
+				score += recommendLocationBonus
+			}
+			if prefs.PreferredCountryCode != "" && strings.EqualFold(prefs.PreferredCountryCode, c.CountryCode) {
+				score += recommendCountryBonus
+			}
+		}
+
+		rec := c.ServerRecommendation
+		rec.LoadPercent = load * 100
+		rec.Score = score * c.weight
+		ranked = append(ranked, rec)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+
+	result := &shared.RecommendationResult{
+		Fallbacks: []shared.ServerRecommendation{},
+	}
+	if len(ranked) == 0 {
+		return result
+	}
+
+	result.Best = &ranked[0]
+	for i := 1; i < len(ranked) && len(result.Fallbacks) < maxFallbacks; i++ {
+		result.Fallbacks = append(result.Fallbacks, ranked[i])
+	}
+
+	return result
+}
+
+// handleVPNPreferences handles reading and updating the user's server preferences
+// GET /vpn/preferences
+// PUT /vpn/preferences
+func (api *ManagementAPI) handleVPNPreferences(w http.ResponseWriter, r *http.Request) {
+	// Validate JWT token
+	username, err := api.validateJWTToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		prefs, err := api.getUserServerPreferences(username)
+		if err != nil {
+			log.Printf("[ERROR] Failed to load server preferences for user %s: %v", username, err)
+			http.Error(w, "Failed to retrieve preferences", http.StatusInternalServerError)
+			return
+		}
+
+		response := shared.APIResponse{
+			Success:   true,
+			Message:   "Preferences retrieved successfully",
+			Data:      prefs,
+			Timestamp: time.Now().Unix(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	case "PUT":
+		var req struct {
+			PreferredLocationID  int    `json:"preferred_location_id"`
+			PreferredCountryCode string `json:"preferred_country_code"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		if req.PreferredCountryCode != "" && len(req.PreferredCountryCode) != 2 {
+			http.Error(w, "preferred_country_code must be a 2-letter country code", http.StatusBadRequest)
+			return
+		}
+
+		prefs := &shared.UserServerPreferences{
+			Username:             username,
+			PreferredLocationID:  req.PreferredLocationID,
+			PreferredCountryCode: strings.ToUpper(req.PreferredCountryCode),
+			UpdatedAt:            time.Now(),
+		}
+
+		if err := api.saveUserServerPreferences(prefs); err != nil {
+			log.Printf("[ERROR] Failed to save server preferences for user %s: %v", username, err)
+			http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+			return
+		}
+
+		api.auditRequest(r, "VPN_PREFERENCES_UPDATED", username, "Server preferences updated")
+
+		response := shared.APIResponse{
+			Success:   true,
+			Message:   "Preferences updated successfully",
+			Data:      prefs,
+			Timestamp: time.Now().Unix(),
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getUserServerPreferences loads a user's server preferences (empty if none saved)
+func (api *ManagementAPI) getUserServerPreferences(username string) (*shared.UserServerPreferences, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	prefs := &shared.UserServerPreferences{Username: username}
+	var locationID sql.NullInt64
+	var countryCode sql.NullString
+
+	err := conn.QueryRow(`
+		SELECT preferred_location_id, preferred_country_code, updated_at
+		FROM user_server_preferences
+		WHERE username = $1
+	`, username).Scan(&locationID, &countryCode, &prefs.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return prefs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if locationID.Valid {
+		prefs.PreferredLocationID = int(locationID.Int64)
+	}
+	if countryCode.Valid {
+		prefs.PreferredCountryCode = countryCode.String
+	}
+
+	return prefs, nil
+}
+
+// saveUserServerPreferences upserts a user's server preferences
+func (api *ManagementAPI) saveUserServerPreferences(prefs *shared.UserServerPreferences) error {
+	conn := api.manager.GetDB().GetConnection()
+
+	var locationID, countryCode interface{}
+	if prefs.PreferredLocationID != 0 {
+		locationID = prefs.PreferredLocationID
+	}
+	if prefs.PreferredCountryCode != "" {
+		countryCode = prefs.PreferredCountryCode
+	}
+
+	_, err := conn.Exec(`
+		INSERT INTO user_server_preferences (username, preferred_location_id, preferred_country_code, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (username) DO UPDATE
+		SET preferred_location_id = EXCLUDED.preferred_location_id,
+		    preferred_country_code = EXCLUDED.preferred_country_code,
+		    updated_at = EXCLUDED.updated_at
+	`, prefs.Username, locationID, countryCode, prefs.UpdatedAt)
+	return err
+}
+
+// handleEndNodeCapacity handles updating capacity and weight for an end-node
+// PUT /api/endnodes/{server_id}/capacity
+func (api *ManagementAPI) handleEndNodeCapacity(w http.ResponseWriter, r *http.Request, serverID string) {
+	if r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Capacity int     `json:"capacity"`
+		Weight   float64 `json:"weight"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Capacity < 1 || req.Capacity > 100000 {
+		http.Error(w, "capacity must be between 1 and 100000", http.StatusBadRequest)
+		return
+	}
+	if req.Weight == 0 {
+		req.Weight = 1.0
+	}
+	if req.Weight < 0 || req.Weight > 100 {
+		http.Error(w, "weight must be between 0 and 100", http.StatusBadRequest)
+		return
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	result, err := conn.Exec(`
+		UPDATE servers SET capacity = $1, weight = $2 WHERE name = $3
+	`, req.Capacity, req.Weight, serverID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to update capacity for %s: %v", serverID, err)
+		http.Error(w, "Failed to update capacity", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, fmt.Sprintf("End-node '%s' not found", serverID), http.StatusNotFound)
+		return
+	}
+
+	api.auditRequest(
+		r,
+		"ENDNODE_CAPACITY_UPDATED",
+		admin,
+		fmt.Sprintf("End-node %s capacity=%d weight=%.2f", serverID, req.Capacity, req.Weight),
+	)
+
+	response := shared.APIResponse{
+		Success: true,
+		Message: fmt.Sprintf("Capacity updated for end-node '%s'", serverID),
+		Data: map[string]interface{}{
+			"server_id": serverID,
+			"capacity":  req.Capacity,
+			"weight":    req.Weight,
+		},
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
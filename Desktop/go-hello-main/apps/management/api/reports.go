@@ -0,0 +1,398 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	defaultTopUsersLimit   = 20
+	maxTopUsersLimit       = 1000
+	defaultChurnInactivity = 30
+)
+
+// reportBuilder builds a named report for the requested range
+type reportBuilder func(api *ManagementAPI, r *http.Request, report *shared.Report) error
+
+// reportBuilders lists the available fleet reports by URL name
+var reportBuilders = map[string]reportBuilder{
+	"top-users":     (*ManagementAPI).buildTopUsersReport,
+	"bandwidth":     (*ManagementAPI).buildBandwidthReport,
+	"concurrency":   (*ManagementAPI).buildConcurrencyReport,
+	"registrations": (*ManagementAPI).buildRegistrationsReport,
+	"churn":         (*ManagementAPI).buildChurnReport,
+}
+
+// handleReports serves fleet-wide usage and capacity reports
+// GET /api/reports                      - list available reports
+// GET /api/reports/{name}?from=&to=&granularity=&format=csv|json
+func (api *ManagementAPI) handleReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/reports"), "/")
+	if name == "" {
+		api.handleListReports(w)
+		return
+	}
+
+	build, ok := reportBuilders[name]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown report '%s'", name), http.StatusNotFound)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "format must be one of: csv, json", http.StatusBadRequest)
+		return
+	}
+
+	granularity, from, to, err := parseUsageRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := &shared.Report{
+		Name:        name,
+		From:        from,
+		To:          to,
+		Granularity: granularity,
+		Rows:        []map[string]interface{}{},
+		GeneratedAt: time.Now(),
+	}
+
+	if err := build(api, r, report); err != nil {
+		if badRequest, ok := err.(reportParamError); ok {
+			http.Error(w, badRequest.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[REPORTS] Failed to build %s report: %v", name, err)
+		http.Error(w, "Failed to build report", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(
+		r,
+		"REPORT_GENERATED",
+		admin,
+		fmt.Sprintf("Report %s generated - from=%s to=%s format=%s rows=%d",
+			name, from.Format(time.RFC3339), to.Format(time.RFC3339), format, len(report.Rows)),
+	)
+
+	if format == "csv" {
+		writeReportCSV(w, report)
+		return
+	}
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Report %s generated successfully", name),
+		Data:      report,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// handleListReports lists the report names
+func (api *ManagementAPI) handleListReports(w http.ResponseWriter) {
+	response := shared.APIResponse{
+		Success: true,
+		Message: "Available reports",
+		Data: map[string]string{
+			"top-users":     "/api/reports/top-users?from=&to=&limit=",
+			"bandwidth":     "/api/reports/bandwidth?from=&to=&granularity=hour|day&group=server|location",
+			"concurrency":   "/api/reports/concurrency?from=&to=&granularity=hour|day&server_id=",
+			"registrations": "/api/reports/registrations?from=&to=",
+			"churn":         "/api/reports/churn?from=&to=&inactive_days=",
+		},
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// reportParamError is an invalid report query parameter
+type reportParamError string
+
+func (e reportParamError) Error() string {
+	return string(e)
+}
+
+// intParam reads a positive integer query parameter with a default and maximum
+func intParam(r *http.Request, name string, def, max int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 || n > max {
+		return 0, reportParamError(fmt.Sprintf("%s must be between 1 and %d", name, max))
+	}
+	return n, nil
+}
+
+// runReportQuery executes query and appends one row per result using columns as keys
+func (api *ManagementAPI) runReportQuery(report *shared.Report, columns []string, query string, args ...interface{}) error {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	report.Columns = columns
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, col := range columns {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[col] = values[i]
+		}
+		report.Rows = append(report.Rows, row)
+	}
+
+	return rows.Err()
+}
+
+// buildTopUsersReport ranks users by total traffic
+func (api *ManagementAPI) buildTopUsersReport(r *http.Request, report *shared.Report) error {
+	limit, err := intParam(r, "limit", defaultTopUsersLimit, maxTopUsersLimit)
+	if err != nil {
+		return err
+	}
+	report.Granularity = ""
+
+	return api.runReportQuery(report,
+		[]string{"username", "bytes_in", "bytes_out", "total_bytes", "duration_seconds"}, `
+		SELECT username,
+		       SUM(bytes_in)::BIGINT,
+		       SUM(bytes_out)::BIGINT,
+		       SUM(bytes_in + bytes_out)::BIGINT AS total_bytes,
+		       SUM(duration_seconds)::BIGINT
+		FROM vpn_statistics_daily
+		WHERE bucket_start >= $1 AND bucket_start < $2
+		GROUP BY username
+		ORDER BY total_bytes DESC
+		LIMIT $3
+	`, report.From, report.To, limit)
+}
+
+// buildBandwidthReport reports traffic per server or location over time
+func (api *ManagementAPI) buildBandwidthReport(r *http.Request, report *shared.Report) error {
+	table := rollupTables[report.Granularity].table
+
+	switch r.URL.Query().Get("group") {
+	case "", "server":
+		return api.runReportQuery(report,
+			[]string{"bucket_start", "server_id", "bytes_in", "bytes_out", "total_bytes"}, fmt.Sprintf(`
+			SELECT bucket_start, server_id,
+			       SUM(bytes_in)::BIGINT, SUM(bytes_out)::BIGINT, SUM(bytes_in + bytes_out)::BIGINT
+			FROM %s
+			WHERE bucket_start >= $1 AND bucket_start < $2
+			GROUP BY bucket_start, server_id
+			ORDER BY bucket_start, server_id
+		`, table), report.From, report.To)
+	case "location":
+		return api.runReportQuery(report,
+			[]string{"bucket_start", "location_id", "location", "bytes_in", "bytes_out", "total_bytes"}, fmt.Sprintf(`
+			SELECT r.bucket_start, l.id, COALESCE(l.city || ', ' || l.country, 'Unassigned'),
+			       SUM(r.bytes_in)::BIGINT, SUM(r.bytes_out)::BIGINT, SUM(r.bytes_in + r.bytes_out)::BIGINT
+			FROM %s r
+			LEFT JOIN servers s ON s.name = r.server_id
+			LEFT JOIN server_locations l ON l.id = s.location_id
+			WHERE r.bucket_start >= $1 AND r.bucket_start < $2
+			GROUP BY r.bucket_start, l.id, l.city, l.country
+			ORDER BY r.bucket_start, l.id
+		`, table), report.From, report.To)
+	default:
+		return reportParamError("group must be one of: server, location")
+	}
+}
+
+// buildConcurrencyReport reports peak concurrent sessions per bucket
+// Every bucket in the range is reported, including those without events
+func (api *ManagementAPI) buildConcurrencyReport(r *http.Request, report *shared.Report) error {
+	unit := rollupTables[report.Granularity].unit
+
+	serverFilter := ""
+	args := []interface{}{report.From, report.To}
+	if serverID := r.URL.Query().Get("server_id"); serverID != "" {
+		serverFilter = "AND server_id = $3"
+		args = append(args, serverID)
+	}
+
+	// Walk connect (+1) and disconnect (-1) events in time order; a session
+	// still open at the end of the range contributes no closing event. Each
+	// bucket starts from the running value at its start, so sessions carried
+	// over from earlier buckets count toward its peak
+	return api.runReportQuery(report,
+		[]string{"bucket_start", "peak_sessions"}, fmt.Sprintf(`
+		WITH sessions AS (
+			SELECT connected_at, disconnected_at
+			FROM vpn_connections
+			WHERE connected_at IS NOT NULL AND connected_at < $2
+			  AND (disconnected_at IS NULL OR disconnected_at > $1) %[1]s
+		), events AS (
+			SELECT connected_at AS at, 1 AS delta FROM sessions
+			UNION ALL
+			SELECT disconnected_at, -1 FROM sessions
+			WHERE disconnected_at IS NOT NULL AND disconnected_at < $2
+		), running AS (
+			SELECT at, delta, SUM(delta) OVER (ORDER BY at, delta ROWS UNBOUNDED PRECEDING) AS concurrent
+			FROM events
+		), buckets AS (
+			SELECT bucket_start
+			FROM generate_series(date_trunc('%[2]s', $1::timestamp), $2::timestamp, interval '1 %[2]s') AS bucket_start
+			WHERE bucket_start < $2
+		)
+		SELECT b.bucket_start, GREATEST(COALESCE(seed.concurrent, 0), COALESCE(peak.concurrent, 0))::BIGINT
+		FROM buckets b
+		LEFT JOIN LATERAL (
+			SELECT concurrent
+			FROM running
+			WHERE at <= GREATEST(b.bucket_start, $1)
+			ORDER BY at DESC, delta DESC
+			LIMIT 1
+		) seed ON true
+		LEFT JOIN LATERAL (
+			SELECT MAX(concurrent) AS concurrent
+			FROM running
+			WHERE at > GREATEST(b.bucket_start, $1) AND at < b.bucket_start + interval '1 %[2]s'
+		) peak ON true
+		ORDER BY b.bucket_start
+	`, serverFilter, unit), args...)
+}
+
+// buildRegistrationsReport counts new accounts per day from auth_users.created_at
+func (api *ManagementAPI) buildRegistrationsReport(r *http.Request, report *shared.Report) error {
+	report.Granularity = shared.GranularityDay
+
+	return api.runReportQuery(report,
+		[]string{"day", "registrations"}, `
+		SELECT date_trunc('day', created_at) AS day, COUNT(*)
+		FROM auth_users
+		WHERE created_at >= $1 AND created_at < $2
+		GROUP BY 1
+		ORDER BY 1
+	`, report.From, report.To)
+}
+
+// buildChurnReport counts users who went inactive, based on last_login
+// A user churns on the day their last login becomes inactive_days old
+func (api *ManagementAPI) buildChurnReport(r *http.Request, report *shared.Report) error {
+	inactiveDays, err := intParam(r, "inactive_days", defaultChurnInactivity, 3650)
+	if err != nil {
+		return err
+	}
+	report.Granularity = shared.GranularityDay
+
+	err = api.runReportQuery(report,
+		[]string{"day", "churned"}, `
+		SELECT date_trunc('day', COALESCE(last_login, created_at) + make_interval(days => $3)) AS day, COUNT(*)
+		FROM auth_users
+		WHERE COALESCE(last_login, created_at) + make_interval(days => $3) >= $1
+		  AND COALESCE(last_login, created_at) + make_interval(days => $3) < LEAST($2, NOW())
+		GROUP BY 1
+		ORDER BY 1
+	`, report.From, report.To, inactiveDays)
+	if err != nil {
+		return err
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+
+	var total, active int64
+	err = conn.QueryRow(`
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE COALESCE(last_login, created_at) >= NOW() - make_interval(days => $1))
+		FROM auth_users
+		WHERE active = true
+	`, inactiveDays).Scan(&total, &active)
+	if err != nil {
+		return err
+	}
+
+	churnRate := 0.0
+	if total > 0 {
+		churnRate = float64(total-active) / float64(total)
+	}
+	report.Summary = map[string]interface{}{
+		"inactive_days": inactiveDays,
+		"total_users":   total,
+		"active_users":  active,
+		"churned_users": total - active,
+		"churn_rate":    churnRate,
+	}
+
+	return nil
+}
+
+// writeReportCSV writes the report rows as CSV in column order
+func writeReportCSV(w http.ResponseWriter, report *shared.Report) {
+	filename := fmt.Sprintf("%s-%s-%s.csv", report.Name, report.From.Format("20060102"), report.To.Format("20060102"))
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	writer := csv.NewWriter(w)
+	writer.Write(report.Columns)
+
+	record := make([]string, len(report.Columns))
+	for _, row := range report.Rows {
+		for i, col := range report.Columns {
+			record[i] = formatReportValue(row[col])
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("[REPORTS] Failed to write CSV for %s: %v", report.Name, err)
+	}
+}
+
+// formatReportValue renders a report cell for CSV
+func formatReportValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return value.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(value)
+	}
+}
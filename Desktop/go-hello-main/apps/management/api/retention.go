@@ -0,0 +1,694 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	defaultRetentionInterval  = 24 * time.Hour
+	defaultRetentionBatchSize = 5000
+	defaultRetentionRunLimit  = 20
+
+	// retentionBatchPause lets other writers in between batches
+	retentionBatchPause = 100 * time.Millisecond
+
+	// hashedIPPrefix marks addresses already replaced by a keyed hash
+	hashedIPPrefix = "hmac:"
+)
+
+var errRetentionRunning = errors.New("a retention run is already in progress")
+
+// retentionTable describes how a table is pruned and minimized
+type retentionTable struct {
+	timeColumn string
+	condition  string // rows must also match this to be pruned
+	ipColumn   string // empty when the table has no IPs to minimize
+}
+
+// retentionTables lists the tables retention policies may cover
+// audit_log is pruned by pruneAuditLog so the hash chain stays verifiable
+var retentionTables = map[string]retentionTable{
+	"vpn_statistics":        {timeColumn: "created_at"},
+	"vpn_statistics_hourly": {timeColumn: "bucket_start"},
+	"vpn_connections":       {timeColumn: "created_at", condition: "disconnected_at IS NOT NULL", ipColumn: "ip_address"},
+	"server_health": {timeColumn: "last_check",
+		// Keep each server's latest check, which recommendations and the reaper rely on
+		condition: "last_check < (SELECT MAX(h.last_check) FROM server_health h WHERE h.server_id = server_health.server_id)"},
+	"audit_log":             {timeColumn: "timestamp"},
+	"endnode_audit_log":     {timeColumn: "timestamp", ipColumn: "ip_address"},
+	"security_alerts":       {timeColumn: "created_at", condition: "status = 'resolved'", ipColumn: "ip_address"},
+	"user_source_locations": {timeColumn: "seen_at"},
+	"latency_measurements":  {timeColumn: "measured_at"},
+	"event_outbox":          {timeColumn: "created_at", condition: "published_at IS NOT NULL"},
+}
+
+// retentionMu ensures only one run prunes at a time
+var retentionMu sync.Mutex
+
+// retentionConfig returns the run interval and batch size from RETENTION_INTERVAL
+// (Go duration) and RETENTION_BATCH_SIZE
+func retentionConfig() (interval time.Duration, batchSize int) {
+	interval = defaultRetentionInterval
+	batchSize = defaultRetentionBatchSize
+
+	if d, err := time.ParseDuration(os.Getenv("RETENTION_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("RETENTION_BATCH_SIZE")); err == nil && n > 0 {
+		batchSize = n
+	}
+
+	return interval, batchSize
+}
+
+// runRetentionSchedule applies retention policies periodically
+func (api *ManagementAPI) runRetentionSchedule() {
+	interval, _ := retentionConfig()
+	log.Printf("[RETENTION] Applying retention policies every %v", interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		run, err := api.startRetentionRun("system", false)
+		if err != nil {
+			log.Printf("[RETENTION] Scheduled run skipped: %v", err)
+			continue
+		}
+		api.executeRetentionRun(run)
+	}
+}
+
+// startRetentionRun records a new run and takes the run lock
+// The caller must call executeRetentionRun, which releases it
+func (api *ManagementAPI) startRetentionRun(triggeredBy string, dryRun bool) (*shared.RetentionRun, error) {
+	if !retentionMu.TryLock() {
+		return nil, errRetentionRunning
+	}
+
+	run := &shared.RetentionRun{
+		StartedAt:   time.Now(),
+		DryRun:      dryRun,
+		TriggeredBy: triggeredBy,
+		Results:     []shared.RetentionTableResult{},
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	err := conn.QueryRow(`
+		INSERT INTO retention_runs (started_at, dry_run, triggered_by)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, run.StartedAt, run.DryRun, run.TriggeredBy).Scan(&run.ID)
+	if err != nil {
+		retentionMu.Unlock()
+		return nil, err
+	}
+
+	return run, nil
+}
+
+// executeRetentionRun applies every policy and stores the report
+// A failure on one table is recorded and the remaining tables are still processed
+func (api *ManagementAPI) executeRetentionRun(run *shared.RetentionRun) {
+	defer retentionMu.Unlock()
+
+	conn := api.manager.GetDB().GetConnection()
+	_, batchSize := retentionConfig()
+
+	policies, err := api.listRetentionPolicies()
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	hashKey := []byte(os.Getenv("RETENTION_IP_HASH_KEY"))
+	now := time.Now()
+
+	for _, policy := range policies {
+		table, ok := retentionTables[policy.Table]
+		if !ok {
+			continue
+		}
+
+		result := shared.RetentionTableResult{Table: policy.Table}
+
+		if policy.RetainDays != nil {
+			cutoff := now.AddDate(0, 0, -*policy.RetainDays)
+			result.Cutoff = &cutoff
+
+			if policy.Table == "audit_log" {
+				result.Deleted, result.Batches, err = pruneAuditLog(conn, cutoff, batchSize, run.DryRun)
+			} else {
+				result.Deleted, result.Batches, err = pruneTable(conn, policy.Table, table, cutoff, batchSize, run.DryRun)
+			}
+			if err != nil {
+				result.Error = fmt.Sprintf("prune failed: %v", err)
+			}
+		}
+
+		if policy.HashIPAfterDays != nil && result.Error == "" {
+			ipCutoff := now.AddDate(0, 0, -*policy.HashIPAfterDays)
+			result.IPCutoff = &ipCutoff
+
+			switch {
+			case table.ipColumn == "":
+				result.Skipped = "table has no IP addresses to minimize"
+			case len(hashKey) == 0:
+				result.Skipped = "RETENTION_IP_HASH_KEY is not set"
+			default:
+				hashed, batches, err := hashTableIPs(conn, policy.Table, table, ipCutoff, hashKey, batchSize, run.DryRun)
+				result.IPsHashed = hashed
+				result.Batches += batches
+				if err != nil {
+					result.Error = fmt.Sprintf("IP minimization failed: %v", err)
+				}
+			}
+		}
+
+		if result.Error != "" {
+			log.Printf("[RETENTION] %s: %s", policy.Table, result.Error)
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	finished := time.Now()
+	run.FinishedAt = &finished
+
+	results, _ := json.Marshal(run.Results)
+	if _, err := conn.Exec(`
+		UPDATE retention_runs SET finished_at = $1, results = $2, error = $3
+		WHERE id = $4
+	`, finished, results, nullString(run.Error), run.ID); err != nil {
+		log.Printf("[RETENTION] Failed to store report for run %d: %v", run.ID, err)
+	}
+
+	var deleted, hashed int64
+	for _, result := range run.Results {
+		deleted += result.Deleted
+		hashed += result.IPsHashed
+	}
+
+	summary := fmt.Sprintf("Retention run %d pruned %d rows and minimized %d IPs across %d tables",
+		run.ID, deleted, hashed, len(run.Results))
+	if run.DryRun {
+		summary = fmt.Sprintf("Retention dry run %d would prune %d rows and minimize %d IPs across %d tables",
+			run.ID, deleted, hashed, len(run.Results))
+	}
+	log.Printf("[RETENTION] %s", summary)
+
+	api.logAudit("RETENTION_RUN_COMPLETED", run.TriggeredBy, summary, "")
+}
+
+// retentionWhere returns the condition selecting rows older than $1
+func retentionWhere(table retentionTable) string {
+	where := table.timeColumn + " < $1"
+	if table.condition != "" {
+		where += " AND " + table.condition
+	}
+	return where
+}
+
+// pruneTable deletes expired rows in batches, returning rows deleted and batches run
+// Batches select rows by ctid so tables without a single-column key are handled alike
+func pruneTable(db *sql.DB, name string, table retentionTable, cutoff time.Time, batchSize int, dryRun bool) (int64, int, error) {
+	where := retentionWhere(table)
+
+	if dryRun {
+		var count int64
+		err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", name, where), cutoff).Scan(&count)
+		return count, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		DELETE FROM %s WHERE ctid = ANY(ARRAY(
+			SELECT ctid FROM %s WHERE %s LIMIT $2
+		))`, name, name, where)
+
+	var total int64
+	batches := 0
+	for {
+		result, err := db.Exec(query, cutoff, batchSize)
+		if err != nil {
+			return total, batches, err
+		}
+		n, _ := result.RowsAffected()
+		total += n
+		batches++
+
+		if n < int64(batchSize) {
+			return total, batches, nil
+		}
+		time.Sleep(retentionBatchPause)
+	}
+}
+
+// hashTableIPs replaces IP addresses older than cutoff with keyed hashes
+// Returns addresses hashed (or, in a dry run, that would be) and batches run
+func hashTableIPs(db *sql.DB, name string, table retentionTable, cutoff time.Time, key []byte, batchSize int, dryRun bool) (int64, int, error) {
+	where := fmt.Sprintf("%s < $1 AND %s IS NOT NULL AND %s NOT LIKE '%s%%'",
+		table.timeColumn, table.ipColumn, table.ipColumn, hashedIPPrefix)
+
+	if dryRun {
+		var count int64
+		err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", name, where), cutoff).Scan(&count)
+		return count, 0, err
+	}
+
+	var total int64
+	batches := 0
+	for {
+		updated, selected, err := hashIPBatch(db, name, table.ipColumn, where, cutoff, key, batchSize)
+		total += updated
+		if err != nil {
+			return total, batches, err
+		}
+		batches++
+
+		// Stop when the table is exhausted, or rows keep changing underneath us
+		if selected < batchSize || updated == 0 {
+			return total, batches, nil
+		}
+		time.Sleep(retentionBatchPause)
+	}
+}
+
+// hashIPBatch hashes one batch of addresses in a transaction
+func hashIPBatch(db *sql.DB, name, column, where string, cutoff time.Time, key []byte, batchSize int) (int64, int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(fmt.Sprintf(
+		"SELECT ctid::text, %s FROM %s WHERE %s LIMIT $2 FOR UPDATE", column, name, where,
+	), cutoff, batchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	type target struct{ ctid, ip string }
+	var targets []target
+	for rows.Next() {
+		var t target
+		if err := rows.Scan(&t.ctid, &t.ip); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		targets = append(targets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	var updated int64
+	for _, t := range targets {
+		result, err := tx.Exec(fmt.Sprintf(
+			"UPDATE %s SET %s = $1 WHERE ctid = $2::tid", name, column,
+		), hashIP(t.ip, key), t.ctid)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, _ := result.RowsAffected()
+		updated += n
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+	return updated, len(targets), nil
+}
+
+// hashIP returns a keyed hash of the address without its port
+// The same address always hashes alike, so rows can still be correlated
+func hashIP(address string, key []byte) string {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(host))
+	return hashedIPPrefix + hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// pruneAuditLog deletes the oldest audit entries in batches, anchoring the chain
+// Only a prefix of the log is removed: entries older than cutoff that come after
+// a newer entry are kept so the remaining chain has no gaps.
+func pruneAuditLog(db *sql.DB, cutoff time.Time, batchSize int, dryRun bool) (int64, int, error) {
+	// Everything before the first entry within the retention period is expired
+	var boundary sql.NullInt64
+	if err := db.QueryRow("SELECT MIN(id) FROM audit_log WHERE timestamp >= $1", cutoff).Scan(&boundary); err != nil {
+		return 0, 0, err
+	}
+	if !boundary.Valid {
+		if err := db.QueryRow("SELECT MAX(id) + 1 FROM audit_log").Scan(&boundary); err != nil || !boundary.Valid {
+			return 0, 0, err
+		}
+	}
+
+	if dryRun {
+		var count int64
+		err := db.QueryRow("SELECT COUNT(*) FROM audit_log WHERE id < $1", boundary.Int64).Scan(&count)
+		return count, 0, err
+	}
+
+	// Anchors are signed with the checkpoint key, since pruning removes the checkpoints they replace
+	key, err := auditSigningKeyFromEnv()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var total int64
+	batches := 0
+	for {
+		n, err := pruneAuditBatch(db, boundary.Int64, batchSize, key)
+		total += n
+		if err != nil {
+			return total, batches, err
+		}
+		if n == 0 {
+			return total, batches, nil
+		}
+		batches++
+		time.Sleep(retentionBatchPause)
+	}
+}
+
+// pruneAuditBatch deletes the oldest batch of entries below boundary and records the anchor
+// The anchor is signed when key is set and left unsigned otherwise
+func pruneAuditBatch(db *sql.DB, boundary int64, batchSize int, key ed25519.PrivateKey) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Writers link to the head row, not to the oldest entries, so only other
+	// pruning and checkpointing need to be held back
+	if err := lockAuditMaintenance(tx); err != nil {
+		return 0, err
+	}
+
+	var throughID sql.NullInt64
+	var chained int64
+	var lastHash sql.NullString
+	err = tx.QueryRow(`
+		SELECT MAX(id), COUNT(entry_hash),
+		       (ARRAY_AGG(entry_hash ORDER BY id DESC) FILTER (WHERE entry_hash IS NOT NULL))[1]
+		FROM (SELECT id, entry_hash FROM audit_log WHERE id < $1 ORDER BY id LIMIT $2) batch
+	`, boundary, batchSize).Scan(&throughID, &chained, &lastHash)
+	if err != nil {
+		return 0, err
+	}
+	if !throughID.Valid {
+		return 0, nil
+	}
+
+	anchor, err := latestAuditAnchor(tx)
+	if err != nil {
+		return 0, err
+	}
+	if !lastHash.Valid && anchor != nil {
+		lastHash = sql.NullString{String: anchor.hash, Valid: anchor.hash != ""}
+	}
+	var prunedBefore int64
+	if anchor != nil {
+		prunedBefore = anchor.count
+	}
+
+	count := prunedBefore + chained
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	var publicKey, signature sql.NullString
+	if key != nil {
+		publicKey = sql.NullString{String: base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)), Valid: true}
+		signature = sql.NullString{String: base64.StdEncoding.EncodeToString(ed25519.Sign(key,
+			anchorMessage(throughID.Int64, lastHash.String, count, createdAt))), Valid: true}
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO audit_chain_anchors (pruned_through_id, entry_hash, entry_count, public_key, signature, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, throughID.Int64, lastHash, count, publicKey, signature, createdAt); err != nil {
+		return 0, err
+	}
+
+	// Checkpoints inside the pruned range can no longer be checked
+	if _, err := tx.Exec("DELETE FROM audit_checkpoints WHERE last_audit_id <= $1", throughID.Int64); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec("DELETE FROM audit_log WHERE id <= $1", throughID.Int64)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := result.RowsAffected()
+
+	return n, tx.Commit()
+}
+
+// listRetentionPolicies returns all policies ordered by table
+func (api *ManagementAPI) listRetentionPolicies() ([]shared.RetentionPolicy, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query(`
+		SELECT table_name, retain_days, hash_ip_after_days, COALESCE(updated_by, ''), updated_at
+		FROM retention_policies
+		ORDER BY table_name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := []shared.RetentionPolicy{}
+	for rows.Next() {
+		var p shared.RetentionPolicy
+		var retain, hashAfter sql.NullInt64
+		if err := rows.Scan(&p.Table, &retain, &hashAfter, &p.UpdatedBy, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if retain.Valid {
+			days := int(retain.Int64)
+			p.RetainDays = &days
+		}
+		if hashAfter.Valid {
+			days := int(hashAfter.Int64)
+			p.HashIPAfterDays = &days
+		}
+		policies = append(policies, p)
+	}
+
+	return policies, rows.Err()
+}
+
+// handleRetention handles retention policy administration
+// GET  /api/retention/policies
+// PUT  /api/retention/policies/{table}  {"retain_days": 90, "hash_ip_after_days": 30}
+// POST /api/retention/run?dry_run=true
+// GET  /api/retention/runs?limit=
+// GET  /api/retention/runs/{id}
+func (api *ManagementAPI) handleRetention(w http.ResponseWriter, r *http.Request) {
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/retention"), "/"), "/")
+
+	switch {
+	case parts[0] == "policies" && len(parts) == 1 && r.Method == "GET":
+		policies, err := api.listRetentionPolicies()
+		if err != nil {
+			log.Printf("[ERROR] Failed to list retention policies: %v", err)
+			http.Error(w, "Failed to retrieve retention policies", http.StatusInternalServerError)
+			return
+		}
+		writeAPIResponse(w, http.StatusOK, "Retention policies retrieved successfully", policies)
+
+	case parts[0] == "policies" && len(parts) == 2 && r.Method == "PUT":
+		api.handleUpdateRetentionPolicy(w, r, admin, parts[1])
+
+	case parts[0] == "run" && len(parts) == 1 && r.Method == "POST":
+		dryRun := r.URL.Query().Get("dry_run") == "true"
+
+		run, err := api.startRetentionRun(admin, dryRun)
+		if err == errRetentionRunning {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to start retention run: %v", err)
+			http.Error(w, "Failed to start retention run", http.StatusInternalServerError)
+			return
+		}
+
+		api.auditRequest(r, "RETENTION_RUN_STARTED", admin, fmt.Sprintf("Retention run %d started (dry_run=%t)", run.ID, dryRun))
+		go api.executeRetentionRun(run)
+
+		writeAPIResponse(w, http.StatusAccepted, "Retention run started", run)
+
+	case parts[0] == "runs" && len(parts) == 1 && r.Method == "GET":
+		limit, err := intParam(r, "limit", defaultRetentionRunLimit, 200)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		runs, err := api.listRetentionRuns(0, limit)
+		if err != nil {
+			log.Printf("[ERROR] Failed to list retention runs: %v", err)
+			http.Error(w, "Failed to retrieve retention runs", http.StatusInternalServerError)
+			return
+		}
+		writeAPIResponse(w, http.StatusOK, fmt.Sprintf("Retrieved %d retention runs", len(runs)), runs)
+
+	case parts[0] == "runs" && len(parts) == 2 && r.Method == "GET":
+		id, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid run ID", http.StatusBadRequest)
+			return
+		}
+		runs, err := api.listRetentionRuns(id, 1)
+		if err != nil {
+			log.Printf("[ERROR] Failed to load retention run %d: %v", id, err)
+			http.Error(w, "Failed to retrieve retention run", http.StatusInternalServerError)
+			return
+		}
+		if len(runs) == 0 {
+			http.Error(w, "Retention run not found", http.StatusNotFound)
+			return
+		}
+		writeAPIResponse(w, http.StatusOK, "Retention run retrieved successfully", runs[0])
+
+	case parts[0] == "policies" || parts[0] == "run" || parts[0] == "runs":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// handleUpdateRetentionPolicy replaces a table's policy; null disables a step
+func (api *ManagementAPI) handleUpdateRetentionPolicy(w http.ResponseWriter, r *http.Request, admin, tableName string) {
+	table, ok := retentionTables[tableName]
+	if !ok {
+		names := make([]string, 0, len(retentionTables))
+		for name := range retentionTables {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		http.Error(w, fmt.Sprintf("Unknown table '%s' (supported: %s)", tableName, strings.Join(names, ", ")), http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		RetainDays      *int `json:"retain_days"`
+		HashIPAfterDays *int `json:"hash_ip_after_days"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if (req.RetainDays != nil && *req.RetainDays <= 0) || (req.HashIPAfterDays != nil && *req.HashIPAfterDays <= 0) {
+		http.Error(w, "Day counts must be positive, or null to disable", http.StatusBadRequest)
+		return
+	}
+	if req.HashIPAfterDays != nil && table.ipColumn == "" {
+		http.Error(w, fmt.Sprintf("IP minimization is not supported for %s", tableName), http.StatusBadRequest)
+		return
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	_, err := conn.Exec(`
+		INSERT INTO retention_policies (table_name, retain_days, hash_ip_after_days, updated_by, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (table_name) DO UPDATE
+		SET retain_days = EXCLUDED.retain_days, hash_ip_after_days = EXCLUDED.hash_ip_after_days,
+		    updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+	`, tableName, req.RetainDays, req.HashIPAfterDays, admin, time.Now())
+	if err != nil {
+		log.Printf("[ERROR] Failed to update retention policy for %s: %v", tableName, err)
+		http.Error(w, "Failed to update retention policy", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(r, "RETENTION_POLICY_UPDATED", admin,
+		fmt.Sprintf("Retention policy for %s - retain_days=%s hash_ip_after_days=%s",
+			tableName, formatOptionalDays(req.RetainDays), formatOptionalDays(req.HashIPAfterDays)))
+
+	policy := shared.RetentionPolicy{
+		Table:           tableName,
+		RetainDays:      req.RetainDays,
+		HashIPAfterDays: req.HashIPAfterDays,
+		UpdatedBy:       admin,
+		UpdatedAt:       time.Now(),
+	}
+	writeAPIResponse(w, http.StatusOK, "Retention policy updated successfully", policy)
+}
+
+// listRetentionRuns returns the newest runs, or the run with the given ID when id > 0
+func (api *ManagementAPI) listRetentionRuns(id int64, limit int) ([]shared.RetentionRun, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	query := `
+		SELECT id, started_at, finished_at, dry_run, triggered_by, results, COALESCE(error, '')
+		FROM retention_runs`
+	args := []interface{}{limit}
+	if id > 0 {
+		query += " WHERE id = $2"
+		args = append(args, id)
+	}
+	query += " ORDER BY started_at DESC LIMIT $1"
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []shared.RetentionRun{}
+	for rows.Next() {
+		var run shared.RetentionRun
+		var finished sql.NullTime
+		var results []byte
+		if err := rows.Scan(&run.ID, &run.StartedAt, &finished, &run.DryRun, &run.TriggeredBy, &results, &run.Error); err != nil {
+			return nil, err
+		}
+		if finished.Valid {
+			run.FinishedAt = &finished.Time
+		}
+		run.Results = []shared.RetentionTableResult{}
+		json.Unmarshal(results, &run.Results)
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// formatOptionalDays renders a nullable day count for audit details
+func formatOptionalDays(days *int) string {
+	if days == nil {
+		return "off"
+	}
+	return strconv.Itoa(*days)
+}
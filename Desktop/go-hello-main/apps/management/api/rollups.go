@@ -0,0 +1,373 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// Maximum ranges served per granularity
+const (
+	maxHourlyRange = 31 * 24 * time.Hour
+	maxDailyRange  = 3 * 366 * 24 * time.Hour
+)
+
+// rollupTables maps each granularity to its rollup table and date_trunc unit
+var rollupTables = map[string]struct {
+	table string
+	unit  string
+}{
+	shared.GranularityHour: {"vpn_statistics_hourly", "hour"},
+	shared.GranularityDay:  {"vpn_statistics_daily", "day"},
+}
+
+// upsertRollups adds a statistics sample to the hourly and daily rollups
+// createdAt must be the value stored on the raw row so buckets match a backfill
+func upsertRollups(tx *sql.Tx, username, serverID string, bytesIn, bytesOut int64, duration int, createdAt time.Time) error {
+	for _, granularity := range []string{shared.GranularityHour, shared.GranularityDay} {
+		rollup := rollupTables[granularity]
+
+		_, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s (bucket_start, username, server_id, bytes_in, bytes_out, duration_seconds, sample_count)
+			VALUES (date_trunc('%s', $1::timestamp), $2, $3, $4, $5, $6, 1)
+			ON CONFLICT (username, bucket_start, server_id) DO UPDATE
+			SET bytes_in = %s.bytes_in + EXCLUDED.bytes_in,
+			    bytes_out = %s.bytes_out + EXCLUDED.bytes_out,
+			    duration_seconds = %s.duration_seconds + EXCLUDED.duration_seconds,
+			    sample_count = %s.sample_count + 1
+		`, rollup.table, rollup.unit, rollup.table, rollup.table, rollup.table, rollup.table),
+			createdAt, username, serverID, bytesIn, bytesOut, duration)
+		if err != nil {
+			return fmt.Errorf("failed to update %s rollup: %v", granularity, err)
+		}
+	}
+
+	return nil
+}
+
+// getUsageSeries returns the user's usage per bucket between from and to
+// An empty serverID aggregates across all servers
+func (api *ManagementAPI) getUsageSeries(username, serverID, granularity string, from, to time.Time) (*shared.UsageSeries, error) {
+	rollup, ok := rollupTables[granularity]
+	if !ok {
+		return nil, fmt.Errorf("unknown granularity %q", granularity)
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+
+	query := fmt.Sprintf(`
+		SELECT bucket_start,
+		       SUM(bytes_in), SUM(bytes_out), SUM(duration_seconds), SUM(sample_count)
+		FROM %s
+		WHERE username = $1 AND bucket_start >= $2 AND bucket_start < $3`, rollup.table)
+	args := []interface{}{username, from, to}
+	if serverID != "" {
+		query += " AND server_id = $4"
+		args = append(args, serverID)
+	}
+	query += `
+		GROUP BY bucket_start
+		ORDER BY bucket_start`
+
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := &shared.UsageSeries{
+		Username:    username,
+		ServerID:    serverID,
+		Granularity: granularity,
+		From:        from,
+		To:          to,
+		Buckets:     []shared.UsageBucket{},
+	}
+
+	for rows.Next() {
+		var b shared.UsageBucket
+		if err := rows.Scan(&b.BucketStart, &b.BytesIn, &b.BytesOut, &b.DurationSeconds, &b.SampleCount); err != nil {
+			return nil, err
+		}
+		series.Buckets = append(series.Buckets, b)
+	}
+
+	return series, rows.Err()
+}
+
+// parseUsageRange reads granularity, from and to query parameters
+// Defaults to daily buckets over the last 30 days, or hourly over the last 24 hours
+func parseUsageRange(r *http.Request) (string, time.Time, time.Time, error) {
+	query := r.URL.Query()
+
+	granularity := query.Get("granularity")
+	if granularity == "" {
+		granularity = shared.GranularityDay
+	}
+	if _, ok := rollupTables[granularity]; !ok {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("granularity must be one of: hour, day")
+	}
+
+	step := 24 * time.Hour
+	maxRange := maxDailyRange
+	defaultRange := 30 * 24 * time.Hour
+	if granularity == shared.GranularityHour {
+		step = time.Hour
+		maxRange = maxHourlyRange
+		defaultRange = 24 * time.Hour
+	}
+
+	to := time.Now().UTC()
+	if v := query.Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, fmt.Errorf("invalid to: %v", err)
+		}
+		to = t
+	}
+
+	from := to.Add(-defaultRange)
+	if v := query.Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return "", time.Time{}, time.Time{}, fmt.Errorf("invalid from: %v", err)
+		}
+		from = t
+	}
+
+	// Align to bucket boundaries, rounding the end up so its bucket is included
+	from = from.Truncate(step)
+	if aligned := to.Truncate(step); !aligned.Equal(to) {
+		to = aligned.Add(step)
+	}
+
+	if !from.Before(to) {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > maxRange {
+		return "", time.Time{}, time.Time{}, fmt.Errorf("range too large for %s granularity (max %v)", granularity, maxRange)
+	}
+
+	return granularity, from, to, nil
+}
+
+// parseTimeParam accepts RFC 3339 timestamps, YYYY-MM-DD dates or Unix seconds
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(secs, 0).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("expected RFC 3339, YYYY-MM-DD or Unix seconds")
+}
+
+// handleStatsBackfill rebuilds rollups from raw statistics
+// POST /api/stats/backfill?from=&to=
+func (api *ManagementAPI) handleStatsBackfill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+
+	// Default to the full range of raw statistics
+	var first, last sql.NullTime
+	if err := conn.QueryRow("SELECT MIN(created_at), MAX(created_at) FROM vpn_statistics").Scan(&first, &last); err != nil {
+		log.Printf("[ROLLUP] Failed to read statistics range: %v", err)
+		http.Error(w, "Failed to read statistics range", http.StatusInternalServerError)
+		return
+	}
+
+	var from, to time.Time
+	if first.Valid {
+		from, to = first.Time, last.Time
+	}
+	if v := r.URL.Query().Get("from"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid from: %v", err), http.StatusBadRequest)
+			return
+		}
+		from = t
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid to: %v", err), http.StatusBadRequest)
+			return
+		}
+		to = t
+	}
+
+	// Backfill works in whole days
+	from = from.Truncate(24 * time.Hour)
+	to = to.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	if from.IsZero() {
+		http.Error(w, "Nothing to backfill", http.StatusBadRequest)
+		return
+	}
+
+	// Days retention has pruned only survive in the rollups; never rebuild them
+	earliest, err := api.earliestCompleteStatsDay()
+	if err != nil {
+		log.Printf("[ROLLUP] Failed to read statistics retention: %v", err)
+		http.Error(w, "Failed to read statistics retention", http.StatusInternalServerError)
+		return
+	}
+	if from.Before(earliest) {
+		if r.URL.Query().Get("from") != "" {
+			http.Error(w, fmt.Sprintf("from must be on or after %s; earlier raw statistics have been pruned", earliest.Format("2006-01-02")), http.StatusBadRequest)
+			return
+		}
+		from = earliest
+	}
+	if !from.Before(to) {
+		http.Error(w, "Nothing to backfill", http.StatusBadRequest)
+		return
+	}
+
+	backfill := &shared.RollupBackfill{
+		From: from,
+		To:   to,
+		Days: int(to.Sub(from) / (24 * time.Hour)),
+	}
+
+	api.auditRequest(
+		r,
+		"STATS_ROLLUP_BACKFILL_STARTED",
+		admin,
+		fmt.Sprintf("Rollup backfill started - from=%s to=%s days=%d", from.Format("2006-01-02"), to.Format("2006-01-02"), backfill.Days),
+	)
+
+	go api.runRollupBackfill(backfill, admin)
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   "Rollup backfill started",
+		Data:      backfill,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// runRollupBackfill rebuilds rollups one day at a time and reports the result
+func (api *ManagementAPI) runRollupBackfill(backfill *shared.RollupBackfill, admin string) {
+	for day := backfill.From; day.Before(backfill.To); day = day.Add(24 * time.Hour) {
+		// Retention may have moved on since the backfill started
+		earliest, err := api.earliestCompleteStatsDay()
+		if err == nil && day.Before(earliest) {
+			log.Printf("[ROLLUP] Skipping %s: raw statistics have been pruned", day.Format("2006-01-02"))
+			continue
+		}
+
+		hourly, daily, err := api.backfillRollupDay(day)
+		if err != nil {
+			log.Printf("[ROLLUP] Backfill failed at %s: %v", day.Format("2006-01-02"), err)
+			api.logAudit(
+				"STATS_ROLLUP_BACKFILL_FAILED",
+				admin,
+				fmt.Sprintf("Rollup backfill failed at %s: %v", day.Format("2006-01-02"), err),
+				"",
+			)
+			return
+		}
+		backfill.HourlyRows += hourly
+		backfill.DailyRows += daily
+	}
+
+	log.Printf("[ROLLUP] Backfill complete - days=%d hourly=%d daily=%d", backfill.Days, backfill.HourlyRows, backfill.DailyRows)
+	api.logAudit(
+		"STATS_ROLLUP_BACKFILL_COMPLETED",
+		admin,
+		fmt.Sprintf("Rollup backfill complete - days=%d hourly_rows=%d daily_rows=%d", backfill.Days, backfill.HourlyRows, backfill.DailyRows),
+		"",
+	)
+}
+
+// earliestCompleteStatsDay returns the first day retention has not pruned from vpn_statistics
+// It is zero when raw statistics are kept forever
+func (api *ManagementAPI) earliestCompleteStatsDay() (time.Time, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	var retainDays sql.NullInt64
+	err := conn.QueryRow("SELECT retain_days FROM retention_policies WHERE table_name = 'vpn_statistics'").Scan(&retainDays)
+	if err == sql.ErrNoRows || (err == nil && !retainDays.Valid) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The cutoff's own day is partly pruned, so start from the day after
+	cutoff := time.Now().AddDate(0, 0, -int(retainDays.Int64))
+	return cutoff.Truncate(24 * time.Hour).Add(24 * time.Hour), nil
+}
+
+// backfillRollupDay replaces one day's rollups with totals recomputed from raw statistics
+// Rebuilding from scratch makes the backfill safe to re-run over the same range; callers
+// must only pass days at or after earliestCompleteStatsDay
+func (api *ManagementAPI) backfillRollupDay(day time.Time) (int64, int64, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	// Block incremental upserts so no sample is counted twice or lost mid-rebuild
+	if _, err := tx.Exec("LOCK TABLE vpn_statistics_hourly, vpn_statistics_daily IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return 0, 0, err
+	}
+
+	end := day.Add(24 * time.Hour)
+	var counts [2]int64
+
+	for i, granularity := range []string{shared.GranularityHour, shared.GranularityDay} {
+		rollup := rollupTables[granularity]
+
+		if _, err := tx.Exec(fmt.Sprintf(
+			"DELETE FROM %s WHERE bucket_start >= $1 AND bucket_start < $2", rollup.table,
+		), day, end); err != nil {
+			return 0, 0, err
+		}
+
+		result, err := tx.Exec(fmt.Sprintf(`
+			INSERT INTO %s (bucket_start, username, server_id, bytes_in, bytes_out, duration_seconds, sample_count)
+			SELECT date_trunc('%s', created_at), username, COALESCE(server_id, ''),
+			       SUM(bytes_in), SUM(bytes_out), SUM(duration_seconds), COUNT(*)
+			FROM vpn_statistics
+			WHERE created_at >= $1 AND created_at < $2
+			GROUP BY 1, 2, 3
+		`, rollup.table, rollup.unit), day, end)
+		if err != nil {
+			return 0, 0, err
+		}
+		counts[i], _ = result.RowsAffected()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return counts[0], counts[1], nil
+}
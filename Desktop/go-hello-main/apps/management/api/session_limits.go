@@ -0,0 +1,161 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// Concurrent session limit policies
+const (
+	sessionLimitPolicyReject     = "reject"
+	sessionLimitPolicyKickOldest = "kick_oldest"
+
+	closeReasonKicked = "kicked"
+)
+
+// sessionLimitError is returned when a connect would exceed the user's session limit
+type sessionLimitError struct {
+	Limit  int
+	Active int
+}
+
+func (e *sessionLimitError) Error() string {
+	return fmt.Sprintf("concurrent session limit reached (%d of %d sessions active)", e.Active, e.Limit)
+}
+
+// kickedSession is a session closed to make room for a new one
+type kickedSession struct {
+	SessionID string
+	ServerID  string
+}
+
+// sessionLimitPolicy returns the policy from SESSION_LIMIT_POLICY (reject or kick_oldest)
+func sessionLimitPolicy() string {
+	if os.Getenv("SESSION_LIMIT_POLICY") == sessionLimitPolicyKickOldest {
+		return sessionLimitPolicyKickOldest
+	}
+	return sessionLimitPolicyReject
+}
+
+// defaultSessionLimit returns the limit for users without a plan or override
+// from DEFAULT_MAX_CONCURRENT_SESSIONS (0 means unlimited)
+func defaultSessionLimit() int {
+	if n, err := strconv.Atoi(os.Getenv("DEFAULT_MAX_CONCURRENT_SESSIONS")); err == nil && n > 0 {
+		return n
+	}
+	return 0
+}
+
+// getSessionLimit returns the user's concurrent session limit (0 means unlimited)
+// A per-user override takes precedence over the plan limit
+func getSessionLimit(tx *sql.Tx, username string) (int, error) {
+	var limit sql.NullInt64
+	err := tx.QueryRow(`
+		SELECT COALESCE(a.max_concurrent_sessions, p.max_concurrent_sessions)
+		FROM auth_users a
+		LEFT JOIN plans p ON p.id = a.plan_id
+		WHERE a.phone_number = $1
+	`, username).Scan(&limit)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	if limit.Valid {
+		return int(limit.Int64), nil
+	}
+	return defaultSessionLimit(), nil
+}
+
+// enforceSessionLimit checks the limit before a session becomes connected
+// Under the kick_oldest policy the oldest sessions are closed to make room and returned
+func enforceSessionLimit(tx *sql.Tx, username string, excludeID int, now time.Time) ([]kickedSession, error) {
+	limit, err := getSessionLimit(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, session_id, server_id
+		FROM vpn_connections
+		WHERE username = $1 AND disconnected_at IS NULL AND status = $2 AND id <> $3
+		ORDER BY connected_at ASC
+		FOR UPDATE
+	`, username, shared.SessionStateConnected, excludeID)
+	if err != nil {
+		return nil, err
+	}
+
+	type activeSession struct {
+		id int
+		kickedSession
+	}
+	var active []activeSession
+	for rows.Next() {
+		var s activeSession
+		if err := rows.Scan(&s.id, &s.SessionID, &s.ServerID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		active = append(active, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(active) < limit {
+		return nil, nil
+	}
+
+	if sessionLimitPolicy() != sessionLimitPolicyKickOldest {
+		return nil, &sessionLimitError{Limit: limit, Active: len(active)}
+	}
+
+	// Close the oldest sessions so that the new one fits within the limit
+	var kicked []kickedSession
+	for _, s := range active[:len(active)-limit+1] {
+		_, err := tx.Exec(`
+			UPDATE vpn_connections
+			SET status = $1, disconnected_at = $2, last_seen_at = $2, close_reason = $3,
+			    duration_seconds = GREATEST(0, EXTRACT(EPOCH FROM ($2 - connected_at))::INTEGER)
+			WHERE id = $4
+		`, shared.SessionStateDisconnected, now, closeReasonKicked, s.id)
+		if err != nil {
+			return nil, err
+		}
+		kicked = append(kicked, s.kickedSession)
+	}
+
+	return kicked, nil
+}
+
+// disconnectKickedSessions tells end-nodes to drop sessions closed by the limit policy
+func (api *ManagementAPI) disconnectKickedSessions(username string, kicked []kickedSession) {
+	for _, s := range kicked {
+		api.logAudit(
+			"VPN_SESSION_LIMIT_KICKED",
+			username,
+			fmt.Sprintf("Session %s on server %s closed to enforce concurrent session limit", s.SessionID, s.ServerID),
+			"",
+		)
+
+		endNode, err := api.findEndNode(s.ServerID)
+		if err != nil {
+			log.Printf("[SESSIONS] Cannot kick session %s: %v", s.SessionID, err)
+			continue
+		}
+
+		if err := api.disconnectUserOnEndNode(endNode, username, s.SessionID); err != nil {
+			log.Printf("[SESSIONS] Failed to disconnect session %s on %s: %v", s.SessionID, s.ServerID, err)
+		}
+	}
+}
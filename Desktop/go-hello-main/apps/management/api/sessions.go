@@ -0,0 +1,293 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// Session close reasons
+const (
+	closeReasonDisconnected  = "disconnected"
+	closeReasonError         = "error"
+	closeReasonTimeout       = "timeout"
+	closeReasonServerOffline = "server-offline"
+	closeReasonRevoked       = "revoked"
+)
+
+// sessionTransitions lists the states a session may move to from each state
+// The empty state means the user has no open session on the server
+var sessionTransitions = map[string][]string{
+	"": {
+		shared.SessionStateConnecting,
+		shared.SessionStateConnected,
+		shared.SessionStateError,
+	},
+	shared.SessionStateConnecting: {
+		shared.SessionStateConnecting,
+		shared.SessionStateConnected,
+		shared.SessionStateDisconnected,
+		shared.SessionStateError,
+	},
+	shared.SessionStateConnected: {
+		shared.SessionStateDisconnected,
+		shared.SessionStateError,
+	},
+}
+
+// validSessionID matches the UUIDs assigned to sessions
+var validSessionID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// errSessionNotFound is returned when a session ID does not match an open session
+var errSessionNotFound = fmt.Errorf("session not found or already closed")
+
+// sessionTransitionError is returned when a status update is not allowed from the current state
+type sessionTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *sessionTransitionError) Error() string {
+	if e.From == "" {
+		return fmt.Sprintf("cannot report %s without an open session", e.To)
+	}
+	return fmt.Sprintf("invalid session transition from %s to %s", e.From, e.To)
+}
+
+// isValidSessionTransition checks the session state machine
+func isValidSessionTransition(from, to string) bool {
+	for _, allowed := range sessionTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// isTerminalSessionState reports whether entering state closes the session
+func isTerminalSessionState(state string) bool {
+	return state == shared.SessionStateDisconnected || state == shared.SessionStateError
+}
+
+// sessionEvent is a client-reported change in connection status
+type sessionEvent struct {
+	Username  string
+	ServerID  string
+	SessionID string
+	Status    string
+	IPAddress string
+}
+
+// applySessionEvent moves the matching session through the state machine
+// A new session is opened when the user has no open session on the server;
+// disconnected and error events close the session and record its duration
+func (api *ManagementAPI) applySessionEvent(event sessionEvent) (*shared.VPNSession, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Serialize session changes per user so concurrent connects can't both pass the limit
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext($1))", event.Username); err != nil {
+		return nil, err
+	}
+
+	session, err := loadOpenSession(tx, event.Username, event.ServerID, event.SessionID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	// An explicit session ID must refer to an open session; only server-scoped
+	// events may open a new one
+	if session == nil && event.SessionID != "" {
+		return nil, errSessionNotFound
+	}
+
+	from := ""
+	if session != nil {
+		from = session.State
+	}
+
+	if !isValidSessionTransition(from, event.Status) {
+		return nil, &sessionTransitionError{From: from, To: event.Status}
+	}
+
+	now := time.Now()
+
+	var kicked []kickedSession
+	if event.Status == shared.SessionStateConnected {
+		excludeID := 0
+		if session != nil {
+			excludeID = session.ID
+		}
+		kicked, err = enforceSessionLimit(tx, event.Username, excludeID, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if session == nil {
+		session, err = openSession(tx, event, now)
+	} else {
+		err = advanceSession(tx, session, event, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if len(kicked) > 0 {
+		api.disconnectKickedSessions(event.Username, kicked)
+	}
+
+	return session, nil
+}
+
+// openSession inserts a new session row in the event's state
+func openSession(tx *sql.Tx, event sessionEvent, now time.Time) (*shared.VPNSession, error) {
+	session := &shared.VPNSession{
+		Username:   event.Username,
+		ServerID:   event.ServerID,
+		State:      event.Status,
+		IPAddress:  event.IPAddress,
+		LastSeenAt: now,
+		CreatedAt:  now,
+	}
+
+	var connectedAt, disconnectedAt, closeReason interface{}
+	if event.Status == shared.SessionStateConnected {
+		session.ConnectedAt = &now
+		connectedAt = now
+	}
+	if event.Status == shared.SessionStateError {
+		// A failed attempt without a prior session is recorded already closed
+		session.DisconnectedAt = &now
+		session.CloseReason = closeReasonError
+		disconnectedAt = now
+		closeReason = closeReasonError
+	}
+
+	err := tx.QueryRow(`
+		INSERT INTO vpn_connections
+			(username, status, server_id, ip_address, connected_at, disconnected_at,
+			 last_seen_at, duration_seconds, close_reason, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 0, $8, $7)
+		RETURNING id, session_id
+	`, event.Username, event.Status, event.ServerID, event.IPAddress,
+		connectedAt, disconnectedAt, now, closeReason).Scan(&session.ID, &session.SessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// advanceSession updates an open session to the event's state, closing it if terminal
+func advanceSession(tx *sql.Tx, session *shared.VPNSession, event sessionEvent, now time.Time) error {
+	session.State = event.Status
+	session.LastSeenAt = now
+	if event.IPAddress != "" {
+		session.IPAddress = event.IPAddress
+	}
+
+	if event.Status == shared.SessionStateConnected && session.ConnectedAt == nil {
+		session.ConnectedAt = &now
+	}
+
+	if isTerminalSessionState(event.Status) {
+		reason := closeReasonDisconnected
+		if event.Status == shared.SessionStateError {
+			reason = closeReasonError
+		}
+		closeSessionFields(session, reason, now)
+	}
+
+	_, err := tx.Exec(`
+		UPDATE vpn_connections
+		SET status = $1, ip_address = $2, connected_at = $3, disconnected_at = $4,
+		    last_seen_at = $5, duration_seconds = $6, close_reason = $7
+		WHERE id = $8
+	`, session.State, session.IPAddress, session.ConnectedAt, session.DisconnectedAt,
+		session.LastSeenAt, session.DurationSeconds, nullString(session.CloseReason), session.ID)
+	return err
+}
+
+// closeSessionFields marks a session closed and computes its connected duration
+func closeSessionFields(session *shared.VPNSession, reason string, now time.Time) {
+	session.DisconnectedAt = &now
+	session.CloseReason = reason
+	if session.ConnectedAt != nil {
+		session.DurationSeconds = int(now.Sub(*session.ConnectedAt).Seconds())
+	}
+}
+
+// loadOpenSession locks and returns the open session for a user
+// When sessionID is empty the most recent open session on serverID is used
+func loadOpenSession(tx *sql.Tx, username, serverID, sessionID string) (*shared.VPNSession, error) {
+	query := `
+		SELECT id, session_id, username, server_id, status, ip_address,
+		       connected_at, last_seen_at, created_at
+		FROM vpn_connections
+		WHERE username = $1 AND disconnected_at IS NULL AND `
+	var arg interface{}
+	if sessionID != "" {
+		query += "session_id = $2::uuid"
+		arg = sessionID
+	} else {
+		query += "server_id = $2"
+		arg = serverID
+	}
+	query += `
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE`
+
+	var session shared.VPNSession
+	var ipAddress sql.NullString
+	var connectedAt, lastSeenAt sql.NullTime
+
+	err := tx.QueryRow(query, username, arg).Scan(
+		&session.ID,
+		&session.SessionID,
+		&session.Username,
+		&session.ServerID,
+		&session.State,
+		&ipAddress,
+		&connectedAt,
+		&lastSeenAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if ipAddress.Valid {
+		session.IPAddress = ipAddress.String
+	}
+	if connectedAt.Valid {
+		session.ConnectedAt = &connectedAt.Time
+	}
+	session.LastSeenAt = session.CreatedAt
+	if lastSeenAt.Valid {
+		session.LastSeenAt = lastSeenAt.Time
+	}
+
+	return &session, nil
+}
+
+// nullString converts an empty string to a SQL NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -28,6 +31,7 @@ func (api *ManagementAPI) handleVPNStatus(w http.ResponseWriter, r *http.Request
 	var req struct {
 		Status    string `json:"status"` // connected, disconnected, connecting, error
 		ServerID  string `json:"server_id"`
+		SessionID string `json:"session_id,omitempty"`
 		IPAddress string `json:"ip_address,omitempty"`
 	}
 
@@ -48,23 +52,86 @@ func (api *ManagementAPI) handleVPNStatus(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Update connection status in database
-	if err := api.updateConnectionStatus(username, req.Status, req.ServerID, req.IPAddress); err != nil {
+	if req.ServerID == "" && req.SessionID == "" {
+		http.Error(w, "server_id or session_id is required", http.StatusBadRequest)
+		return
+	}
+	if req.SessionID != "" && !validSessionID.MatchString(req.SessionID) {
+		http.Error(w, "session_id must be a UUID", http.StatusBadRequest)
+		return
+	}
+
+	// Users who exhausted a suspending quota may not start new sessions
+	if req.Status == "connected" {
+		suspended, err := api.isQuotaSuspended(username)
+		if err != nil {
+			log.Printf("[QUOTA] Failed to check quota for user %s: %v", username, err)
+		} else if suspended {
+			api.audit(r, shared.AuditEvent{
+				Action:    "VPN_QUOTA_REJECTED",
+				Actor:     username,
+				Target:    req.ServerID,
+				Details:   fmt.Sprintf("Connection to server %s rejected - quota exhausted", req.ServerID),
+				IPAddress: req.IPAddress,
+			})
+			http.Error(w, "Quota exhausted for the current billing period", http.StatusForbidden)
+			return
+		}
+	}
+
+	// Apply the status update to the user's session
+	session, err := api.applySessionEvent(sessionEvent{
+		Username:  username,
+		ServerID:  req.ServerID,
+		SessionID: req.SessionID,
+		Status:    req.Status,
+		IPAddress: req.IPAddress,
+	})
+	if err == errSessionNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if limitErr, ok := err.(*sessionLimitError); ok {
+		api.audit(r, shared.AuditEvent{
+			Action:    "VPN_SESSION_LIMIT_REJECTED",
+			Actor:     username,
+			Target:    req.ServerID,
+			Details:   fmt.Sprintf("Connection to server %s rejected - %v", req.ServerID, limitErr),
+			IPAddress: req.IPAddress,
+		})
+		http.Error(w, limitErr.Error(), http.StatusConflict)
+		return
+	}
+	if transitionErr, ok := err.(*sessionTransitionError); ok {
+		http.Error(w, transitionErr.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to update connection status: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	api.queueAnomalyCheck(activityEvent{
+		Kind:     activityStatus,
+		Username: username,
+		ServerID: session.ServerID,
+		Status:   req.Status,
+		SourceIP: clientIP(r),
+	})
+
 	// Log the status update
-	api.logAudit(
-		"VPN_STATUS_UPDATE",
-		username,
-		fmt.Sprintf("VPN status updated to %s on server %s", req.Status, req.ServerID),
-		req.IPAddress,
-	)
+	api.audit(r, shared.AuditEvent{
+		Action:    "VPN_STATUS_UPDATE",
+		Actor:     username,
+		Target:    session.ServerID,
+		Details:   fmt.Sprintf("VPN status updated to %s on server %s (session %s)", req.Status, session.ServerID, session.SessionID),
+		IPAddress: req.IPAddress,
+	})
 
 	response := shared.APIResponse{
 		Success:   true,
 		Message:   fmt.Sprintf("Connection status updated to %s", req.Status),
+		Data:      session,
 		Timestamp: time.Now().Unix(),
 	}
 
@@ -111,12 +178,19 @@ func (api *ManagementAPI) handleVPNStats(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// A stats upload counts as a heartbeat for the session
+	if _, err := api.touchSessions(username, req.ServerID, ""); err != nil {
+		log.Printf("[ERROR] Failed to touch sessions for user %s: %v", username, err)
+	}
+
+	api.recordIngestedUsage(username, req.ServerID, req.BytesIn, req.BytesOut, req.Duration)
+
 	// Log the statistics update
-	api.logAudit(
+	api.auditRequest(
+		r,
 		"VPN_STATS_UPLOADED",
 		username,
 		fmt.Sprintf("Statistics uploaded - bytes_in=%d, bytes_out=%d, duration=%ds", req.BytesIn, req.BytesOut, req.Duration),
-		r.RemoteAddr,
 	)
 
 	response := shared.APIResponse{
@@ -130,7 +204,7 @@ func (api *ManagementAPI) handleVPNStats(w http.ResponseWriter, r *http.Request)
 }
 
 // handleGetUserStats handles retrieving user statistics
-// GET /vpn/stats/{username}
+// GET /vpn/stats/{username}?from=&to=&granularity=hour|day&server_id=&status=&cursor=&limit=
 func (api *ManagementAPI) handleGetUserStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -157,6 +231,19 @@ func (api *ManagementAPI) handleGetUserStats(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	granularity, from, to, err := parseUsageRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseConnectionFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Username = username
+
 	// Get user statistics from database
 	stats, err := api.getUserStatistics(username)
 	if err != nil {
@@ -165,23 +252,32 @@ func (api *ManagementAPI) handleGetUserStats(w http.ResponseWriter, r *http.Requ
 	}
 
 	// Get connection history
-	connections, err := api.getConnectionHistory(username)
+	connections, err := api.getConnectionHistory(filter)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Failed to retrieve connection history: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	// Get usage over time from the rollups
+	series, err := api.getUsageSeries(username, r.URL.Query().Get("server_id"), granularity, from, to)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to retrieve usage series: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	// Log the access
-	api.logAudit(
-		"VPN_STATS_ACCESSED",
-		authenticatedUser,
-		fmt.Sprintf("Statistics accessed for user %s", username),
-		r.RemoteAddr,
-	)
+	api.audit(r, shared.AuditEvent{
+		Action:  "VPN_STATS_ACCESSED",
+		Actor:   authenticatedUser,
+		Target:  username,
+		Details: fmt.Sprintf("Statistics accessed for user %s", username),
+	})
 
 	responseData := map[string]interface{}{
 		"summary":     stats,
-		"connections": connections,
+		"connections": connections.Connections,
+		"next_cursor": connections.NextCursor,
+		"series":      series,
 	}
 
 	response := shared.APIResponse{
@@ -192,46 +288,63 @@ func (api *ManagementAPI) handleGetUserStats(w http.ResponseWriter, r *http.Requ
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(connections.Total))
 	json.NewEncoder(w).Encode(response)
 }
 
-// updateConnectionStatus updates the VPN connection status in the database
-func (api *ManagementAPI) updateConnectionStatus(username, status, serverID, ipAddress string) error {
+// storeVPNStatistics stores VPN usage statistics in the database
+func (api *ManagementAPI) storeVPNStatistics(username, serverID string, bytesIn, bytesOut int64, duration int) error {
 	db := api.manager.GetDB()
 	conn := db.GetConnection()
 
-	query := `
-		INSERT INTO vpn_connections (username, status, server_id, ip_address, connected_at, disconnected_at, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`
-
-	var connectedAt, disconnectedAt interface{}
-	now := time.Now()
+	tx, err := conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	if status == "connected" {
-		connectedAt = now
-		disconnectedAt = nil
-	} else if status == "disconnected" {
-		connectedAt = nil
-		disconnectedAt = now
+	if _, err := insertVPNStatistics(tx, username, serverID, "", 0, bytesIn, bytesOut, duration, time.Now()); err != nil {
+		return err
 	}
 
-	_, err := conn.Exec(query, username, status, serverID, ipAddress, connectedAt, disconnectedAt, now)
-	return err
+	return tx.Commit()
 }
 
-// storeVPNStatistics stores VPN usage statistics in the database
-func (api *ManagementAPI) storeVPNStatistics(username, serverID string, bytesIn, bytesOut int64, duration int) error {
-	db := api.manager.GetDB()
-	conn := db.GetConnection()
+// recordIngestedUsage charges newly stored usage to the user's quota and queues an anomaly check
+func (api *ManagementAPI) recordIngestedUsage(username, serverID string, bytesIn, bytesOut int64, duration int) {
+	// Quota accounting must not cause the upload itself to fail
+	if err := api.accountUsage(username, serverID, bytesIn, bytesOut, duration); err != nil {
+		log.Printf("[QUOTA] Failed to account usage for user %s: %v", username, err)
+	}
 
+	api.queueAnomalyCheck(activityEvent{Kind: activityStats, Username: username, ServerID: serverID})
+}
+
+// insertVPNStatistics stores a raw statistics row and adds it to the rollups
+// streamID and seq identify batched samples and are left NULL for single uploads.
+// A sample already stored for the stream is skipped and false is returned.
+func insertVPNStatistics(tx *sql.Tx, username, serverID, streamID string, seq int64, bytesIn, bytesOut int64, duration int, createdAt time.Time) (bool, error) {
 	query := `
-		INSERT INTO vpn_statistics (username, server_id, bytes_in, bytes_out, duration_seconds, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		INSERT INTO vpn_statistics (username, server_id, bytes_in, bytes_out, duration_seconds, stream_id, seq, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (username, stream_id, seq) WHERE stream_id IS NOT NULL DO NOTHING
 	`
 
-	_, err := conn.Exec(query, username, serverID, bytesIn, bytesOut, duration, time.Now())
-	return err
+	var seqArg interface{}
+	if streamID != "" {
+		seqArg = seq
+	}
+
+	result, err := tx.Exec(query, username, serverID, bytesIn, bytesOut, duration, nullString(streamID), seqArg, createdAt)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	// Keep the rollups in step with the raw row
+	return true, upsertRollups(tx, username, serverID, bytesIn, bytesOut, duration, createdAt)
 }
 
 // getUserStatistics retrieves aggregated statistics for a user
@@ -239,13 +352,14 @@ func (api *ManagementAPI) getUserStatistics(username string) (*shared.UserStatis
 	db := api.manager.GetDB()
 	conn := db.GetConnection()
 
+	// Totals come from the daily rollup rather than scanning raw statistics
 	query := `
 		SELECT
 			COALESCE(SUM(bytes_in), 0) as total_bytes_in,
 			COALESCE(SUM(bytes_out), 0) as total_bytes_out,
 			COALESCE(SUM(duration_seconds), 0) as total_duration,
-			COUNT(*) as connection_count
-		FROM vpn_statistics
+			COALESCE(SUM(sample_count), 0) as connection_count
+		FROM vpn_statistics_daily
 		WHERE username = $1
 	`
 
@@ -265,41 +379,48 @@ func (api *ManagementAPI) getUserStatistics(username string) (*shared.UserStatis
 
 	// Get last connection time
 	lastConnQuery := `
-		SELECT created_at
+		SELECT MAX(connected_at)
 		FROM vpn_connections
-		WHERE username = $1 AND status = 'connected'
-		ORDER BY created_at DESC
-		LIMIT 1
+		WHERE username = $1
 	`
 
-	var lastConn time.Time
-	if err := conn.QueryRow(lastConnQuery, username).Scan(&lastConn); err == nil {
-		stats.LastConnection = lastConn
+	var lastConn sql.NullTime
+	if err := conn.QueryRow(lastConnQuery, username).Scan(&lastConn); err == nil && lastConn.Valid {
+		stats.LastConnection = lastConn.Time
 	}
 
 	return &stats, nil
 }
 
-// getConnectionHistory retrieves recent connection history for a user
-func (api *ManagementAPI) getConnectionHistory(username string) ([]shared.VPNConnectionStatus, error) {
+// getConnectionHistory retrieves a page of connection history matching the filter
+func (api *ManagementAPI) getConnectionHistory(filter *connectionFilter) (*shared.ConnectionPage, error) {
 	db := api.manager.GetDB()
 	conn := db.GetConnection()
 
-	query := `
+	page := &shared.ConnectionPage{Connections: []shared.VPNConnectionStatus{}}
+
+	where, args := filter.where(false)
+	if err := conn.QueryRow("SELECT COUNT(*) FROM vpn_connections "+where, args...).Scan(&page.Total); err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	where, args = filter.where(true)
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf(`
 		SELECT id, username, status, server_id, connected_at, disconnected_at, ip_address, created_at
 		FROM vpn_connections
-		WHERE username = $1
-		ORDER BY created_at DESC
-		LIMIT 50
-	`
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d
+	`, where, len(args))
 
-	rows, err := conn.Query(query, username)
+	rows, err := conn.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var connections []shared.VPNConnectionStatus
 	for rows.Next() {
 		var conn shared.VPNConnectionStatus
 		var connectedAt, disconnectedAt, ipAddress interface{}
@@ -330,10 +451,19 @@ func (api *ManagementAPI) getConnectionHistory(username string) ([]shared.VPNCon
 			conn.IPAddress = ipAddress.(string)
 		}
 
-		connections = append(connections, conn)
+		page.Connections = append(page.Connections, conn)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Connections) > filter.Limit {
+		page.Connections = page.Connections[:filter.Limit]
+		last := page.Connections[len(page.Connections)-1]
+		page.NextCursor = pageCursor{At: last.CreatedAt, ID: int64(last.ID)}.encode()
 	}
 
-	return connections, rows.Err()
+	return page, nil
 }
 
 // validateJWTToken validates the JWT token and returns the phone number (username)
@@ -379,3 +509,20 @@ func (api *ManagementAPI) isAdmin(username string) bool {
 	}
 	return false
 }
+
+// requireAdmin validates the JWT token and checks that the caller is an admin
+// Writes the error response and returns false if the request must not proceed
+func (api *ManagementAPI) requireAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username, err := api.validateJWTToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+
+	if !api.isAdmin(username) {
+		http.Error(w, "Forbidden - admin privileges required", http.StatusForbidden)
+		return "", false
+	}
+
+	return username, true
+}
@@ -0,0 +1,259 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+// maxStatsBatchSamples caps the number of samples accepted in one upload
+const maxStatsBatchSamples = 1000
+
+// counterRegressionError is returned when a cumulative counter goes backwards
+type counterRegressionError struct {
+	Seq     int64
+	Counter string
+	Last    int64
+	Value   int64
+}
+
+func (e *counterRegressionError) Error() string {
+	return fmt.Sprintf("counter %s regressed at seq %d (%d < %d)", e.Counter, e.Seq, e.Value, e.Last)
+}
+
+// statsCursor is the last accepted state of a client stream
+type statsCursor struct {
+	LastSeq  int64
+	BytesIn  int64
+	BytesOut int64
+	Duration int64
+}
+
+// handleVPNStatsBatch handles batched, idempotent statistics upload
+// POST /vpn/stats/batch
+func (api *ManagementAPI) handleVPNStatsBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Validate JWT token
+	username, err := api.validateJWTToken(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req shared.StatsBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateStatsBatch(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := api.ingestStatsBatch(username, &req)
+	if regression, ok := err.(*counterRegressionError); ok {
+		api.auditRequest(
+			r,
+			"VPN_STATS_BATCH_REJECTED",
+			username,
+			fmt.Sprintf("Statistics batch for session %s rejected - %v", req.SessionID, regression),
+		)
+		http.Error(w, regression.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to ingest statistics batch for user %s: %v", username, err)
+		http.Error(w, "Failed to store statistics", http.StatusInternalServerError)
+		return
+	}
+
+	// A stats upload counts as a heartbeat for the session
+	if _, err := api.touchSessions(username, req.ServerID, ""); err != nil {
+		log.Printf("[ERROR] Failed to touch sessions for user %s: %v", username, err)
+	}
+
+	if result.Accepted > 0 {
+		api.recordIngestedUsage(username, req.ServerID, result.BytesIn, result.BytesOut, int(result.Duration))
+	}
+
+	api.auditRequest(
+		r,
+		"VPN_STATS_UPLOADED",
+		username,
+		fmt.Sprintf("Statistics batch uploaded - session=%s accepted=%d duplicates=%d bytes_in=%d, bytes_out=%d, duration=%ds",
+			req.SessionID, result.Accepted, result.Duplicates, result.BytesIn, result.BytesOut, result.Duration),
+	)
+
+	response := shared.APIResponse{
+		Success:   true,
+		Message:   fmt.Sprintf("Accepted %d samples (%d duplicates ignored)", result.Accepted, result.Duplicates),
+		Data:      result,
+		Timestamp: time.Now().Unix(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// validateStatsBatch checks a batch and sorts its samples by sequence number
+func validateStatsBatch(req *shared.StatsBatchRequest) error {
+	if req.SessionID == "" {
+		return fmt.Errorf("session_id is required")
+	}
+
+	if req.Mode == "" {
+		req.Mode = shared.CounterModeDelta
+	}
+	if req.Mode != shared.CounterModeDelta && req.Mode != shared.CounterModeCumulative {
+		return fmt.Errorf("mode must be one of: delta, cumulative")
+	}
+
+	if len(req.Samples) == 0 {
+		return fmt.Errorf("samples are required")
+	}
+	if len(req.Samples) > maxStatsBatchSamples {
+		return fmt.Errorf("too many samples (max %d)", maxStatsBatchSamples)
+	}
+
+	sort.Slice(req.Samples, func(i, j int) bool {
+		return req.Samples[i].Seq < req.Samples[j].Seq
+	})
+
+	for i, s := range req.Samples {
+		if s.Seq <= 0 {
+			return fmt.Errorf("seq must be positive")
+		}
+		if i > 0 && s.Seq == req.Samples[i-1].Seq {
+			return fmt.Errorf("duplicate seq %d in batch", s.Seq)
+		}
+		if s.BytesIn < 0 || s.BytesOut < 0 || s.Duration < 0 {
+			return fmt.Errorf("invalid statistics values at seq %d", s.Seq)
+		}
+	}
+
+	return nil
+}
+
+// ingestStatsBatch stores the batch's new samples as deltas in one transaction
+// Delta samples already stored for the stream are skipped, whatever order batches
+// arrive in. Cumulative samples at or below the stream's cursor are replays and are
+// skipped; a cumulative counter lower than the previous value rejects the whole batch
+func (api *ManagementAPI) ingestStatsBatch(username string, req *shared.StatsBatchRequest) (*shared.StatsBatchResult, error) {
+	conn := api.manager.GetDB().GetConnection()
+
+	tx, err := conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cursor, err := loadStatsCursor(tx, username, req.SessionID, req.ServerID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &shared.StatsBatchResult{LastSeq: cursor.LastSeq}
+	now := time.Now()
+
+	for _, s := range req.Samples {
+		delta := s
+		if req.Mode == shared.CounterModeCumulative {
+			// Cumulative values only convert against the latest counters
+			if s.Seq <= cursor.LastSeq {
+				result.Duplicates++
+				continue
+			}
+
+			for _, c := range []struct {
+				name        string
+				last, value int64
+			}{
+				{"bytes_in", cursor.BytesIn, s.BytesIn},
+				{"bytes_out", cursor.BytesOut, s.BytesOut},
+				{"duration_seconds", cursor.Duration, s.Duration},
+			} {
+				if c.value < c.last {
+					return nil, &counterRegressionError{Seq: s.Seq, Counter: c.name, Last: c.last, Value: c.value}
+				}
+			}
+
+			delta.BytesIn = s.BytesIn - cursor.BytesIn
+			delta.BytesOut = s.BytesOut - cursor.BytesOut
+			delta.Duration = s.Duration - cursor.Duration
+			cursor.BytesIn, cursor.BytesOut, cursor.Duration = s.BytesIn, s.BytesOut, s.Duration
+		}
+
+		// The unique (username, stream_id, seq) index catches replays
+		inserted, err := insertVPNStatistics(tx, username, req.ServerID, req.SessionID, s.Seq,
+			delta.BytesIn, delta.BytesOut, int(delta.Duration), now)
+		if err != nil {
+			return nil, err
+		}
+		if s.Seq > cursor.LastSeq {
+			cursor.LastSeq = s.Seq
+		}
+		if !inserted {
+			result.Duplicates++
+			continue
+		}
+
+		result.Accepted++
+		result.BytesIn += delta.BytesIn
+		result.BytesOut += delta.BytesOut
+		result.Duration += delta.Duration
+	}
+	result.LastSeq = cursor.LastSeq
+
+	if result.Accepted > 0 {
+		_, err := tx.Exec(`
+			UPDATE stats_ingest_cursors
+			SET last_seq = $1, last_bytes_in = $2, last_bytes_out = $3, last_duration = $4, updated_at = $5
+			WHERE username = $6 AND stream_id = $7
+		`, cursor.LastSeq, cursor.BytesIn, cursor.BytesOut, cursor.Duration, now, username, req.SessionID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// loadStatsCursor locks the stream's cursor, creating it on first upload
+func loadStatsCursor(tx *sql.Tx, username, streamID, serverID string) (*statsCursor, error) {
+	_, err := tx.Exec(`
+		INSERT INTO stats_ingest_cursors (username, stream_id, server_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (username, stream_id) DO NOTHING
+	`, username, streamID, nullString(serverID))
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor statsCursor
+	err = tx.QueryRow(`
+		SELECT last_seq, last_bytes_in, last_bytes_out, last_duration
+		FROM stats_ingest_cursors
+		WHERE username = $1 AND stream_id = $2
+		FOR UPDATE
+	`, username, streamID).Scan(&cursor.LastSeq, &cursor.BytesIn, &cursor.BytesOut, &cursor.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
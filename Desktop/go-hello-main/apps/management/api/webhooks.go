@@ -0,0 +1,714 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"vpnmanager/pkg/shared"
+)
+
+const (
+	defaultWebhookMaxAttempts = 8
+
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 20
+	webhookTimeout      = 10 * time.Second
+
+	// webhookLease delays a claimed delivery so a crashed worker's claims are retried
+	webhookLease = 5 * time.Minute
+
+	webhookBaseBackoff = 30 * time.Second
+	webhookMaxBackoff  = 1 * time.Hour
+
+	// webhookMinSecretLength keeps caller-chosen secrets from being guessable
+	webhookMinSecretLength = 16
+)
+
+// enqueueWebhookEvent queues an event for every enabled subscription that wants it
+func enqueueWebhookEvent(exec sqlExecer, envelope shared.EventEnvelope) error {
+	event := shared.WebhookEvent{
+		ID:         envelope.ID,
+		Type:       envelope.Type,
+		OccurredAt: envelope.OccurredAt,
+		Data:       envelope.Event,
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %v", event.Type, err)
+	}
+
+	_, err = exec.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3
+		FROM webhook_subscriptions
+		WHERE enabled = true
+		  AND (jsonb_array_length(events) = 0 OR events @> jsonb_build_array($2::text))
+	`, event.ID, event.Type, payload)
+	if err != nil {
+		return fmt.Errorf("failed to queue %s event: %v", event.Type, err)
+	}
+	return nil
+}
+
+// subscribeWebhooks queues webhook deliveries for bus events subscribers can filter on
+// Delivery rows are durable, so outbox events are never marked published without them
+func subscribeWebhooks(bus *eventBus) {
+	bus.SubscribeDurable("webhooks", enqueueWebhookEvent, shared.WebhookEventTypes...)
+}
+
+// webhookDelivery is a claimed delivery with what is needed to send it
+type webhookDelivery struct {
+	id       int64
+	attempts int
+	eventID  string
+	event    string
+	payload  []byte
+	url      string
+	secret   string
+	enabled  bool
+}
+
+// runWebhookDelivery sends due deliveries until the process exits
+// WEBHOOK_MAX_ATTEMPTS sets how many attempts are made before a delivery is dead-lettered
+func (api *ManagementAPI) runWebhookDelivery() {
+	maxAttempts := defaultWebhookMaxAttempts
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && n > 0 {
+		maxAttempts = n
+	}
+
+	client := &http.Client{
+		Timeout: webhookTimeout,
+		// A redirect would resend the signed payload somewhere the subscriber did not register
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		conn := api.manager.GetDB().GetConnection()
+		for {
+			n, err := deliverDueWebhooks(conn, client, maxAttempts)
+			if err != nil {
+				log.Printf("[WEBHOOK] Delivery pass failed: %v", err)
+			}
+			// Keep going while there is a backlog
+			if err != nil || n < webhookBatchSize {
+				break
+			}
+		}
+	}
+}
+
+// deliverDueWebhooks claims and sends one batch of due deliveries, returning how many were claimed
+func deliverDueWebhooks(db *sql.DB, client *http.Client, maxAttempts int) (int, error) {
+	rows, err := db.Query(`
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $1 * INTERVAL '1 second'
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id
+		  AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		  )
+		RETURNING d.id, d.attempts, d.event_id, d.event_type, d.payload, s.url, s.secret, s.enabled
+	`, int(webhookLease.Seconds()), webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var due []webhookDelivery
+	for rows.Next() {
+		var d webhookDelivery
+		if err := rows.Scan(&d.id, &d.attempts, &d.eventID, &d.event, &d.payload, &d.url, &d.secret, &d.enabled); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, d := range due {
+		if !d.enabled {
+			// Dead-lettered rather than dropped so it can be replayed once re-enabled
+			recordWebhookAttempt(db, d, 0, fmt.Errorf("subscription is disabled"), maxAttempts, true)
+			continue
+		}
+
+		status, err := sendWebhook(client, d)
+		recordWebhookAttempt(db, d, status, err, maxAttempts, false)
+	}
+
+	return len(due), nil
+}
+
+// sendWebhook posts the payload, signed with the subscription secret
+// The signature is HMAC-SHA256 over "<timestamp>.<body>", so receivers can reject replays
+func sendWebhook(client *http.Client, d webhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest("POST", d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "vpnmanager-webhooks/1.0")
+	req.Header.Set("X-Webhook-ID", d.eventID)
+	req.Header.Set("X-Webhook-Event", d.event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(d.id, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+webhookSignature(d.secret, timestamp, d.payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookSignature returns the hex HMAC-SHA256 of "<timestamp>.<body>"
+func webhookSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordWebhookAttempt stores the outcome and schedules a retry, or dead-letters the delivery
+func recordWebhookAttempt(db *sql.DB, d webhookDelivery, statusCode int, sendErr error, maxAttempts int, dead bool) {
+	attempts := d.attempts + 1
+
+	var err error
+	switch {
+	case sendErr == nil:
+		_, err = db.Exec(`
+			UPDATE webhook_deliveries
+			SET status = 'delivered', attempts = $2, last_attempt_at = NOW(), last_status_code = $3,
+			    last_error = NULL, delivered_at = NOW()
+			WHERE id = $1
+		`, d.id, attempts, statusCode)
+
+	case dead || attempts >= maxAttempts:
+		log.Printf("[WEBHOOK] Delivery %d of %s dead after %d attempts: %v", d.id, d.event, attempts, sendErr)
+		_, err = db.Exec(`
+			UPDATE webhook_deliveries
+			SET status = 'dead', attempts = $2, last_attempt_at = NOW(), last_status_code = $3, last_error = $4
+			WHERE id = $1
+		`, d.id, attempts, nullInt(statusCode), sendErr.Error())
+
+	default:
+		_, err = db.Exec(`
+			UPDATE webhook_deliveries
+			SET attempts = $2, last_attempt_at = NOW(), last_status_code = $3, last_error = $4,
+			    next_attempt_at = NOW() + $5 * INTERVAL '1 millisecond'
+			WHERE id = $1
+		`, d.id, attempts, nullInt(statusCode), sendErr.Error(), webhookBackoff(attempts).Milliseconds())
+	}
+
+	if err != nil {
+		log.Printf("[WEBHOOK] Failed to record attempt for delivery %d: %v", d.id, err)
+	}
+}
+
+// webhookBackoff doubles the delay after each failed attempt, up to webhookMaxBackoff
+func webhookBackoff(attempts int) time.Duration {
+	delay := webhookBaseBackoff
+	for i := 1; i < attempts && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxBackoff {
+		delay = webhookMaxBackoff
+	}
+	return delay
+}
+
+// nullInt converts a zero status code to a SQL NULL
+func nullInt(n int) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+// handleWebhooks handles webhook administration
+// GET    /api/webhooks
+// POST   /api/webhooks                 {"url": "...", "events": ["user.created"], "description": "...", "secret": "..."}
+// GET    /api/webhooks/{id}
+// PUT    /api/webhooks/{id}            {"url", "events", "description", "enabled", "rotate_secret"} (all optional)
+// DELETE /api/webhooks/{id}
+// GET    /api/webhooks/{id}/deliveries?status=&limit=
+// GET    /api/webhooks/dead-letters?subscription_id=&limit=
+// POST   /api/webhooks/deliveries/{id}/replay
+func (api *ManagementAPI) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	admin, ok := api.requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/webhooks"), "/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "":
+		switch r.Method {
+		case "GET":
+			api.handleListWebhooks(w)
+		case "POST":
+			api.handleCreateWebhook(w, r, admin)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+
+	case path == "dead-letters":
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		subscriptionID := 0
+		if v := r.URL.Query().Get("subscription_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, "Invalid subscription_id", http.StatusBadRequest)
+				return
+			}
+			subscriptionID = id
+		}
+		api.handleListWebhookDeliveries(w, r, subscriptionID, shared.WebhookDeliveryDead)
+
+	case len(parts) == 3 && parts[0] == "deliveries" && parts[2] == "replay":
+		if r.Method != "POST" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+			return
+		}
+		api.handleReplayWebhookDelivery(w, r, admin, id)
+
+	default:
+		id, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "deliveries") {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+
+		if len(parts) == 2 {
+			if r.Method != "GET" {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if _, err := api.getWebhookSubscription(id); err == sql.ErrNoRows {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			api.handleListWebhookDeliveries(w, r, id, r.URL.Query().Get("status"))
+			return
+		}
+
+		switch r.Method {
+		case "GET":
+			subscription, err := api.getWebhookSubscription(id)
+			if err == sql.ErrNoRows {
+				http.Error(w, "Webhook not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				log.Printf("[ERROR] Failed to load webhook %d: %v", id, err)
+				http.Error(w, "Failed to retrieve webhook", http.StatusInternalServerError)
+				return
+			}
+			writeAPIResponse(w, http.StatusOK, "Webhook retrieved successfully", subscription)
+		case "PUT":
+			api.handleUpdateWebhook(w, r, admin, id)
+		case "DELETE":
+			api.handleDeleteWebhook(w, r, admin, id)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleListWebhooks lists all subscriptions
+func (api *ManagementAPI) handleListWebhooks(w http.ResponseWriter) {
+	conn := api.manager.GetDB().GetConnection()
+
+	rows, err := conn.Query("SELECT " + webhookColumns + " FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		log.Printf("[ERROR] Failed to list webhooks: %v", err)
+		http.Error(w, "Failed to retrieve webhooks", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	subscriptions := []shared.WebhookSubscription{}
+	for rows.Next() {
+		subscription, err := scanWebhookSubscription(rows)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan webhook: %v", err)
+			http.Error(w, "Failed to retrieve webhooks", http.StatusInternalServerError)
+			return
+		}
+		subscriptions = append(subscriptions, *subscription)
+	}
+
+	writeAPIResponse(w, http.StatusOK, fmt.Sprintf("Retrieved %d webhooks", len(subscriptions)), subscriptions)
+}
+
+// handleCreateWebhook creates a subscription; a secret is generated unless one is given
+func (api *ManagementAPI) handleCreateWebhook(w http.ResponseWriter, r *http.Request, admin string) {
+	var req struct {
+		URL         string   `json:"url"`
+		Events      []string `json:"events"`
+		Description string   `json:"description"`
+		Secret      string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := validateWebhookURL(req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateWebhookEvents(req.Events); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Secret) > 0 && len(req.Secret) < webhookMinSecretLength {
+		http.Error(w, fmt.Sprintf("secret must be at least %d characters", webhookMinSecretLength), http.StatusBadRequest)
+		return
+	}
+	if req.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			log.Printf("[ERROR] Failed to generate webhook secret: %v", err)
+			http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+			return
+		}
+		req.Secret = secret
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+
+	events, _ := json.Marshal(req.Events)
+	subscription := &shared.WebhookSubscription{
+		URL:         req.URL,
+		Secret:      req.Secret,
+		Events:      req.Events,
+		Description: req.Description,
+		Enabled:     true,
+		CreatedBy:   admin,
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	err := conn.QueryRow(`
+		INSERT INTO webhook_subscriptions (url, secret, events, description, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at
+	`, req.URL, req.Secret, events, nullString(req.Description), admin).Scan(
+		&subscription.ID, &subscription.CreatedAt, &subscription.UpdatedAt)
+	if err != nil {
+		log.Printf("[ERROR] Failed to create webhook: %v", err)
+		http.Error(w, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(r, "WEBHOOK_CREATED", admin,
+		fmt.Sprintf("Webhook %d created for %s (events: %s)", subscription.ID, req.URL, formatWebhookEvents(req.Events)))
+
+	writeAPIResponse(w, http.StatusCreated, "Webhook created; store the secret, it is not shown again", subscription)
+}
+
+// handleUpdateWebhook changes the given fields of a subscription
+func (api *ManagementAPI) handleUpdateWebhook(w http.ResponseWriter, r *http.Request, admin string, id int) {
+	var req struct {
+		URL          *string   `json:"url"`
+		Events       *[]string `json:"events"`
+		Description  *string   `json:"description"`
+		Enabled      *bool     `json:"enabled"`
+		RotateSecret bool      `json:"rotate_secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	subscription, err := api.getWebhookSubscription(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to load webhook %d: %v", id, err)
+		http.Error(w, "Failed to retrieve webhook", http.StatusInternalServerError)
+		return
+	}
+
+	var changes []string
+	if req.URL != nil {
+		if err := validateWebhookURL(*req.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		subscription.URL = *req.URL
+		changes = append(changes, "url="+*req.URL)
+	}
+	if req.Events != nil {
+		if err := validateWebhookEvents(*req.Events); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		subscription.Events = append([]string{}, *req.Events...)
+		changes = append(changes, "events="+formatWebhookEvents(subscription.Events))
+	}
+	if req.Description != nil {
+		subscription.Description = *req.Description
+		changes = append(changes, "description")
+	}
+	if req.Enabled != nil {
+		subscription.Enabled = *req.Enabled
+		changes = append(changes, fmt.Sprintf("enabled=%t", *req.Enabled))
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	events, _ := json.Marshal(subscription.Events)
+	query := `
+		UPDATE webhook_subscriptions
+		SET url = $2, events = $3, description = $4, enabled = $5, updated_at = NOW()`
+	args := []interface{}{id, subscription.URL, events, nullString(subscription.Description), subscription.Enabled}
+	if req.RotateSecret {
+		if subscription.Secret, err = newWebhookSecret(); err != nil {
+			log.Printf("[ERROR] Failed to generate webhook secret: %v", err)
+			http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+			return
+		}
+		args = append(args, subscription.Secret)
+		query += ", secret = $6"
+		changes = append(changes, "secret rotated")
+	}
+	query += " WHERE id = $1 RETURNING updated_at"
+
+	if err := conn.QueryRow(query, args...).Scan(&subscription.UpdatedAt); err != nil {
+		log.Printf("[ERROR] Failed to update webhook %d: %v", id, err)
+		http.Error(w, "Failed to update webhook", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(r, "WEBHOOK_UPDATED", admin, fmt.Sprintf("Webhook %d updated: %s", id, strings.Join(changes, ", ")))
+
+	writeAPIResponse(w, http.StatusOK, "Webhook updated successfully", subscription)
+}
+
+// handleDeleteWebhook removes a subscription and its delivery history
+func (api *ManagementAPI) handleDeleteWebhook(w http.ResponseWriter, r *http.Request, admin string, id int) {
+	conn := api.manager.GetDB().GetConnection()
+
+	result, err := conn.Exec("DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete webhook %d: %v", id, err)
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	api.auditRequest(r, "WEBHOOK_DELETED", admin, fmt.Sprintf("Webhook %d deleted", id))
+
+	writeAPIResponse(w, http.StatusOK, "Webhook deleted successfully", nil)
+}
+
+// handleListWebhookDeliveries lists deliveries, newest first
+// subscriptionID 0 matches every subscription; status "" matches every status
+func (api *ManagementAPI) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request, subscriptionID int, status string) {
+	switch status {
+	case "", shared.WebhookDeliveryPending, shared.WebhookDeliveryDelivered, shared.WebhookDeliveryDead:
+	default:
+		http.Error(w, "status must be one of: pending, delivered, dead", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := intParam(r, "limit", 50, 500)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn := api.manager.GetDB().GetConnection()
+	rows, err := conn.Query(`
+		SELECT id, subscription_id, event_id, event_type, status, attempts, next_attempt_at, last_attempt_at,
+		       COALESCE(last_status_code, 0), COALESCE(last_error, ''), delivered_at, COALESCE(replay_of, 0),
+		       created_at, payload
+		FROM webhook_deliveries
+		WHERE ($1 = 0 OR subscription_id = $1) AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3
+	`, subscriptionID, status, limit)
+	if err != nil {
+		log.Printf("[ERROR] Failed to list webhook deliveries: %v", err)
+		http.Error(w, "Failed to retrieve webhook deliveries", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []shared.WebhookDelivery{}
+	for rows.Next() {
+		var d shared.WebhookDelivery
+		var nextAttemptAt, lastAttemptAt, deliveredAt sql.NullTime
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Status, &d.Attempts,
+			&nextAttemptAt, &lastAttemptAt, &d.LastStatusCode, &d.LastError, &deliveredAt, &d.ReplayOf,
+			&d.CreatedAt, &payload)
+		if err != nil {
+			log.Printf("[ERROR] Failed to scan webhook delivery: %v", err)
+			http.Error(w, "Failed to retrieve webhook deliveries", http.StatusInternalServerError)
+			return
+		}
+
+		if nextAttemptAt.Valid && d.Status == shared.WebhookDeliveryPending {
+			d.NextAttemptAt = &nextAttemptAt.Time
+		}
+		if lastAttemptAt.Valid {
+			d.LastAttemptAt = &lastAttemptAt.Time
+		}
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		d.Event = &shared.WebhookEvent{}
+		json.Unmarshal(payload, d.Event)
+
+		deliveries = append(deliveries, d)
+	}
+
+	writeAPIResponse(w, http.StatusOK, fmt.Sprintf("Retrieved %d deliveries", len(deliveries)), deliveries)
+}
+
+// handleReplayWebhookDelivery queues a delivery again under a new delivery ID
+// The event ID is unchanged so receivers can recognize a replay they already processed
+func (api *ManagementAPI) handleReplayWebhookDelivery(w http.ResponseWriter, r *http.Request, admin string, id int64) {
+	conn := api.manager.GetDB().GetConnection()
+
+	var replayID int64
+	err := conn.QueryRow(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, replay_of)
+		SELECT subscription_id, event_id, event_type, payload, id
+		FROM webhook_deliveries
+		WHERE id = $1 AND status <> 'pending'
+		RETURNING id
+	`, id).Scan(&replayID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Delivery not found or still pending", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to replay webhook delivery %d: %v", id, err)
+		http.Error(w, "Failed to replay delivery", http.StatusInternalServerError)
+		return
+	}
+
+	api.auditRequest(r, "WEBHOOK_REPLAYED", admin, fmt.Sprintf("Webhook delivery %d replayed as %d", id, replayID))
+
+	writeAPIResponse(w, http.StatusAccepted, "Delivery queued for replay", map[string]interface{}{
+		"delivery_id": replayID,
+		"replay_of":   id,
+	})
+}
+
+// webhookColumns are the webhook_subscriptions columns read by scanWebhookSubscription
+const webhookColumns = "id, url, events, COALESCE(description, ''), enabled, created_by, created_at, updated_at"
+
+// getWebhookSubscription loads one subscription without its secret
+func (api *ManagementAPI) getWebhookSubscription(id int) (*shared.WebhookSubscription, error) {
+	conn := api.manager.GetDB().GetConnection()
+	return scanWebhookSubscription(conn.QueryRow("SELECT "+webhookColumns+" FROM webhook_subscriptions WHERE id = $1", id))
+}
+
+// scanWebhookSubscription scans a row selected with webhookColumns
+func scanWebhookSubscription(row rowScanner) (*shared.WebhookSubscription, error) {
+	var s shared.WebhookSubscription
+	var events []byte
+	if err := row.Scan(&s.ID, &s.URL, &events, &s.Description, &s.Enabled, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	s.Events = []string{}
+	json.Unmarshal(events, &s.Events)
+	return &s, nil
+}
+
+// validateWebhookURL requires an absolute http or https URL
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// validateWebhookEvents checks every event filter names a known event type
+func validateWebhookEvents(events []string) error {
+	for _, event := range events {
+		known := false
+		for _, t := range shared.WebhookEventTypes {
+			if event == t {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown event '%s' (supported: %s)", event, strings.Join(shared.WebhookEventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// formatWebhookEvents renders an event filter for audit details
+func formatWebhookEvents(events []string) string {
+	if len(events) == 0 {
+		return "all"
+	}
+	return strings.Join(events, ",")
+}
+
+// newWebhookSecret returns a random 256-bit hex secret
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,83 @@
+// Command audit-verify walks the management server's audit chain and reports the first break.
+//
+// Usage:
+//
+//	audit-verify [-database-url URL] [-json]
+//
+// The database URL defaults to DATABASE_URL. Checkpoint signatures are verified
+// with AUDIT_VERIFY_KEY, or the key derived from AUDIT_SIGNING_KEY.
+// Exits 0 when the chain is intact, 1 when it is broken and 2 on error.
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"vpnmanager/apps/management/api"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	databaseURL := flag.String("database-url", os.Getenv("DATABASE_URL"), "PostgreSQL connection URL")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	if *databaseURL == "" {
+		fmt.Fprintln(os.Stderr, "audit-verify: set -database-url or DATABASE_URL")
+		os.Exit(2)
+	}
+
+	publicKey, err := api.AuditVerifyKeyFromEnv()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit-verify: %v\n", err)
+		os.Exit(2)
+	}
+
+	db, err := sql.Open("postgres", *databaseURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit-verify: failed to open database: %v\n", err)
+		os.Exit(2)
+	}
+	defer db.Close()
+
+	report, err := api.VerifyAuditChain(db, publicKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "audit-verify: verification failed: %v\n", err)
+		os.Exit(2)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		fmt.Printf("Entries checked:     %d\n", report.EntriesChecked)
+		fmt.Printf("Unchained (legacy):  %d\n", report.UnchainedEntries)
+		fmt.Printf("Pruned (retention):  %d\n", report.PrunedEntries)
+		fmt.Printf("Checkpoints checked: %d\n", report.CheckpointsChecked)
+		if !report.SignaturesVerified {
+			fmt.Println("Signatures:          not verified (no AUDIT_VERIFY_KEY or AUDIT_SIGNING_KEY)")
+		}
+		if report.HeadID != 0 {
+			fmt.Printf("Chain head:          entry %d %s\n", report.HeadID, report.HeadHash)
+		}
+	}
+
+	if !report.Valid {
+		b := report.FirstBreak
+		if b.CheckpointID != 0 {
+			fmt.Fprintf(os.Stderr, "BROKEN at entry %d (checkpoint %d): %s\n", b.AuditID, b.CheckpointID, b.Reason)
+		} else {
+			fmt.Fprintf(os.Stderr, "BROKEN at entry %d: %s\n", b.AuditID, b.Reason)
+		}
+		os.Exit(1)
+	}
+
+	if !*asJSON {
+		fmt.Println("Audit chain intact")
+	}
+}
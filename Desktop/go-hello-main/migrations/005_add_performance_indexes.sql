@@ -38,12 +38,14 @@ CREATE INDEX IF NOT EXISTS idx_vpn_connections_active
     WHERE disconnected_at IS NULL;
 
 -- Index for audit log queries
-CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at
-    ON audit_logs(created_at DESC)
-    WHERE created_at IS NOT NULL;
-
-CREATE INDEX IF NOT EXISTS idx_audit_logs_user_action
-    ON audit_logs(user_id, action, created_at DESC);
+-- NOTE: these targeted a non-existent audit_logs table; audit_log indexes
+-- are created by 019_unify_audit_log
+-- CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at
+--     ON audit_logs(created_at DESC)
+--     WHERE created_at IS NOT NULL;
+--
+-- CREATE INDEX IF NOT EXISTS idx_audit_logs_user_action
+--     ON audit_logs(user_id, action, created_at DESC);
 
 -- Index for server health monitoring
 CREATE INDEX IF NOT EXISTS idx_server_health_status_check
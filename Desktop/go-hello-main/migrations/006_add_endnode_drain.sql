@@ -0,0 +1,49 @@
+-- =====================================================
+-- Migration: 006_add_endnode_drain
+-- Description: Track end-node drain state and user migration progress
+-- Created: 2025-11-24
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- A draining server receives no new user assignments
+ALTER TABLE servers
+    ADD COLUMN IF NOT EXISTS draining BOOLEAN NOT NULL DEFAULT false;
+
+-- One row per drain run; the latest row for a server is its current drain
+CREATE TABLE IF NOT EXISTS server_drains (
+    id              SERIAL PRIMARY KEY,
+    server_id       VARCHAR(255) NOT NULL,
+    status          VARCHAR(32)  NOT NULL DEFAULT 'running',
+    total_users     INTEGER      NOT NULL DEFAULT 0,
+    migrated_users  INTEGER      NOT NULL DEFAULT 0,
+    failed_users    INTEGER      NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    started_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+    completed_at    TIMESTAMP,
+    CONSTRAINT server_drains_status_check
+        CHECK (status IN ('running', 'completed', 'failed'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_server_drains_server_started
+    ON server_drains(server_id, started_at DESC);
+
+-- Only one drain may run per server at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_server_drains_running
+    ON server_drains(server_id)
+    WHERE status = 'running';
+
+COMMENT ON COLUMN servers.draining IS 'Server is being drained and must not receive new users';
+COMMENT ON TABLE server_drains IS 'Progress of user migrations off draining end-nodes';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_server_drains_running;
+DROP INDEX IF EXISTS idx_server_drains_server_started;
+DROP TABLE IF EXISTS server_drains;
+ALTER TABLE servers DROP COLUMN IF EXISTS draining;
+
+*/
@@ -0,0 +1,41 @@
+-- =====================================================
+-- Migration: 007_add_server_capacity_and_preferences
+-- Description: Per-server capacity and weights, and user server preferences
+-- Created: 2025-11-25
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Maximum active users a server should carry, and its relative preference weight
+ALTER TABLE servers
+    ADD COLUMN IF NOT EXISTS capacity INTEGER NOT NULL DEFAULT 50,
+    ADD COLUMN IF NOT EXISTS weight   REAL    NOT NULL DEFAULT 1.0;
+
+ALTER TABLE servers
+    ADD CONSTRAINT servers_capacity_check CHECK (capacity > 0),
+    ADD CONSTRAINT servers_weight_check   CHECK (weight > 0);
+
+-- Per-user preferences used when recommending servers
+CREATE TABLE IF NOT EXISTS user_server_preferences (
+    username               VARCHAR(255) PRIMARY KEY,
+    preferred_location_id  INTEGER REFERENCES server_locations(id) ON DELETE SET NULL,
+    preferred_country_code CHAR(2),
+    updated_at             TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN servers.capacity IS 'Maximum number of active users assigned to this server';
+COMMENT ON COLUMN servers.weight IS 'Relative weight applied to the recommendation score';
+COMMENT ON TABLE user_server_preferences IS 'User location preferences for server recommendation';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP TABLE IF EXISTS user_server_preferences;
+ALTER TABLE servers DROP CONSTRAINT IF EXISTS servers_weight_check;
+ALTER TABLE servers DROP CONSTRAINT IF EXISTS servers_capacity_check;
+ALTER TABLE servers DROP COLUMN IF EXISTS weight;
+ALTER TABLE servers DROP COLUMN IF EXISTS capacity;
+
+*/
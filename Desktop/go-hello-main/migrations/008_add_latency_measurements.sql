@@ -0,0 +1,50 @@
+-- =====================================================
+-- Migration: 008_add_latency_measurements
+-- Description: Store client-reported RTT measurements to servers
+-- Created: 2025-11-26
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+CREATE TABLE IF NOT EXISTS latency_measurements (
+    id               BIGSERIAL PRIMARY KEY,
+    username         VARCHAR(255) NOT NULL,
+    server_id        VARCHAR(255) NOT NULL,
+    location_id      INTEGER      NOT NULL REFERENCES server_locations(id) ON DELETE CASCADE,
+    client_network   VARCHAR(64),
+    client_asn       BIGINT,
+    client_latitude  DOUBLE PRECISION,
+    client_longitude DOUBLE PRECISION,
+    rtt_ms           INTEGER      NOT NULL,
+    measured_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+    CONSTRAINT latency_measurements_rtt_check CHECK (rtt_ms > 0)
+);
+
+-- Percentile lookups per location, narrowed by client network or ASN
+CREATE INDEX IF NOT EXISTS idx_latency_measurements_location_network
+    ON latency_measurements(location_id, client_network, measured_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_latency_measurements_location_asn
+    ON latency_measurements(location_id, client_asn, measured_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_latency_measurements_server_network
+    ON latency_measurements(server_id, client_network, measured_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_latency_measurements_measured_at
+    ON latency_measurements(measured_at DESC);
+
+COMMENT ON TABLE latency_measurements IS 'Client-measured round-trip times to VPN servers';
+COMMENT ON COLUMN latency_measurements.client_network IS 'Client network prefix (/24 for IPv4, /48 for IPv6)';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_latency_measurements_measured_at;
+DROP INDEX IF EXISTS idx_latency_measurements_server_network;
+DROP INDEX IF EXISTS idx_latency_measurements_location_asn;
+DROP INDEX IF EXISTS idx_latency_measurements_location_network;
+DROP TABLE IF EXISTS latency_measurements;
+
+*/
@@ -0,0 +1,44 @@
+-- =====================================================
+-- Migration: 009_add_location_admin_constraints
+-- Description: Constraints and bookkeeping for admin-managed server locations
+-- Created: 2025-11-27
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+ALTER TABLE server_locations
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- Reject coordinates outside the valid range
+ALTER TABLE server_locations
+    ADD CONSTRAINT server_locations_latitude_check
+        CHECK (latitude IS NULL OR latitude BETWEEN -90 AND 90),
+    ADD CONSTRAINT server_locations_longitude_check
+        CHECK (longitude IS NULL OR longitude BETWEEN -180 AND 180),
+    ADD CONSTRAINT server_locations_country_code_check
+        CHECK (country_code ~ '^[A-Z]{2}$');
+
+-- A city can only be listed once per country
+CREATE UNIQUE INDEX IF NOT EXISTS idx_server_locations_country_city
+    ON server_locations(country_code, LOWER(city));
+
+CREATE INDEX IF NOT EXISTS idx_servers_location_id
+    ON servers(location_id);
+
+COMMENT ON INDEX idx_server_locations_country_city IS 'Prevents duplicate locations for the same city';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_servers_location_id;
+DROP INDEX IF EXISTS idx_server_locations_country_city;
+ALTER TABLE server_locations DROP CONSTRAINT IF EXISTS server_locations_country_code_check;
+ALTER TABLE server_locations DROP CONSTRAINT IF EXISTS server_locations_longitude_check;
+ALTER TABLE server_locations DROP CONSTRAINT IF EXISTS server_locations_latitude_check;
+ALTER TABLE server_locations DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE server_locations DROP COLUMN IF EXISTS created_at;
+
+*/
@@ -0,0 +1,54 @@
+-- =====================================================
+-- Migration: 010_add_vpn_session_lifecycle
+-- Description: Turn vpn_connections rows into sessions spanning connect to disconnect
+-- Created: 2025-11-28
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+ALTER TABLE vpn_connections
+    ADD COLUMN IF NOT EXISTS session_id       UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN IF NOT EXISTS last_seen_at     TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS duration_seconds INTEGER,
+    ADD COLUMN IF NOT EXISTS close_reason     VARCHAR(32);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vpn_connections_session_id
+    ON vpn_connections(session_id);
+
+-- Before sessions, every status post created its own row and none were ever closed.
+-- Close them so only sessions opened from now on count as active.
+UPDATE vpn_connections
+SET disconnected_at = COALESCE(connected_at, created_at),
+    last_seen_at    = created_at,
+    close_reason    = 'legacy'
+WHERE disconnected_at IS NULL;
+
+-- The previous active-connections index was keyed on user_id, which the API never writes
+DROP INDEX IF EXISTS idx_vpn_connections_active;
+
+CREATE INDEX IF NOT EXISTS idx_vpn_connections_active
+    ON vpn_connections(username, server_id, created_at DESC)
+    WHERE disconnected_at IS NULL;
+
+COMMENT ON COLUMN vpn_connections.session_id IS 'Client-visible identifier of the VPN session';
+COMMENT ON COLUMN vpn_connections.close_reason IS 'Why the session ended (disconnected, error, legacy, ...)';
+COMMENT ON INDEX idx_vpn_connections_active IS 'Optimizes lookup of open VPN sessions';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_vpn_connections_active;
+CREATE INDEX IF NOT EXISTS idx_vpn_connections_active
+    ON vpn_connections(user_id, connected_at)
+    WHERE disconnected_at IS NULL;
+DROP INDEX IF EXISTS idx_vpn_connections_session_id;
+ALTER TABLE vpn_connections DROP COLUMN IF EXISTS close_reason;
+ALTER TABLE vpn_connections DROP COLUMN IF EXISTS duration_seconds;
+ALTER TABLE vpn_connections DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE vpn_connections DROP COLUMN IF EXISTS session_id;
+
+*/
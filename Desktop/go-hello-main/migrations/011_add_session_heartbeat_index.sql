@@ -0,0 +1,23 @@
+-- =====================================================
+-- Migration: 011_add_session_heartbeat_index
+-- Description: Support the stale session reaper
+-- Created: 2025-11-29
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Reaper scans open sessions by last heartbeat
+CREATE INDEX IF NOT EXISTS idx_vpn_connections_open_last_seen
+    ON vpn_connections(last_seen_at)
+    WHERE disconnected_at IS NULL;
+
+COMMENT ON INDEX idx_vpn_connections_open_last_seen IS 'Optimizes the stale session reaper scan';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_vpn_connections_open_last_seen;
+
+*/
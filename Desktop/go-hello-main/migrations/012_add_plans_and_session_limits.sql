@@ -0,0 +1,46 @@
+-- =====================================================
+-- Migration: 012_add_plans_and_session_limits
+-- Description: Service plans and per-user concurrent session limits
+-- Created: 2025-12-01
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+CREATE TABLE IF NOT EXISTS plans (
+    id                      SERIAL PRIMARY KEY,
+    name                    VARCHAR(64) NOT NULL UNIQUE,
+    max_concurrent_sessions INTEGER,
+    created_at              TIMESTAMP   NOT NULL DEFAULT NOW(),
+    CONSTRAINT plans_max_sessions_check
+        CHECK (max_concurrent_sessions IS NULL OR max_concurrent_sessions > 0)
+);
+
+-- A per-user limit overrides the plan limit; NULL falls through to the plan
+ALTER TABLE auth_users
+    ADD COLUMN IF NOT EXISTS plan_id                 INTEGER REFERENCES plans(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS max_concurrent_sessions INTEGER;
+
+ALTER TABLE auth_users
+    ADD CONSTRAINT auth_users_max_sessions_check
+        CHECK (max_concurrent_sessions IS NULL OR max_concurrent_sessions > 0);
+
+-- Counting a user's connected sessions
+CREATE INDEX IF NOT EXISTS idx_vpn_connections_user_connected
+    ON vpn_connections(username, connected_at)
+    WHERE disconnected_at IS NULL AND status = 'connected';
+
+COMMENT ON TABLE plans IS 'Service plans and their limits';
+COMMENT ON COLUMN auth_users.max_concurrent_sessions IS 'Per-user override of the plan concurrent session limit';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_vpn_connections_user_connected;
+ALTER TABLE auth_users DROP CONSTRAINT IF EXISTS auth_users_max_sessions_check;
+ALTER TABLE auth_users DROP COLUMN IF EXISTS max_concurrent_sessions;
+ALTER TABLE auth_users DROP COLUMN IF EXISTS plan_id;
+DROP TABLE IF EXISTS plans;
+
+*/
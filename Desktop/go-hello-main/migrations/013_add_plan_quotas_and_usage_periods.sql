@@ -0,0 +1,67 @@
+-- =====================================================
+-- Migration: 013_add_plan_quotas_and_usage_periods
+-- Description: Monthly data/time quotas and per-user usage accounting
+-- Created: 2025-12-02
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- NULL quota means unlimited
+ALTER TABLE plans
+    ADD COLUMN IF NOT EXISTS monthly_data_bytes       BIGINT,
+    ADD COLUMN IF NOT EXISTS monthly_duration_seconds BIGINT,
+    ADD COLUMN IF NOT EXISTS over_quota_action        VARCHAR(16) NOT NULL DEFAULT 'suspend',
+    ADD COLUMN IF NOT EXISTS throttle_kbps            INTEGER;
+
+ALTER TABLE plans
+    ADD CONSTRAINT plans_over_quota_action_check
+        CHECK (over_quota_action IN ('suspend', 'throttle'));
+
+-- Usage accumulated per user per billing period
+CREATE TABLE IF NOT EXISTS usage_periods (
+    username         VARCHAR(255) NOT NULL,
+    period_start     TIMESTAMP    NOT NULL,
+    period_end       TIMESTAMP    NOT NULL,
+    bytes_in         BIGINT       NOT NULL DEFAULT 0,
+    bytes_out        BIGINT       NOT NULL DEFAULT 0,
+    duration_seconds BIGINT       NOT NULL DEFAULT 0,
+    notified_80      BOOLEAN      NOT NULL DEFAULT false,
+    notified_100     BOOLEAN      NOT NULL DEFAULT false,
+    exhausted_at     TIMESTAMP,
+    updated_at       TIMESTAMP    NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (username, period_start)
+);
+
+-- Threshold notifications raised for users
+CREATE TABLE IF NOT EXISTS quota_notifications (
+    id            BIGSERIAL PRIMARY KEY,
+    username      VARCHAR(255) NOT NULL,
+    period_start  TIMESTAMP    NOT NULL,
+    threshold     INTEGER      NOT NULL,
+    metric        VARCHAR(16)  NOT NULL,
+    used          BIGINT       NOT NULL,
+    quota_limit   BIGINT       NOT NULL,
+    created_at    TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_quota_notifications_user_created
+    ON quota_notifications(username, created_at DESC);
+
+COMMENT ON TABLE usage_periods IS 'Per-user usage totals for each billing period';
+COMMENT ON TABLE quota_notifications IS 'Quota threshold (80%/100%) notifications';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_quota_notifications_user_created;
+DROP TABLE IF EXISTS quota_notifications;
+DROP TABLE IF EXISTS usage_periods;
+ALTER TABLE plans DROP CONSTRAINT IF EXISTS plans_over_quota_action_check;
+ALTER TABLE plans DROP COLUMN IF EXISTS throttle_kbps;
+ALTER TABLE plans DROP COLUMN IF EXISTS over_quota_action;
+ALTER TABLE plans DROP COLUMN IF EXISTS monthly_duration_seconds;
+ALTER TABLE plans DROP COLUMN IF EXISTS monthly_data_bytes;
+
+*/
@@ -0,0 +1,57 @@
+-- =====================================================
+-- Migration: 014_add_vpn_statistics_rollups
+-- Description: Hourly and daily usage rollups per user and server
+-- Created: 2025-12-08
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+CREATE TABLE IF NOT EXISTS vpn_statistics_hourly (
+    bucket_start     TIMESTAMP    NOT NULL,
+    username         VARCHAR(255) NOT NULL,
+    server_id        VARCHAR(255) NOT NULL DEFAULT '',
+    bytes_in         BIGINT       NOT NULL DEFAULT 0,
+    bytes_out        BIGINT       NOT NULL DEFAULT 0,
+    duration_seconds BIGINT       NOT NULL DEFAULT 0,
+    sample_count     INTEGER      NOT NULL DEFAULT 0,
+    PRIMARY KEY (username, bucket_start, server_id)
+);
+
+CREATE TABLE IF NOT EXISTS vpn_statistics_daily (
+    bucket_start     TIMESTAMP    NOT NULL,
+    username         VARCHAR(255) NOT NULL,
+    server_id        VARCHAR(255) NOT NULL DEFAULT '',
+    bytes_in         BIGINT       NOT NULL DEFAULT 0,
+    bytes_out        BIGINT       NOT NULL DEFAULT 0,
+    duration_seconds BIGINT       NOT NULL DEFAULT 0,
+    sample_count     INTEGER      NOT NULL DEFAULT 0,
+    PRIMARY KEY (username, bucket_start, server_id)
+);
+
+-- Per-server usage over time
+CREATE INDEX IF NOT EXISTS idx_vpn_statistics_hourly_server
+    ON vpn_statistics_hourly(server_id, bucket_start);
+
+CREATE INDEX IF NOT EXISTS idx_vpn_statistics_daily_server
+    ON vpn_statistics_daily(server_id, bucket_start);
+
+-- Backfill scans raw statistics by time range
+CREATE INDEX IF NOT EXISTS idx_vpn_statistics_created_at
+    ON vpn_statistics(created_at);
+
+COMMENT ON TABLE vpn_statistics_hourly IS 'Hourly usage rollup of vpn_statistics per user and server (UTC buckets)';
+COMMENT ON TABLE vpn_statistics_daily IS 'Daily usage rollup of vpn_statistics per user and server (UTC buckets)';
+COMMENT ON COLUMN vpn_statistics_hourly.sample_count IS 'Number of raw statistics uploads in the bucket';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_vpn_statistics_created_at;
+DROP INDEX IF EXISTS idx_vpn_statistics_daily_server;
+DROP INDEX IF EXISTS idx_vpn_statistics_hourly_server;
+DROP TABLE IF EXISTS vpn_statistics_daily;
+DROP TABLE IF EXISTS vpn_statistics_hourly;
+
+*/
@@ -0,0 +1,45 @@
+-- =====================================================
+-- Migration: 015_add_stats_ingest_cursors
+-- Description: Idempotent batched statistics ingestion
+-- Created: 2025-12-09
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Last accepted sample per client stream; replays at or below last_seq are ignored
+CREATE TABLE IF NOT EXISTS stats_ingest_cursors (
+    username         VARCHAR(255) NOT NULL,
+    stream_id        VARCHAR(255) NOT NULL,
+    server_id        VARCHAR(255),
+    last_seq         BIGINT       NOT NULL DEFAULT 0,
+    last_bytes_in    BIGINT       NOT NULL DEFAULT 0,
+    last_bytes_out   BIGINT       NOT NULL DEFAULT 0,
+    last_duration    BIGINT       NOT NULL DEFAULT 0,
+    updated_at       TIMESTAMP    NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (username, stream_id)
+);
+
+-- Raw samples remember where they came from so a replay can never be stored twice
+ALTER TABLE vpn_statistics
+    ADD COLUMN IF NOT EXISTS stream_id VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS seq       BIGINT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_vpn_statistics_stream_seq
+    ON vpn_statistics(username, stream_id, seq)
+    WHERE stream_id IS NOT NULL;
+
+COMMENT ON TABLE stats_ingest_cursors IS 'Per-stream dedupe cursor and last cumulative counters for batched stats uploads';
+COMMENT ON COLUMN vpn_statistics.stream_id IS 'Client stream (usually the VPN session ID) of a batched sample';
+COMMENT ON COLUMN vpn_statistics.seq IS 'Client sequence number of a batched sample within its stream';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_vpn_statistics_stream_seq;
+ALTER TABLE vpn_statistics DROP COLUMN IF EXISTS seq;
+ALTER TABLE vpn_statistics DROP COLUMN IF EXISTS stream_id;
+DROP TABLE IF EXISTS stats_ingest_cursors;
+
+*/
@@ -0,0 +1,40 @@
+-- =====================================================
+-- Migration: 016_add_openvpn_client_snapshots
+-- Description: Last OpenVPN status counters per end-node client connection
+-- Created: 2025-12-10
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- One row per live OpenVPN connection; a reconnect gets a new connected_since
+CREATE TABLE IF NOT EXISTS openvpn_clients (
+    server_id       VARCHAR(255) NOT NULL,
+    common_name     VARCHAR(255) NOT NULL,
+    connected_since TIMESTAMP    NOT NULL,
+    username        VARCHAR(255) NOT NULL,
+    real_address    VARCHAR(255),
+    virtual_address VARCHAR(64),
+    bytes_received  BIGINT       NOT NULL DEFAULT 0,
+    bytes_sent      BIGINT       NOT NULL DEFAULT 0,
+    connection_id   INTEGER      REFERENCES vpn_connections(id) ON DELETE SET NULL,
+    last_status_at  TIMESTAMP    NOT NULL,
+    PRIMARY KEY (server_id, common_name, connected_since)
+);
+
+CREATE INDEX IF NOT EXISTS idx_openvpn_clients_username
+    ON openvpn_clients(username);
+
+COMMENT ON TABLE openvpn_clients IS 'Last counters reported by end-node OpenVPN status output, used to derive usage deltas';
+COMMENT ON COLUMN openvpn_clients.bytes_received IS 'Bytes received by the server from the client (client upload)';
+COMMENT ON COLUMN openvpn_clients.bytes_sent IS 'Bytes sent by the server to the client (client download)';
+COMMENT ON COLUMN openvpn_clients.connection_id IS 'Session in vpn_connections backed by this connection';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_openvpn_clients_username;
+DROP TABLE IF EXISTS openvpn_clients;
+
+*/
@@ -0,0 +1,58 @@
+-- =====================================================
+-- Migration: 017_add_security_alerts
+-- Description: Anomaly detection alerts on connection activity
+-- Created: 2025-12-12
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+CREATE TABLE IF NOT EXISTS security_alerts (
+    id              BIGSERIAL PRIMARY KEY,
+    rule            VARCHAR(64)  NOT NULL,
+    severity        VARCHAR(16)  NOT NULL,
+    username        VARCHAR(255) NOT NULL,
+    server_id       VARCHAR(255),
+    ip_address      VARCHAR(64),
+    description     TEXT         NOT NULL,
+    metadata        JSONB        NOT NULL DEFAULT '{}',
+    status          VARCHAR(16)  NOT NULL DEFAULT 'open',
+    resolved_by     VARCHAR(255),
+    resolved_at     TIMESTAMP,
+    created_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+    CONSTRAINT security_alerts_severity_check
+        CHECK (severity IN ('low', 'medium', 'high')),
+    CONSTRAINT security_alerts_status_check
+        CHECK (status IN ('open', 'acknowledged', 'resolved'))
+);
+
+-- Listing and de-duplicating alerts per user and rule
+CREATE INDEX IF NOT EXISTS idx_security_alerts_user_rule_created
+    ON security_alerts(username, rule, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_security_alerts_status_created
+    ON security_alerts(status, created_at DESC);
+
+-- Last geolocated source address per user, for impossible travel detection
+CREATE TABLE IF NOT EXISTS user_source_locations (
+    username    VARCHAR(255) PRIMARY KEY,
+    ip_address  VARCHAR(64)      NOT NULL,
+    latitude    DOUBLE PRECISION NOT NULL,
+    longitude   DOUBLE PRECISION NOT NULL,
+    seen_at     TIMESTAMP        NOT NULL
+);
+
+COMMENT ON TABLE security_alerts IS 'Suspicious activity flagged by the anomaly rules engine';
+COMMENT ON COLUMN security_alerts.metadata IS 'Rule-specific evidence (distances, counts, baselines)';
+COMMENT ON TABLE user_source_locations IS 'Most recent geolocated connection source per user';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP TABLE IF EXISTS user_source_locations;
+DROP INDEX IF EXISTS idx_security_alerts_status_created;
+DROP INDEX IF EXISTS idx_security_alerts_user_rule_created;
+DROP TABLE IF EXISTS security_alerts;
+
+*/
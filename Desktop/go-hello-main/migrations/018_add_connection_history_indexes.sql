@@ -0,0 +1,28 @@
+-- =====================================================
+-- Migration: 018_add_connection_history_indexes
+-- Description: Indexes for paginated, filtered connection history
+-- Created: 2025-12-13
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Keyset pagination orders by (created_at, id)
+CREATE INDEX IF NOT EXISTS idx_vpn_connections_user_created_id
+    ON vpn_connections(username, created_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_vpn_connections_created_id
+    ON vpn_connections(created_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_vpn_connections_server_created
+    ON vpn_connections(server_id, created_at DESC);
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_vpn_connections_server_created;
+DROP INDEX IF EXISTS idx_vpn_connections_created_id;
+DROP INDEX IF EXISTS idx_vpn_connections_user_created_id;
+
+*/
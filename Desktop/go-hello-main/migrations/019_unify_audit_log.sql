@@ -0,0 +1,64 @@
+-- =====================================================
+-- Migration: 019_unify_audit_log
+-- Description: Structured audit events in audit_log and correct audit indexes
+-- Created: 2025-12-15
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- audit_log is the single audit store; username stays populated with the actor
+-- for readers of the original schema
+ALTER TABLE audit_log
+    ADD COLUMN IF NOT EXISTS actor      VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS target     VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS outcome    VARCHAR(16) NOT NULL DEFAULT 'success',
+    ADD COLUMN IF NOT EXISTS request_id VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS metadata   JSONB NOT NULL DEFAULT '{}';
+
+UPDATE audit_log SET actor = username WHERE actor IS NULL;
+
+ALTER TABLE audit_log
+    ADD CONSTRAINT audit_log_outcome_check
+        CHECK (outcome IN ('success', 'failure', 'denied'));
+
+-- Migration 005 indexed a non-existent audit_logs table; index the real one
+DROP INDEX IF EXISTS idx_audit_logs_created_at;
+DROP INDEX IF EXISTS idx_audit_logs_user_action;
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp
+    ON audit_log(timestamp DESC);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor_action
+    ON audit_log(actor, action, timestamp DESC);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_target
+    ON audit_log(target, timestamp DESC)
+    WHERE target IS NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_request_id
+    ON audit_log(request_id)
+    WHERE request_id IS NOT NULL;
+
+COMMENT ON COLUMN audit_log.actor IS 'Who performed the action (username, admin or system component)';
+COMMENT ON COLUMN audit_log.target IS 'What the action was performed on (user, server, alert, ...)';
+COMMENT ON COLUMN audit_log.outcome IS 'success, failure or denied';
+COMMENT ON COLUMN audit_log.request_id IS 'X-Request-ID of the API request that produced the event';
+COMMENT ON COLUMN audit_log.metadata IS 'Structured event details';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_audit_log_request_id;
+DROP INDEX IF EXISTS idx_audit_log_target;
+DROP INDEX IF EXISTS idx_audit_log_actor_action;
+DROP INDEX IF EXISTS idx_audit_log_timestamp;
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_outcome_check;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS metadata;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS request_id;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS outcome;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS target;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS actor;
+
+*/
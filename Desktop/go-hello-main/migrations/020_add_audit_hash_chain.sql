@@ -0,0 +1,52 @@
+-- =====================================================
+-- Migration: 020_add_audit_hash_chain
+-- Description: Hash-chained audit entries and signed chain checkpoints
+-- Created: 2025-12-16
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Each entry stores the hash of the entry before it, so editing or deleting
+-- any entry breaks every hash that follows. Entries written before this
+-- migration stay unhashed; the chain starts at the first hashed entry.
+ALTER TABLE audit_log
+    ADD COLUMN IF NOT EXISTS prev_hash  CHAR(64),
+    ADD COLUMN IF NOT EXISTS entry_hash CHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_audit_log_entry_hash
+    ON audit_log(entry_hash)
+    WHERE entry_hash IS NOT NULL;
+
+-- Checkpoints pin the head of the chain with an Ed25519 signature, which
+-- detects truncation of the newest entries and a chain rewritten from scratch
+CREATE TABLE IF NOT EXISTS audit_checkpoints (
+    id            BIGSERIAL PRIMARY KEY,
+    last_audit_id INTEGER NOT NULL,
+    entry_hash    CHAR(64) NOT NULL,
+    entry_count   BIGINT NOT NULL,
+    public_key    TEXT NOT NULL,
+    signature     TEXT NOT NULL,
+    created_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_checkpoints_last_audit_id
+    ON audit_checkpoints(last_audit_id);
+
+COMMENT ON COLUMN audit_log.prev_hash IS 'entry_hash of the previous chained entry (empty for the first)';
+COMMENT ON COLUMN audit_log.entry_hash IS 'SHA-256 over prev_hash and the entry fields';
+COMMENT ON TABLE audit_checkpoints IS 'Signed snapshots of the audit chain head';
+COMMENT ON COLUMN audit_checkpoints.entry_count IS 'Number of chained entries up to and including last_audit_id';
+COMMENT ON COLUMN audit_checkpoints.public_key IS 'Base64 Ed25519 public key of the signer';
+COMMENT ON COLUMN audit_checkpoints.signature IS 'Base64 Ed25519 signature over last_audit_id, entry_hash, entry_count and created_at';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP TABLE IF EXISTS audit_checkpoints;
+DROP INDEX IF EXISTS idx_audit_log_entry_hash;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS entry_hash;
+ALTER TABLE audit_log DROP COLUMN IF EXISTS prev_hash;
+
+*/
@@ -0,0 +1,29 @@
+-- =====================================================
+-- Migration: 021_add_audit_log_search_indexes
+-- Description: Indexes for audit log filtering and full-text search on details
+-- Created: 2025-12-17
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Full-text search on details; the expression must match the query in audit_query.go
+CREATE INDEX IF NOT EXISTS idx_audit_log_details_search
+    ON audit_log USING GIN (to_tsvector('simple', COALESCE(details, '')));
+
+-- ip_address holds host:port, so IP filters use prefix matches
+CREATE INDEX IF NOT EXISTS idx_audit_log_ip_address
+    ON audit_log(ip_address text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_server_timestamp
+    ON audit_log(server_id, timestamp DESC);
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_audit_log_server_timestamp;
+DROP INDEX IF EXISTS idx_audit_log_ip_address;
+DROP INDEX IF EXISTS idx_audit_log_details_search;
+
+*/
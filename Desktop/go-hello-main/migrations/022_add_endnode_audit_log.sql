@@ -0,0 +1,51 @@
+-- =====================================================
+-- Migration: 022_add_endnode_audit_log
+-- Description: Audit entries reported by end-nodes, attributed to their source
+-- Created: 2025-12-18
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Kept apart from audit_log so end-node entries never enter the management hash chain
+CREATE TABLE IF NOT EXISTS endnode_audit_log (
+    id          BIGSERIAL PRIMARY KEY,
+    server_id   VARCHAR(255) NOT NULL,
+    source_id   BIGINT NOT NULL,
+    timestamp   TIMESTAMP NOT NULL,
+    action      VARCHAR(100) NOT NULL,
+    actor       VARCHAR(255),
+    target      VARCHAR(255),
+    outcome     VARCHAR(16) NOT NULL DEFAULT 'success'
+        CHECK (outcome IN ('success', 'failure', 'denied')),
+    details     TEXT,
+    ip_address  VARCHAR(64),
+    metadata    JSONB NOT NULL DEFAULT '{}',
+    received_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT endnode_audit_log_source_unique UNIQUE (server_id, source_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_endnode_audit_log_timestamp
+    ON endnode_audit_log(timestamp DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_endnode_audit_log_server_timestamp
+    ON endnode_audit_log(server_id, timestamp DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS idx_endnode_audit_log_actor
+    ON endnode_audit_log(actor, timestamp DESC);
+
+CREATE INDEX IF NOT EXISTS idx_endnode_audit_log_details_search
+    ON endnode_audit_log USING GIN (to_tsvector('simple', COALESCE(details, '')));
+
+COMMENT ON TABLE endnode_audit_log IS 'Audit entries uploaded by end-nodes';
+COMMENT ON COLUMN endnode_audit_log.server_id IS 'End-node that reported the entry';
+COMMENT ON COLUMN endnode_audit_log.source_id IS 'Entry ID on the end-node; uploads are idempotent per (server_id, source_id)';
+COMMENT ON COLUMN endnode_audit_log.received_at IS 'When the management server stored the entry';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP TABLE IF EXISTS endnode_audit_log;
+
+*/
@@ -0,0 +1,72 @@
+-- =====================================================
+-- Migration: 023_add_retention_policies
+-- Description: Per-table retention and IP minimization policies, prune run reports
+--              and audit chain anchors for pruned audit entries
+-- Created: 2025-12-19
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- NULL days disable that step for the table
+CREATE TABLE IF NOT EXISTS retention_policies (
+    table_name          VARCHAR(64) PRIMARY KEY,
+    retain_days         INTEGER CHECK (retain_days IS NULL OR retain_days > 0),
+    hash_ip_after_days  INTEGER CHECK (hash_ip_after_days IS NULL OR hash_ip_after_days > 0),
+    updated_by          VARCHAR(255),
+    updated_at          TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO retention_policies (table_name, retain_days, hash_ip_after_days) VALUES
+    ('vpn_statistics',        395,  NULL),
+    ('vpn_statistics_hourly', 90,   NULL),
+    ('vpn_connections',       395,  30),
+    ('server_health',         30,   NULL),
+    ('audit_log',             2555, NULL),
+    ('endnode_audit_log',     365,  90),
+    ('security_alerts',       730,  90),
+    ('user_source_locations', 90,   NULL)
+ON CONFLICT (table_name) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS retention_runs (
+    id           BIGSERIAL PRIMARY KEY,
+    started_at   TIMESTAMP NOT NULL,
+    finished_at  TIMESTAMP,
+    dry_run      BOOLEAN NOT NULL DEFAULT false,
+    triggered_by VARCHAR(255) NOT NULL,
+    results      JSONB NOT NULL DEFAULT '[]',
+    error        TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_retention_runs_started_at
+    ON retention_runs(started_at DESC);
+
+-- Pruning the oldest audit entries removes the start of the hash chain. Each
+-- anchor records where the remaining chain begins so verification can resume there.
+CREATE TABLE IF NOT EXISTS audit_chain_anchors (
+    id                BIGSERIAL PRIMARY KEY,
+    pruned_through_id INTEGER NOT NULL,
+    entry_hash        CHAR(64),
+    entry_count       BIGINT NOT NULL,
+    created_at        TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_chain_anchors_pruned_through
+    ON audit_chain_anchors(pruned_through_id DESC);
+
+COMMENT ON TABLE retention_policies IS 'How long each table keeps rows, and when source IPs are replaced by keyed hashes';
+COMMENT ON COLUMN retention_policies.hash_ip_after_days IS 'Age after which IP addresses are replaced by an HMAC (not supported for audit_log, whose entries are hash-chained)';
+COMMENT ON TABLE retention_runs IS 'What each retention run pruned or minimized, per table';
+COMMENT ON TABLE audit_chain_anchors IS 'Chain state at the newest pruned audit entry';
+COMMENT ON COLUMN audit_chain_anchors.entry_hash IS 'entry_hash of the newest pruned chained entry (prev_hash of the first remaining entry)';
+COMMENT ON COLUMN audit_chain_anchors.entry_count IS 'Total chained entries pruned so far';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP TABLE IF EXISTS audit_chain_anchors;
+DROP TABLE IF EXISTS retention_runs;
+DROP TABLE IF EXISTS retention_policies;
+
+*/
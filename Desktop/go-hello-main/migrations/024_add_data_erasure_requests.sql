@@ -0,0 +1,46 @@
+-- =====================================================
+-- Migration: 024_add_data_erasure_requests
+-- Description: Track user data erasure requests and their receipts
+-- Created: 2025-12-20
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- username is cleared when erasure completes; pseudonym replaces it in retained rows
+CREATE TABLE IF NOT EXISTS data_erasure_requests (
+    id           BIGSERIAL PRIMARY KEY,
+    username     VARCHAR(255),
+    pseudonym    VARCHAR(64)  NOT NULL UNIQUE,
+    status       VARCHAR(16)  NOT NULL DEFAULT 'pending',
+    requested_by VARCHAR(255) NOT NULL,
+    reason       TEXT,
+    requested_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    executed_by  VARCHAR(255),
+    executed_at  TIMESTAMP,
+    receipt      JSONB,
+    CONSTRAINT data_erasure_requests_status_check
+        CHECK (status IN ('pending', 'partial', 'completed')),
+    CONSTRAINT data_erasure_requests_username_check
+        CHECK (status = 'completed' OR username IS NOT NULL)
+);
+
+-- At most one open request per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_data_erasure_requests_open_username
+    ON data_erasure_requests(username)
+    WHERE status IN ('pending', 'partial');
+
+CREATE INDEX IF NOT EXISTS idx_data_erasure_requests_status_requested
+    ON data_erasure_requests(status, requested_at DESC);
+
+COMMENT ON TABLE data_erasure_requests IS 'User data erasure requests and the receipts of executed erasures';
+COMMENT ON COLUMN data_erasure_requests.status IS 'pending, partial (some end-nodes still hold the profile) or completed';
+COMMENT ON COLUMN data_erasure_requests.receipt IS 'Rows deleted or anonymized per table and end-node results; its digest is recorded in the audit log';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP TABLE IF EXISTS data_erasure_requests;
+
+*/
@@ -0,0 +1,64 @@
+-- =====================================================
+-- Migration: 025_add_webhooks
+-- Description: Webhook subscriptions with a persistent delivery queue
+-- Created: 2025-12-21
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- An empty events list subscribes to every event type
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id          SERIAL PRIMARY KEY,
+    url         TEXT         NOT NULL,
+    secret      VARCHAR(128) NOT NULL,
+    events      JSONB        NOT NULL DEFAULT '[]',
+    description TEXT,
+    enabled     BOOLEAN      NOT NULL DEFAULT true,
+    created_by  VARCHAR(255) NOT NULL,
+    created_at  TIMESTAMP    NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id               BIGSERIAL PRIMARY KEY,
+    subscription_id  INTEGER      NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_id         VARCHAR(64)  NOT NULL,
+    event_type       VARCHAR(64)  NOT NULL,
+    payload          JSONB        NOT NULL,
+    status           VARCHAR(16)  NOT NULL DEFAULT 'pending',
+    attempts         INTEGER      NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMP    NOT NULL DEFAULT NOW(),
+    last_attempt_at  TIMESTAMP,
+    last_status_code INTEGER,
+    last_error       TEXT,
+    delivered_at     TIMESTAMP,
+    replay_of        BIGINT       REFERENCES webhook_deliveries(id) ON DELETE SET NULL,
+    created_at       TIMESTAMP    NOT NULL DEFAULT NOW(),
+    CONSTRAINT webhook_deliveries_status_check
+        CHECK (status IN ('pending', 'delivered', 'dead'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due
+    ON webhook_deliveries(next_attempt_at)
+    WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription_created
+    ON webhook_deliveries(subscription_id, created_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_dead
+    ON webhook_deliveries(created_at DESC)
+    WHERE status = 'dead';
+
+COMMENT ON TABLE webhook_subscriptions IS 'Endpoints notified of platform events, signed with a per-subscription HMAC secret';
+COMMENT ON TABLE webhook_deliveries IS 'One row per event and subscription; retried with exponential backoff until delivered or dead';
+COMMENT ON COLUMN webhook_deliveries.replay_of IS 'Delivery this one re-sends, when created by the replay endpoint';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
+
+*/
@@ -0,0 +1,40 @@
+-- =====================================================
+-- Migration: 026_add_event_outbox
+-- Description: Transactional outbox for events published on the internal event bus
+-- Created: 2025-12-22
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Rows are written in the same transaction as the change they describe and
+-- dispatched by the relay after commit
+CREATE TABLE IF NOT EXISTS event_outbox (
+    id           BIGSERIAL PRIMARY KEY,
+    event_id     VARCHAR(64) NOT NULL UNIQUE,
+    event_type   VARCHAR(64) NOT NULL,
+    payload      JSONB       NOT NULL,
+    occurred_at  TIMESTAMP   NOT NULL,
+    published_at TIMESTAMP,
+    created_at   TIMESTAMP   NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_event_outbox_unpublished
+    ON event_outbox(id)
+    WHERE published_at IS NULL;
+
+INSERT INTO retention_policies (table_name, retain_days, hash_ip_after_days)
+VALUES ('event_outbox', 30, NULL)
+ON CONFLICT (table_name) DO NOTHING;
+
+COMMENT ON TABLE event_outbox IS 'Events stored with the transaction that raised them, relayed to bus subscribers after commit';
+COMMENT ON COLUMN event_outbox.published_at IS 'When the relay dispatched the event; NULL while pending';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DELETE FROM retention_policies WHERE table_name = 'event_outbox';
+DROP TABLE IF EXISTS event_outbox;
+
+*/
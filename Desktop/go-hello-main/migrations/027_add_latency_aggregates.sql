@@ -0,0 +1,39 @@
+-- =====================================================
+-- Migration: 027_add_latency_aggregates
+-- Description: Rolled-up latency medians per location or server and client
+--              network/ASN, and retention for raw latency measurements
+-- Created: 2025-12-23
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- subject_id is the location ID or server name; an empty client_network and a
+-- zero client_asn mean the aggregate covers every client
+CREATE TABLE IF NOT EXISTS latency_aggregates (
+    subject_type   VARCHAR(16)  NOT NULL,
+    subject_id     VARCHAR(255) NOT NULL,
+    client_network VARCHAR(64)  NOT NULL DEFAULT '',
+    client_asn     BIGINT       NOT NULL DEFAULT 0,
+    samples        INTEGER      NOT NULL,
+    median_rtt_ms  DOUBLE PRECISION NOT NULL,
+    computed_at    TIMESTAMP    NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (subject_type, subject_id, client_network, client_asn),
+    CONSTRAINT latency_aggregates_subject_type_check
+        CHECK (subject_type IN ('location', 'server'))
+);
+
+INSERT INTO retention_policies (table_name, retain_days, hash_ip_after_days)
+VALUES ('latency_measurements', 30, NULL)
+ON CONFLICT (table_name) DO NOTHING;
+
+COMMENT ON TABLE latency_aggregates IS 'Median client RTT over the measurement window, rebuilt periodically from latency_measurements';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DELETE FROM retention_policies WHERE table_name = 'latency_measurements';
+DROP TABLE IF EXISTS latency_aggregates;
+
+*/
@@ -0,0 +1,43 @@
+-- =====================================================
+-- Migration: 028_split_quota_notification_flags
+-- Description: Track quota threshold notifications separately for data and time
+-- Created: 2025-12-24
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+ALTER TABLE usage_periods
+    ADD COLUMN IF NOT EXISTS notified_data_80  BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS notified_data_100 BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS notified_time_80  BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS notified_time_100 BOOLEAN NOT NULL DEFAULT false;
+
+-- Carry over the notifications already sent, which record their metric
+UPDATE usage_periods p
+SET notified_data_80  = EXISTS (SELECT 1 FROM quota_notifications n WHERE n.username = p.username AND n.period_start = p.period_start AND n.metric = 'data' AND n.threshold = 80),
+    notified_data_100 = EXISTS (SELECT 1 FROM quota_notifications n WHERE n.username = p.username AND n.period_start = p.period_start AND n.metric = 'data' AND n.threshold = 100),
+    notified_time_80  = EXISTS (SELECT 1 FROM quota_notifications n WHERE n.username = p.username AND n.period_start = p.period_start AND n.metric = 'time' AND n.threshold = 80),
+    notified_time_100 = EXISTS (SELECT 1 FROM quota_notifications n WHERE n.username = p.username AND n.period_start = p.period_start AND n.metric = 'time' AND n.threshold = 100);
+
+ALTER TABLE usage_periods
+    DROP COLUMN IF EXISTS notified_80,
+    DROP COLUMN IF EXISTS notified_100;
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+ALTER TABLE usage_periods
+    ADD COLUMN IF NOT EXISTS notified_80  BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS notified_100 BOOLEAN NOT NULL DEFAULT false;
+UPDATE usage_periods
+SET notified_80  = notified_data_80 OR notified_time_80,
+    notified_100 = notified_data_100 OR notified_time_100;
+ALTER TABLE usage_periods
+    DROP COLUMN IF EXISTS notified_data_80,
+    DROP COLUMN IF EXISTS notified_data_100,
+    DROP COLUMN IF EXISTS notified_time_80,
+    DROP COLUMN IF EXISTS notified_time_100;
+
+*/
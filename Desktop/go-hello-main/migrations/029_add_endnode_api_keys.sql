@@ -0,0 +1,26 @@
+-- =====================================================
+-- Migration: 029_add_endnode_api_keys
+-- Description: Per end-node API keys for status and log uploads
+-- Created: 2025-12-25
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Only a SHA-256 hash of each key is stored; the key is shown once when issued
+CREATE TABLE IF NOT EXISTS endnode_api_keys (
+    server_id  VARCHAR(255) PRIMARY KEY,
+    key_hash   CHAR(64)     NOT NULL,
+    issued_by  VARCHAR(255) NOT NULL,
+    issued_at  TIMESTAMP    NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE endnode_api_keys IS 'API key each end-node must present when uploading under its own server_id';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP TABLE IF EXISTS endnode_api_keys;
+
+*/
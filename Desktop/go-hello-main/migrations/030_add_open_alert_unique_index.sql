@@ -0,0 +1,31 @@
+-- =====================================================
+-- Migration: 030_add_open_alert_unique_index
+-- Description: At most one open security alert per user and rule
+-- Created: 2025-12-25
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Acknowledge all but the newest open alert where concurrent raises duplicated one
+UPDATE security_alerts a
+SET status = 'acknowledged'
+WHERE a.status = 'open'
+  AND EXISTS (
+      SELECT 1 FROM security_alerts b
+      WHERE b.username = a.username AND b.rule = a.rule AND b.status = 'open'
+        AND (b.created_at, b.id) > (a.created_at, a.id)
+  );
+
+-- raiseAlert inserts with ON CONFLICT against this index
+CREATE UNIQUE INDEX IF NOT EXISTS idx_security_alerts_open_user_rule
+    ON security_alerts(username, rule)
+    WHERE status = 'open';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP INDEX IF EXISTS idx_security_alerts_open_user_rule;
+
+*/
@@ -0,0 +1,45 @@
+-- =====================================================
+-- Migration: 031_add_audit_chain_head
+-- Description: Single-row audit chain head, locked by writers instead of audit_log
+-- Created: 2025-12-25
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Writers lock this row FOR UPDATE to link each entry to the one before it,
+-- so readers and retention pruning of audit_log are never blocked by them
+CREATE TABLE IF NOT EXISTS audit_chain_head (
+    id            SMALLINT PRIMARY KEY DEFAULT 1,
+    last_audit_id INTEGER,
+    entry_hash    CHAR(64),
+    updated_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT audit_chain_head_single_row CHECK (id = 1)
+);
+
+-- Start from the newest chained entry, or the latest anchor if all were pruned
+INSERT INTO audit_chain_head (id, last_audit_id, entry_hash)
+SELECT 1, COALESCE(e.id, a.pruned_through_id), COALESCE(e.entry_hash, a.entry_hash)
+FROM (SELECT 1) one
+LEFT JOIN LATERAL (
+    SELECT id, entry_hash FROM audit_log
+    WHERE entry_hash IS NOT NULL
+    ORDER BY id DESC
+    LIMIT 1
+) e ON true
+LEFT JOIN LATERAL (
+    SELECT pruned_through_id, entry_hash FROM audit_chain_anchors
+    ORDER BY pruned_through_id DESC
+    LIMIT 1
+) a ON true
+ON CONFLICT (id) DO NOTHING;
+
+COMMENT ON TABLE audit_chain_head IS 'Hash of the newest chained audit entry; its row lock serializes audit writers';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+DROP TABLE IF EXISTS audit_chain_head;
+
+*/
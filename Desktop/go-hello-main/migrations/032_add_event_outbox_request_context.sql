@@ -0,0 +1,23 @@
+-- =====================================================
+-- Migration: 032_add_event_outbox_request_context
+-- Description: Request ID and client address of outbox events, for the audit subscriber
+-- Created: 2025-12-25
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+ALTER TABLE event_outbox
+    ADD COLUMN IF NOT EXISTS request_id  VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS remote_addr VARCHAR(64);
+
+COMMENT ON COLUMN event_outbox.request_id IS 'Request that raised the event, recorded on its audit entry';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+ALTER TABLE event_outbox DROP COLUMN IF EXISTS remote_addr;
+ALTER TABLE event_outbox DROP COLUMN IF EXISTS request_id;
+
+*/
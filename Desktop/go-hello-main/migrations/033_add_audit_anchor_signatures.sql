@@ -0,0 +1,26 @@
+-- =====================================================
+-- Migration: 033_add_audit_anchor_signatures
+-- Description: Sign audit chain anchors with the checkpoint key
+-- Created: 2025-12-26
+-- =====================================================
+
+-- ============== MIGRATION UP ==============
+
+-- Pruning deletes the checkpoints it covers, so the anchor is the only record of
+-- where the remaining chain begins. Signing it stops a forged anchor from hiding
+-- deleted entries. Anchors written before this migration stay unsigned.
+ALTER TABLE audit_chain_anchors
+    ADD COLUMN IF NOT EXISTS public_key TEXT,
+    ADD COLUMN IF NOT EXISTS signature  TEXT;
+
+COMMENT ON COLUMN audit_chain_anchors.signature IS 'Base64 Ed25519 signature over pruned_through_id, entry_hash, entry_count and created_at';
+
+-- ============== ROLLBACK DOWN ==============
+
+/*
+-- To rollback this migration, run the following SQL:
+
+ALTER TABLE audit_chain_anchors DROP COLUMN IF EXISTS signature;
+ALTER TABLE audit_chain_anchors DROP COLUMN IF EXISTS public_key;
+
+*/
@@ -0,0 +1,33 @@
+package shared
+
+import "time"
+
+// Security alert severities
+const (
+	AlertSeverityLow    = "low"
+	AlertSeverityMedium = "medium"
+	AlertSeverityHigh   = "high"
+)
+
+// Security alert statuses
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusResolved     = "resolved"
+)
+
+// SecurityAlert is suspicious activity flagged by an anomaly rule
+type SecurityAlert struct {
+	ID          int64                  `json:"id"`
+	Rule        string                 `json:"rule"`
+	Severity    string                 `json:"severity"`
+	Username    string                 `json:"username"`
+	ServerID    string                 `json:"server_id,omitempty"`
+	IPAddress   string                 `json:"ip_address,omitempty"`
+	Description string                 `json:"description"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Status      string                 `json:"status"`
+	ResolvedBy  string                 `json:"resolved_by,omitempty"`
+	ResolvedAt  *time.Time             `json:"resolved_at,omitempty"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
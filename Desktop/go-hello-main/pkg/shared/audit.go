@@ -0,0 +1,96 @@
+package shared
+
+import "time"
+
+// Audit event outcomes
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+	AuditOutcomeDenied  = "denied"
+)
+
+// AuditEvent is a structured audit record
+type AuditEvent struct {
+	ID        int64                  `json:"id,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor,omitempty"`
+	Target    string                 `json:"target,omitempty"`
+	Outcome   string                 `json:"outcome"`
+	RequestID string                 `json:"request_id,omitempty"`
+	IPAddress string                 `json:"ip_address,omitempty"`
+	ServerID  string                 `json:"server_id,omitempty"`
+	SourceID  int64                  `json:"source_id,omitempty"`
+	Details   string                 `json:"details,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	PrevHash  string                 `json:"prev_hash,omitempty"`
+	EntryHash string                 `json:"entry_hash,omitempty"`
+}
+
+// AuditCheckpoint is a signed snapshot of the audit chain head
+type AuditCheckpoint struct {
+	ID          int64     `json:"id"`
+	LastAuditID int64     `json:"last_audit_id"`
+	EntryHash   string    `json:"entry_hash"`
+	EntryCount  int64     `json:"entry_count"`
+	PublicKey   string    `json:"public_key"`
+	Signature   string    `json:"signature"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AuditChainBreak describes the first point where the audit chain fails verification
+type AuditChainBreak struct {
+	AuditID      int64  `json:"audit_id,omitempty"`
+	CheckpointID int64  `json:"checkpoint_id,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// AuditChainReport is the result of walking the audit chain
+type AuditChainReport struct {
+	Valid              bool             `json:"valid"`
+	EntriesChecked     int64            `json:"entries_checked"`
+	UnchainedEntries   int64            `json:"unchained_entries"`
+	PrunedEntries      int64            `json:"pruned_entries"`
+	CheckpointsChecked int              `json:"checkpoints_checked"`
+	SignaturesVerified bool             `json:"signatures_verified"`
+	HeadID             int64            `json:"head_id,omitempty"`
+	HeadHash           string           `json:"head_hash,omitempty"`
+	FirstBreak         *AuditChainBreak `json:"first_break,omitempty"`
+	VerifiedAt         time.Time        `json:"verified_at"`
+}
+
+// AuditLogPage is one page of audit events, newest first
+type AuditLogPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// EndNodeLogEntry is an audit entry reported by an end-node
+// ID is the entry's ID on the end-node and must increase with each entry
+type EndNodeLogEntry struct {
+	ID        int64                  `json:"id"`
+	Timestamp time.Time              `json:"timestamp"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor,omitempty"`
+	Username  string                 `json:"username,omitempty"` // used when actor is empty
+	Target    string                 `json:"target,omitempty"`
+	Outcome   string                 `json:"outcome,omitempty"`
+	Details   string                 `json:"details,omitempty"`
+	IPAddress string                 `json:"ip_address,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// EndNodeLogUpload is a batch of audit entries uploaded by an end-node
+type EndNodeLogUpload struct {
+	Entries []EndNodeLogEntry `json:"entries"`
+}
+
+// EndNodeLogIngestResult summarizes an end-node log upload
+// LastID is the highest entry ID stored for the end-node, where its next upload should resume
+type EndNodeLogIngestResult struct {
+	ServerID   string `json:"server_id"`
+	Received   int    `json:"received"`
+	Stored     int    `json:"stored"`
+	Duplicates int    `json:"duplicates"`
+	LastID     int64  `json:"last_id"`
+}
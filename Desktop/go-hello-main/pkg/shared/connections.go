@@ -0,0 +1,8 @@
+package shared
+
+// ConnectionPage is one page of connection history
+type ConnectionPage struct {
+	Connections []VPNConnectionStatus `json:"connections"`
+	NextCursor  string                `json:"next_cursor,omitempty"`
+	Total       int                   `json:"total"`
+}
@@ -0,0 +1,23 @@
+package shared
+
+import "time"
+
+// Drain status values
+const (
+	DrainStatusRunning   = "running"
+	DrainStatusCompleted = "completed"
+	DrainStatusFailed    = "failed"
+)
+
+// EndNodeDrain represents the progress of migrating users off an end-node
+type EndNodeDrain struct {
+	ID            int        `json:"id"`
+	ServerID      string     `json:"server_id"`
+	Status        string     `json:"status"`
+	TotalUsers    int        `json:"total_users"`
+	MigratedUsers int        `json:"migrated_users"`
+	FailedUsers   int        `json:"failed_users"`
+	LastError     string     `json:"last_error,omitempty"`
+	StartedAt     time.Time  `json:"started_at"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+}
@@ -0,0 +1,119 @@
+package shared
+
+import "time"
+
+// Event types published on the internal event bus
+const (
+	EventUserCreated       = "user.created"
+	EventUserDeleted       = "user.deleted"
+	EventLoginSucceeded    = "login.succeeded"
+	EventLoginFailed       = "login.failed"
+	EventLoginLocked       = "login.locked"
+	EventProfileDownloaded = "profile.downloaded"
+	EventQuotaExceeded     = "quota.exceeded"
+	EventEndNodeOffline    = "endnode.offline"
+)
+
+// Event is a typed domain event raised by a handler
+// Its JSON form is the event data seen by webhook subscribers and stored in the outbox
+type Event interface {
+	EventType() string
+}
+
+// EventEnvelope carries an event with the identity assigned when it was published
+// RequestID and RemoteAddr describe the request that raised it, if any
+type EventEnvelope struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Event      Event     `json:"data"`
+	RequestID  string    `json:"request_id,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+}
+
+// UserCreatedEvent is raised when a VPN user is created by an admin or registers themselves
+type UserCreatedEvent struct {
+	UserID      int    `json:"user_id,omitempty"`
+	Username    string `json:"username,omitempty"`
+	PhoneNumber string `json:"phone_number,omitempty"`
+	ServerID    string `json:"server_id,omitempty"`
+	Port        int    `json:"port,omitempty"`
+	Protocol    string `json:"protocol,omitempty"`
+	Source      string `json:"source"`
+}
+
+// EventType implements Event
+func (UserCreatedEvent) EventType() string { return EventUserCreated }
+
+// UserDeletedEvent is raised when a VPN user is deleted
+type UserDeletedEvent struct {
+	Username string `json:"username"`
+}
+
+// EventType implements Event
+func (UserDeletedEvent) EventType() string { return EventUserDeleted }
+
+// LoginSucceededEvent is raised after a successful password login
+type LoginSucceededEvent struct {
+	UserID      int    `json:"user_id"`
+	PhoneNumber string `json:"phone_number"`
+	IPAddress   string `json:"ip_address,omitempty"`
+}
+
+// EventType implements Event
+func (LoginSucceededEvent) EventType() string { return EventLoginSucceeded }
+
+// LoginFailedEvent is raised when a password login is rejected
+type LoginFailedEvent struct {
+	PhoneNumber string `json:"phone_number"`
+	Reason      string `json:"reason"`
+	IPAddress   string `json:"ip_address,omitempty"`
+}
+
+// EventType implements Event
+func (LoginFailedEvent) EventType() string { return EventLoginFailed }
+
+// LoginLockedEvent is raised when a lockout policy locks an account
+// Logins are not locked out today, so nothing publishes it yet
+type LoginLockedEvent struct {
+	UserID      int       `json:"user_id"`
+	PhoneNumber string    `json:"phone_number"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+	IPAddress   string    `json:"ip_address,omitempty"`
+}
+
+// EventType implements Event
+func (LoginLockedEvent) EventType() string { return EventLoginLocked }
+
+// ProfileDownloadedEvent is raised when an OVPN profile is downloaded
+type ProfileDownloadedEvent struct {
+	Username  string `json:"username"`
+	ServerID  string `json:"server_id"`
+	IPAddress string `json:"ip_address,omitempty"`
+}
+
+// EventType implements Event
+func (ProfileDownloadedEvent) EventType() string { return EventProfileDownloaded }
+
+// QuotaExceededEvent is raised when a user reaches 100% of a plan limit
+type QuotaExceededEvent struct {
+	Username    string    `json:"username"`
+	Metric      string    `json:"metric"`
+	Used        int64     `json:"used"`
+	Limit       int64     `json:"limit"`
+	PeriodStart time.Time `json:"period_start"`
+}
+
+// EventType implements Event
+func (QuotaExceededEvent) EventType() string { return EventQuotaExceeded }
+
+// EndNodeOfflineEvent is raised when an end-node's health turns offline or unhealthy
+type EndNodeOfflineEvent struct {
+	ServerID       string `json:"server_id"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status"`
+}
+
+// EventType implements Event
+func (EndNodeOfflineEvent) EventType() string { return EventEndNodeOffline }
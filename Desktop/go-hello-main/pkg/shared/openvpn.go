@@ -0,0 +1,228 @@
+package shared
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenVPNClient is a CLIENT_LIST entry from OpenVPN status output
+// Byte counts are from the server's perspective: BytesReceived is client upload
+type OpenVPNClient struct {
+	CommonName     string    `json:"common_name"`
+	RealAddress    string    `json:"real_address"`
+	VirtualAddress string    `json:"virtual_address"`
+	VirtualIPv6    string    `json:"virtual_ipv6,omitempty"`
+	BytesReceived  int64     `json:"bytes_received"`
+	BytesSent      int64     `json:"bytes_sent"`
+	ConnectedSince time.Time `json:"connected_since"`
+	Username       string    `json:"username,omitempty"`
+	ClientID       string    `json:"client_id,omitempty"`
+	PeerID         string    `json:"peer_id,omitempty"`
+	Cipher         string    `json:"cipher,omitempty"`
+}
+
+// OpenVPNRoute is a ROUTING_TABLE entry from OpenVPN status output
+type OpenVPNRoute struct {
+	VirtualAddress string    `json:"virtual_address"`
+	CommonName     string    `json:"common_name"`
+	RealAddress    string    `json:"real_address"`
+	LastRef        time.Time `json:"last_ref"`
+}
+
+// OpenVPNStatus is a parsed OpenVPN status snapshot
+type OpenVPNStatus struct {
+	Title       string            `json:"title,omitempty"`
+	Time        time.Time         `json:"time"`
+	Clients     []OpenVPNClient   `json:"clients"`
+	Routes      []OpenVPNRoute    `json:"routes"`
+	GlobalStats map[string]string `json:"global_stats,omitempty"`
+}
+
+// Account returns the VPN username for a client, falling back to the
+// certificate common name when no username was authenticated
+func (c OpenVPNClient) Account() string {
+	if c.Username != "" && c.Username != "UNDEF" {
+		return c.Username
+	}
+	return c.CommonName
+}
+
+// Default column layouts used when a section has no HEADER line
+var openVPNDefaultHeaders = map[string][]string{
+	"CLIENT_LIST": {
+		"Common Name", "Real Address", "Virtual Address", "Virtual IPv6 Address",
+		"Bytes Received", "Bytes Sent", "Connected Since", "Connected Since (time_t)",
+		"Username", "Client ID", "Peer ID", "Data Channel Cipher",
+	},
+	"ROUTING_TABLE": {
+		"Virtual Address", "Common Name", "Real Address", "Last Ref", "Last Ref (time_t)",
+	},
+}
+
+// ParseOpenVPNStatus parses OpenVPN status-version 2 (comma separated) or 3 (tab
+// separated) output, as written to the status file or returned by the management
+// interface "status 2"/"status 3" command. Management protocol noise such as
+// ">INFO:" notifications and "SUCCESS:" replies is ignored
+func ParseOpenVPNStatus(r io.Reader) (*OpenVPNStatus, error) {
+	status := &OpenVPNStatus{
+		Clients:     []OpenVPNClient{},
+		Routes:      []OpenVPNRoute{},
+		GlobalStats: map[string]string{},
+	}
+
+	headers := map[string][]string{}
+	for k, v := range openVPNDefaultHeaders {
+		headers[k] = v
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	lineNo := 0
+	sawData := false
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, ">") ||
+			strings.HasPrefix(line, "SUCCESS:") || strings.HasPrefix(line, "ERROR:") {
+			continue
+		}
+		if line == "END" {
+			break
+		}
+
+		sep := ","
+		if strings.Contains(line, "\t") {
+			sep = "\t"
+		}
+		fields := strings.Split(line, sep)
+
+		switch fields[0] {
+		case "TITLE":
+			status.Title = strings.Join(fields[1:], sep)
+		case "TIME":
+			if len(fields) >= 3 {
+				if t, err := parseOpenVPNTimeT(fields[2]); err == nil {
+					status.Time = t
+				}
+			}
+		case "HEADER":
+			if len(fields) >= 2 {
+				headers[fields[1]] = fields[2:]
+			}
+		case "CLIENT_LIST":
+			client, err := parseOpenVPNClient(columnMap(headers["CLIENT_LIST"], fields[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			status.Clients = append(status.Clients, client)
+			sawData = true
+		case "ROUTING_TABLE":
+			route, err := parseOpenVPNRoute(columnMap(headers["ROUTING_TABLE"], fields[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNo, err)
+			}
+			status.Routes = append(status.Routes, route)
+			sawData = true
+		case "GLOBAL_STATS":
+			if len(fields) >= 3 {
+				status.GlobalStats[fields[1]] = fields[2]
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unrecognized record %q (only status-version 2 and 3 are supported)", lineNo, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !sawData && status.Title == "" && status.Time.IsZero() {
+		return nil, fmt.Errorf("no OpenVPN status records found")
+	}
+
+	return status, nil
+}
+
+// columnMap pairs header names with record values
+func columnMap(header, values []string) map[string]string {
+	m := make(map[string]string, len(header))
+	for i, name := range header {
+		if i < len(values) {
+			m[name] = values[i]
+		}
+	}
+	return m
+}
+
+// parseOpenVPNClient builds a client entry from a CLIENT_LIST record
+func parseOpenVPNClient(cols map[string]string) (OpenVPNClient, error) {
+	client := OpenVPNClient{
+		CommonName:     cols["Common Name"],
+		RealAddress:    cols["Real Address"],
+		VirtualAddress: cols["Virtual Address"],
+		VirtualIPv6:    cols["Virtual IPv6 Address"],
+		Username:       cols["Username"],
+		ClientID:       cols["Client ID"],
+		PeerID:         cols["Peer ID"],
+		Cipher:         cols["Data Channel Cipher"],
+	}
+	if client.CommonName == "" {
+		return client, fmt.Errorf("CLIENT_LIST record without common name")
+	}
+
+	var err error
+	if client.BytesReceived, err = strconv.ParseInt(cols["Bytes Received"], 10, 64); err != nil {
+		return client, fmt.Errorf("invalid bytes received for %s", client.CommonName)
+	}
+	if client.BytesSent, err = strconv.ParseInt(cols["Bytes Sent"], 10, 64); err != nil {
+		return client, fmt.Errorf("invalid bytes sent for %s", client.CommonName)
+	}
+	if client.ConnectedSince, err = parseOpenVPNTimeT(cols["Connected Since (time_t)"]); err != nil {
+		return client, fmt.Errorf("invalid connected since for %s", client.CommonName)
+	}
+
+	return client, nil
+}
+
+// parseOpenVPNRoute builds a route entry from a ROUTING_TABLE record
+func parseOpenVPNRoute(cols map[string]string) (OpenVPNRoute, error) {
+	route := OpenVPNRoute{
+		VirtualAddress: cols["Virtual Address"],
+		CommonName:     cols["Common Name"],
+		RealAddress:    cols["Real Address"],
+	}
+	if route.VirtualAddress == "" {
+		return route, fmt.Errorf("ROUTING_TABLE record without virtual address")
+	}
+
+	var err error
+	if route.LastRef, err = parseOpenVPNTimeT(cols["Last Ref (time_t)"]); err != nil {
+		return route, fmt.Errorf("invalid last ref for %s", route.VirtualAddress)
+	}
+
+	return route, nil
+}
+
+// parseOpenVPNTimeT parses a Unix timestamp column
+func parseOpenVPNTimeT(v string) (time.Time, error) {
+	secs, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(secs, 0), nil
+}
+
+// OpenVPNIngestResult summarizes what the manager derived from an uploaded status snapshot
+type OpenVPNIngestResult struct {
+	ServerID       string    `json:"server_id"`
+	StatusTime     time.Time `json:"status_time"`
+	Clients        int       `json:"clients"`
+	SessionsOpened int       `json:"sessions_opened"`
+	SessionsClosed int       `json:"sessions_closed"`
+	BytesIn        int64     `json:"bytes_in"`
+	BytesOut       int64     `json:"bytes_out"`
+}
@@ -0,0 +1,123 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOpenVPNStatusFixtures(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+	}{
+		{"status-version 2", "openvpn_status_v2.txt"},
+		{"status-version 3", "openvpn_status_v3.txt"},
+		{"management interface with noise", "openvpn_management_status.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			status, err := ParseOpenVPNStatus(f)
+			if err != nil {
+				t.Fatalf("ParseOpenVPNStatus: %v", err)
+			}
+
+			if !strings.HasPrefix(status.Title, "OpenVPN 2.5.9") {
+				t.Errorf("Title = %q", status.Title)
+			}
+			if want := time.Unix(1764583200, 0); !status.Time.Equal(want) {
+				t.Errorf("Time = %v, want %v", status.Time, want)
+			}
+
+			if len(status.Clients) != 2 {
+				t.Fatalf("got %d clients, want 2", len(status.Clients))
+			}
+			alice := status.Clients[0]
+			if alice.CommonName != "alice" || alice.RealAddress != "203.0.113.10:51234" || alice.VirtualAddress != "10.8.0.2" {
+				t.Errorf("alice addresses = %+v", alice)
+			}
+			if alice.BytesReceived != 123456 || alice.BytesSent != 654321 {
+				t.Errorf("alice bytes = %d/%d, want 123456/654321", alice.BytesReceived, alice.BytesSent)
+			}
+			if want := time.Unix(1764579600, 0); !alice.ConnectedSince.Equal(want) {
+				t.Errorf("alice ConnectedSince = %v, want %v", alice.ConnectedSince, want)
+			}
+			if alice.Cipher != "AES-256-GCM" || alice.PeerID != "0" {
+				t.Errorf("alice cipher/peer = %q/%q", alice.Cipher, alice.PeerID)
+			}
+
+			// UNDEF usernames fall back to the certificate common name
+			if got := status.Clients[1].Account(); got != "bob-cert" {
+				t.Errorf("bob Account() = %q, want bob-cert", got)
+			}
+
+			if len(status.Routes) != 2 {
+				t.Fatalf("got %d routes, want 2", len(status.Routes))
+			}
+			if r := status.Routes[1]; r.VirtualAddress != "10.8.0.3" || r.CommonName != "bob-cert" || !r.LastRef.Equal(time.Unix(1764583180, 0)) {
+				t.Errorf("bob route = %+v", r)
+			}
+
+			if got := status.GlobalStats["Max bcast/mcast queue length"]; got != "0" {
+				t.Errorf("GlobalStats = %v", status.GlobalStats)
+			}
+		})
+	}
+}
+
+func TestParseOpenVPNStatusInline(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantErr     string
+		wantClients int
+	}{
+		{
+			name:        "default headers without HEADER lines",
+			input:       "CLIENT_LIST,carol,192.0.2.1:1194,10.8.0.4,,10,20,2025-12-01 09:00:00,1764579600,carol,2,2,AES-128-GCM\nEND\n",
+			wantClients: 1,
+		},
+		{
+			name:    "status-version 1 is rejected",
+			input:   "OpenVPN CLIENT LIST\nUpdated,2025-12-01 10:00:00\n",
+			wantErr: "unrecognized record",
+		},
+		{
+			name:    "invalid byte count",
+			input:   "CLIENT_LIST,carol,192.0.2.1:1194,10.8.0.4,,lots,20,2025-12-01 09:00:00,1764579600\n",
+			wantErr: "invalid bytes received",
+		},
+		{
+			name:    "only management noise",
+			input:   ">INFO:OpenVPN Management Interface Version 3\r\nSUCCESS: pid=1\r\nEND\r\n",
+			wantErr: "no OpenVPN status records",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := ParseOpenVPNStatus(strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseOpenVPNStatus: %v", err)
+			}
+			if len(status.Clients) != tt.wantClients {
+				t.Errorf("got %d clients, want %d", len(status.Clients), tt.wantClients)
+			}
+		})
+	}
+}
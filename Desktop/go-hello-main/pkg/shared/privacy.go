@@ -0,0 +1,98 @@
+package shared
+
+import "time"
+
+// UserDataExportVersion is bumped when the export layout changes incompatibly
+const UserDataExportVersion = 1
+
+// Data erasure request states
+const (
+	ErasureStatusPending   = "pending"
+	ErasureStatusPartial   = "partial"
+	ErasureStatusCompleted = "completed"
+)
+
+// UserProfileExport is the account record of an exported user
+type UserProfileExport struct {
+	ID                    int        `json:"id"`
+	PhoneNumber           string     `json:"phone_number"`
+	Active                bool       `json:"active"`
+	Plan                  string     `json:"plan,omitempty"`
+	MaxConcurrentSessions *int       `json:"max_concurrent_sessions,omitempty"`
+	CreatedAt             time.Time  `json:"created_at"`
+	LastLogin             *time.Time `json:"last_login,omitempty"`
+}
+
+// UsagePeriodRecord is a user's usage in one billing period
+type UsagePeriodRecord struct {
+	PeriodStart     time.Time  `json:"period_start"`
+	PeriodEnd       time.Time  `json:"period_end"`
+	BytesIn         int64      `json:"bytes_in"`
+	BytesOut        int64      `json:"bytes_out"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	ExhaustedAt     *time.Time `json:"exhausted_at,omitempty"`
+}
+
+// UserUsageExport is everything recorded about a user's VPN usage
+type UserUsageExport struct {
+	TotalBytesIn         int64               `json:"total_bytes_in"`
+	TotalBytesOut        int64               `json:"total_bytes_out"`
+	TotalDurationSeconds int64               `json:"total_duration_seconds"`
+	Daily                []UsageBucket       `json:"daily"`
+	Periods              []UsagePeriodRecord `json:"periods"`
+	Notifications        []QuotaNotification `json:"notifications"`
+}
+
+// UserDataExport is the archive of all data held about a user
+type UserDataExport struct {
+	Version           int                    `json:"version"`
+	GeneratedAt       time.Time              `json:"generated_at"`
+	Profile           UserProfileExport      `json:"profile"`
+	Preferences       *UserServerPreferences `json:"preferences,omitempty"`
+	Sessions          []VPNSession           `json:"sessions"`
+	ConnectionHistory []VPNSession           `json:"connection_history"`
+	Usage             UserUsageExport        `json:"usage"`
+	AuditEvents       []AuditEvent           `json:"audit_events"`
+}
+
+// DataErasureRequest tracks the erasure of a user's personal data
+// Username is cleared once erasure completes; the pseudonym replaces it in retained records
+type DataErasureRequest struct {
+	ID          int64               `json:"id"`
+	Username    string              `json:"username,omitempty"`
+	Pseudonym   string              `json:"pseudonym"`
+	Status      string              `json:"status"`
+	RequestedBy string              `json:"requested_by"`
+	Reason      string              `json:"reason,omitempty"`
+	RequestedAt time.Time           `json:"requested_at"`
+	ExecutedBy  string              `json:"executed_by,omitempty"`
+	ExecutedAt  *time.Time          `json:"executed_at,omitempty"`
+	Receipt     *DataErasureReceipt `json:"receipt,omitempty"`
+}
+
+// DataErasureTableResult is what erasure did to one table
+type DataErasureTableResult struct {
+	Table  string `json:"table"`
+	Action string `json:"action"` // deleted or anonymized
+	Rows   int64  `json:"rows"`
+}
+
+// DataErasureEndNodeResult is the outcome of removing the user's profile from an end-node
+type DataErasureEndNodeResult struct {
+	ServerID string `json:"server_id"`
+	Removed  bool   `json:"removed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// DataErasureReceipt records what an erasure did
+// Digest is the SHA-256 of the receipt without the digest, and is also written to the audit log
+type DataErasureReceipt struct {
+	RequestID  int64                      `json:"request_id"`
+	Pseudonym  string                     `json:"pseudonym"`
+	ExecutedBy string                     `json:"executed_by"`
+	ExecutedAt time.Time                  `json:"executed_at"`
+	Tables     []DataErasureTableResult   `json:"tables"`
+	EndNodes   []DataErasureEndNodeResult `json:"end_nodes"`
+	Retained   []string                   `json:"retained"`
+	Digest     string                     `json:"digest,omitempty"`
+}
@@ -0,0 +1,33 @@
+package shared
+
+import "time"
+
+// QuotaMetric describes usage against a single quota
+type QuotaMetric struct {
+	Limit     int64   `json:"limit"` // 0 means unlimited
+	Used      int64   `json:"used"`
+	Remaining int64   `json:"remaining"`
+	Percent   float64 `json:"percent"`
+}
+
+// QuotaNotification is a quota threshold crossed by a user
+type QuotaNotification struct {
+	Threshold int       `json:"threshold"`
+	Metric    string    `json:"metric"`
+	Used      int64     `json:"used"`
+	Limit     int64     `json:"limit"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// QuotaAllowance is a user's remaining allowance in the current billing period
+type QuotaAllowance struct {
+	Username        string              `json:"username"`
+	Plan            string              `json:"plan,omitempty"`
+	PeriodStart     time.Time           `json:"period_start"`
+	PeriodEnd       time.Time           `json:"period_end"`
+	Data            QuotaMetric         `json:"data"`
+	Time            QuotaMetric         `json:"time"`
+	Exhausted       bool                `json:"exhausted"`
+	OverQuotaAction string              `json:"over_quota_action,omitempty"`
+	Notifications   []QuotaNotification `json:"notifications"`
+}
@@ -0,0 +1,34 @@
+package shared
+
+import "time"
+
+// ServerRecommendation is a ranked server candidate returned to clients
+type ServerRecommendation struct {
+	ServerID     string  `json:"server_id"`
+	Host         string  `json:"host"`
+	Port         int     `json:"port"`
+	LocationID   int     `json:"location_id"`
+	City         string  `json:"city"`
+	Country      string  `json:"country"`
+	CountryCode  string  `json:"country_code"`
+	HealthStatus string  `json:"health_status"`
+	UserCount    int     `json:"user_count"`
+	Capacity     int     `json:"capacity"`
+	LoadPercent  float64 `json:"load_percent"`
+	LatencyMs    int     `json:"latency_ms"`
+	Score        float64 `json:"score"`
+}
+
+// RecommendationResult holds the best server pick and ordered fallbacks
+type RecommendationResult struct {
+	Best      *ServerRecommendation  `json:"best"`
+	Fallbacks []ServerRecommendation `json:"fallbacks"`
+}
+
+// UserServerPreferences holds a user's preferred server location
+type UserServerPreferences struct {
+	Username             string    `json:"username"`
+	PreferredLocationID  int       `json:"preferred_location_id,omitempty"`
+	PreferredCountryCode string    `json:"preferred_country_code,omitempty"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
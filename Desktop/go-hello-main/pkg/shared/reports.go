@@ -0,0 +1,15 @@
+package shared
+
+import "time"
+
+// Report is a tabular admin report; Columns gives the column order for CSV export
+type Report struct {
+	Name        string                   `json:"name"`
+	From        time.Time                `json:"from"`
+	To          time.Time                `json:"to"`
+	Granularity string                   `json:"granularity,omitempty"`
+	Columns     []string                 `json:"columns"`
+	Rows        []map[string]interface{} `json:"rows"`
+	Summary     map[string]interface{}   `json:"summary,omitempty"`
+	GeneratedAt time.Time                `json:"generated_at"`
+}
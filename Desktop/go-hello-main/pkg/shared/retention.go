@@ -0,0 +1,36 @@
+package shared
+
+import "time"
+
+// RetentionPolicy controls how long a table keeps rows and when its IPs are minimized
+// A nil day count disables that step
+type RetentionPolicy struct {
+	Table           string    `json:"table"`
+	RetainDays      *int      `json:"retain_days"`
+	HashIPAfterDays *int      `json:"hash_ip_after_days"`
+	UpdatedBy       string    `json:"updated_by,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// RetentionTableResult is what a retention run did to one table
+type RetentionTableResult struct {
+	Table     string     `json:"table"`
+	Cutoff    *time.Time `json:"cutoff,omitempty"`
+	Deleted   int64      `json:"deleted"`
+	IPCutoff  *time.Time `json:"ip_cutoff,omitempty"`
+	IPsHashed int64      `json:"ips_hashed"`
+	Batches   int        `json:"batches"`
+	Skipped   string     `json:"skipped,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// RetentionRun reports a pruning run; in a dry run counts are what would be affected
+type RetentionRun struct {
+	ID          int64                  `json:"id"`
+	StartedAt   time.Time              `json:"started_at"`
+	FinishedAt  *time.Time             `json:"finished_at,omitempty"`
+	DryRun      bool                   `json:"dry_run"`
+	TriggeredBy string                 `json:"triggered_by"`
+	Results     []RetentionTableResult `json:"results"`
+	Error       string                 `json:"error,omitempty"`
+}
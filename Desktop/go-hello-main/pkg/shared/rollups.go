@@ -0,0 +1,37 @@
+package shared
+
+import "time"
+
+// Usage rollup granularities
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+// UsageBucket is aggregated usage for one rollup interval
+type UsageBucket struct {
+	BucketStart     time.Time `json:"bucket_start"`
+	BytesIn         int64     `json:"bytes_in"`
+	BytesOut        int64     `json:"bytes_out"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	SampleCount     int       `json:"sample_count"`
+}
+
+// UsageSeries is a user's usage over a time range at a fixed granularity
+type UsageSeries struct {
+	Username    string        `json:"username"`
+	ServerID    string        `json:"server_id,omitempty"`
+	Granularity string        `json:"granularity"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	Buckets     []UsageBucket `json:"buckets"`
+}
+
+// RollupBackfill reports the result of rebuilding rollups from raw statistics
+type RollupBackfill struct {
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Days       int       `json:"days"`
+	HourlyRows int64     `json:"hourly_rows,omitempty"`
+	DailyRows  int64     `json:"daily_rows,omitempty"`
+}
@@ -0,0 +1,27 @@
+package shared
+
+import "time"
+
+// VPN session states
+const (
+	SessionStateConnecting   = "connecting"
+	SessionStateConnected    = "connected"
+	SessionStateDisconnected = "disconnected"
+	SessionStateError        = "error"
+)
+
+// VPNSession represents a VPN session from first status report until it is closed
+type VPNSession struct {
+	ID              int        `json:"id"`
+	SessionID       string     `json:"session_id"`
+	Username        string     `json:"username"`
+	ServerID        string     `json:"server_id"`
+	State           string     `json:"state"`
+	IPAddress       string     `json:"ip_address,omitempty"`
+	ConnectedAt     *time.Time `json:"connected_at,omitempty"`
+	DisconnectedAt  *time.Time `json:"disconnected_at,omitempty"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+	DurationSeconds int        `json:"duration_seconds"`
+	CloseReason     string     `json:"close_reason,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
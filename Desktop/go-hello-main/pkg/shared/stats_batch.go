@@ -0,0 +1,34 @@
+package shared
+
+// Statistics batch counter modes
+const (
+	CounterModeDelta      = "delta"
+	CounterModeCumulative = "cumulative"
+)
+
+// StatsSample is one usage sample in a batched upload
+// In cumulative mode the counters are running totals for the stream
+type StatsSample struct {
+	Seq      int64 `json:"seq"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+	Duration int64 `json:"duration_seconds"`
+}
+
+// StatsBatchRequest is a batch of samples from one client stream
+type StatsBatchRequest struct {
+	ServerID  string        `json:"server_id"`
+	SessionID string        `json:"session_id"`
+	Mode      string        `json:"mode"` // delta (default) or cumulative
+	Samples   []StatsSample `json:"samples"`
+}
+
+// StatsBatchResult reports how a batch was ingested
+type StatsBatchResult struct {
+	Accepted   int   `json:"accepted"`
+	Duplicates int   `json:"duplicates"`
+	LastSeq    int64 `json:"last_seq"`
+	BytesIn    int64 `json:"bytes_in"`
+	BytesOut   int64 `json:"bytes_out"`
+	Duration   int64 `json:"duration_seconds"`
+}
@@ -0,0 +1,67 @@
+package shared
+
+import "time"
+
+// Webhook event types, a subset of the event bus types
+const (
+	WebhookEventUserCreated       = EventUserCreated
+	WebhookEventEndNodeOffline    = EventEndNodeOffline
+	WebhookEventQuotaExceeded     = EventQuotaExceeded
+	WebhookEventProfileDownloaded = EventProfileDownloaded
+)
+
+// WebhookEventTypes lists every event a subscription can filter on
+// login.locked is left out until a lockout policy publishes it
+var WebhookEventTypes = []string{
+	WebhookEventUserCreated,
+	WebhookEventEndNodeOffline,
+	WebhookEventQuotaExceeded,
+	WebhookEventProfileDownloaded,
+}
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookEvent is the JSON body posted to subscribers
+type WebhookEvent struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// WebhookSubscription is an endpoint notified of matching events
+// Secret is only returned when the subscription is created
+type WebhookSubscription struct {
+	ID          int       `json:"id"`
+	URL         string    `json:"url"`
+	Secret      string    `json:"secret,omitempty"`
+	Events      []string  `json:"events"`
+	Description string    `json:"description,omitempty"`
+	Enabled     bool      `json:"enabled"`
+	CreatedBy   string    `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery tracks sending one event to one subscription
+type WebhookDelivery struct {
+	ID             int64         `json:"id"`
+	SubscriptionID int           `json:"subscription_id"`
+	EventID        string        `json:"event_id"`
+	EventType      string        `json:"event_type"`
+	Status         string        `json:"status"`
+	Attempts       int           `json:"attempts"`
+	NextAttemptAt  *time.Time    `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time    `json:"last_attempt_at,omitempty"`
+	LastStatusCode int           `json:"last_status_code,omitempty"`
+	LastError      string        `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time    `json:"delivered_at,omitempty"`
+	ReplayOf       int64         `json:"replay_of,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	Event          *WebhookEvent `json:"event,omitempty"`
+}